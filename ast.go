@@ -2,12 +2,32 @@ package monkey_interpreter
 
 import (
 	"bytes"
+	"math/big"
 	"strings"
 )
 
 type Node interface {
 	TokenLiteral() string // used only for testing + debugging
 	String() string       // also used for testing and debugging
+	Span() Span           // source range the node was parsed from
+}
+
+// spanTo returns the span from the start of tok to the end of end.
+// end may be nil when parsing failed part way through a node.
+func spanTo(tok Token, end Node) Span {
+	if end == nil {
+		return tok.Span
+	}
+	return Span{Start: tok.Span.Start, End: end.Span().End}
+}
+
+// closedSpan returns the span from start to the end of the closing
+// delimiter, falling back to the opening one if it was never found.
+func closedSpan(start Position, open, close Token) Span {
+	if close.Span.End.IsValid() {
+		return Span{Start: start, End: close.Span.End}
+	}
+	return Span{Start: start, End: open.Span.End}
 }
 
 // TODO: this type system is a little weird?
@@ -38,6 +58,15 @@ func (p *Program) TokenLiteral() string {
 	return ""
 }
 
+func (p *Program) Span() Span {
+	if len(p.Statements) == 0 {
+		return Span{}
+	}
+	first := p.Statements[0].Span()
+	last := p.Statements[len(p.Statements)-1].Span()
+	return Span{Start: first.Start, End: last.End}
+}
+
 func (p *Program) String() string {
 	var out bytes.Buffer
 	for _, s := range p.Statements {
@@ -53,20 +82,31 @@ type Identifier struct {
 
 func (i *Identifier) expressionNode()      {}
 func (i *Identifier) TokenLiteral() string { return i.Token.Literal }
+func (i *Identifier) Span() Span           { return i.Token.Span }
 
 func (i *Identifier) String() string { return i.Value }
 
 type LetStatement struct {
-	Token Token // the token.LET token
+	Token Token // the token.LET token, or token.CONST for constants
 	Name  *Identifier
 	Value Expression
 }
 
+// IsConst reports whether the binding was declared with const
+func (ls *LetStatement) IsConst() bool { return ls.Token.Type == CONST }
+
 func (ls *LetStatement) statementNode() {}
 func (ls *LetStatement) TokenLiteral() string {
 	return ls.Token.Literal
 }
 
+func (ls *LetStatement) Span() Span {
+	if ls.Value != nil {
+		return spanTo(ls.Token, ls.Value)
+	}
+	return spanTo(ls.Token, ls.Name)
+}
+
 func (ls *LetStatement) String() string {
 	var out bytes.Buffer
 	out.WriteString(ls.TokenLiteral() + " ")
@@ -86,6 +126,7 @@ type ReturnStatement struct {
 
 func (rs *ReturnStatement) statementNode()       {}
 func (rs *ReturnStatement) TokenLiteral() string { return rs.Token.Literal }
+func (rs *ReturnStatement) Span() Span           { return spanTo(rs.Token, rs.ReturnValue) }
 
 type ExpressionStatement struct {
 	Token      Token // the first token of the expression
@@ -102,8 +143,117 @@ func (rs *ReturnStatement) String() string {
 	return out.String()
 }
 
+type ThrowStatement struct {
+	Token Token // the 'throw' token
+	Value Expression
+}
+
+func (ts *ThrowStatement) statementNode()       {}
+func (ts *ThrowStatement) TokenLiteral() string { return ts.Token.Literal }
+func (ts *ThrowStatement) Span() Span           { return spanTo(ts.Token, ts.Value) }
+func (ts *ThrowStatement) String() string {
+	var out bytes.Buffer
+	out.WriteString(ts.TokenLiteral() + " ")
+	if ts.Value != nil {
+		out.WriteString(ts.Value.String())
+	}
+	out.WriteString(";")
+	return out.String()
+}
+
+// WhileStatement runs Body for as long as Condition is truthy
+type WhileStatement struct {
+	Token     Token // the 'while' token
+	Condition Expression
+	Body      *BlockStatement
+}
+
+func (ws *WhileStatement) statementNode()       {}
+func (ws *WhileStatement) TokenLiteral() string { return ws.Token.Literal }
+func (ws *WhileStatement) Span() Span {
+	if ws.Body != nil {
+		return spanTo(ws.Token, ws.Body)
+	}
+	return spanTo(ws.Token, ws.Condition)
+}
+func (ws *WhileStatement) String() string {
+	var out bytes.Buffer
+	out.WriteString("while (")
+	if ws.Condition != nil {
+		out.WriteString(ws.Condition.String())
+	}
+	out.WriteString(") ")
+	if ws.Body != nil {
+		out.WriteString(ws.Body.String())
+	}
+	return out.String()
+}
+
+// ForStatement runs Body once per element of Iterable, see evalForStatement.
+// Key is nil in the one variable form, for (x in xs).
+type ForStatement struct {
+	Token    Token // the 'for' token
+	Key      *Identifier
+	Value    *Identifier
+	Iterable Expression
+	Body     *BlockStatement
+}
+
+func (fs *ForStatement) statementNode()       {}
+func (fs *ForStatement) TokenLiteral() string { return fs.Token.Literal }
+func (fs *ForStatement) Span() Span {
+	if fs.Body != nil {
+		return spanTo(fs.Token, fs.Body)
+	}
+	return spanTo(fs.Token, fs.Iterable)
+}
+func (fs *ForStatement) String() string {
+	var out bytes.Buffer
+	out.WriteString("for (")
+	if fs.Key != nil {
+		out.WriteString(fs.Key.String() + ", ")
+	}
+	if fs.Value != nil {
+		out.WriteString(fs.Value.String())
+	}
+	out.WriteString(" in ")
+	if fs.Iterable != nil {
+		out.WriteString(fs.Iterable.String())
+	}
+	out.WriteString(") ")
+	if fs.Body != nil {
+		out.WriteString(fs.Body.String())
+	}
+	return out.String()
+}
+
+// BreakStatement and ContinueStatement only appear inside loop bodies
+type BreakStatement struct {
+	Token Token // the 'break' token
+}
+
+func (bs *BreakStatement) statementNode()       {}
+func (bs *BreakStatement) TokenLiteral() string { return bs.Token.Literal }
+func (bs *BreakStatement) Span() Span           { return bs.Token.Span }
+func (bs *BreakStatement) String() string       { return "break;" }
+
+type ContinueStatement struct {
+	Token Token // the 'continue' token
+}
+
+func (cs *ContinueStatement) statementNode()       {}
+func (cs *ContinueStatement) TokenLiteral() string { return cs.Token.Literal }
+func (cs *ContinueStatement) Span() Span           { return cs.Token.Span }
+func (cs *ContinueStatement) String() string       { return "continue;" }
+
 func (es *ExpressionStatement) statementNode()       {}
 func (es *ExpressionStatement) TokenLiteral() string { return es.Token.Literal }
+func (es *ExpressionStatement) Span() Span {
+	if es.Expression != nil {
+		return es.Expression.Span()
+	}
+	return es.Token.Span
+}
 
 func (es *ExpressionStatement) String() string {
 	if es.Expression != nil {
@@ -120,6 +270,28 @@ type IntegerLiteral struct {
 func (il *IntegerLiteral) expressionNode()      {}
 func (il *IntegerLiteral) TokenLiteral() string { return il.Token.Literal }
 func (il *IntegerLiteral) String() string       { return il.Token.Literal }
+func (il *IntegerLiteral) Span() Span           { return il.Token.Span }
+
+// BigIntegerLiteral is an integer literal too large for an IntegerLiteral
+type BigIntegerLiteral struct {
+	Token Token
+	Value *big.Int
+}
+
+func (bl *BigIntegerLiteral) expressionNode()      {}
+func (bl *BigIntegerLiteral) TokenLiteral() string { return bl.Token.Literal }
+func (bl *BigIntegerLiteral) String() string       { return bl.Token.Literal }
+func (bl *BigIntegerLiteral) Span() Span           { return bl.Token.Span }
+
+type FloatLiteral struct {
+	Token Token
+	Value float64
+}
+
+func (fl *FloatLiteral) expressionNode()      {}
+func (fl *FloatLiteral) TokenLiteral() string { return fl.Token.Literal }
+func (fl *FloatLiteral) String() string       { return fl.Token.Literal }
+func (fl *FloatLiteral) Span() Span           { return fl.Token.Span }
 
 type PrefixExpression struct {
 	Token    Token // The prefix token, e.g. !
@@ -129,6 +301,7 @@ type PrefixExpression struct {
 
 func (pe *PrefixExpression) expressionNode()      {}
 func (pe *PrefixExpression) TokenLiteral() string { return pe.Token.Literal }
+func (pe *PrefixExpression) Span() Span           { return spanTo(pe.Token, pe.Right) }
 func (pe *PrefixExpression) String() string {
 	var out bytes.Buffer
 	out.WriteString("(")
@@ -145,8 +318,44 @@ type InfixExpression struct {
 	Right    Expression
 }
 
+// AssignExpression stores Value in Target, an *Identifier or an
+// *IndexExpression. Compound assignments such as += carry the infix
+// operator to apply, plain ones an empty Operator.
+type AssignExpression struct {
+	Token    Token // the assignment token, e.g. +=
+	Target   Expression
+	Operator string
+	Value    Expression
+}
+
+func (ae *AssignExpression) expressionNode()      {}
+func (ae *AssignExpression) TokenLiteral() string { return ae.Token.Literal }
+func (ae *AssignExpression) Span() Span {
+	span := spanTo(ae.Token, ae.Value)
+	if ae.Target != nil {
+		span.Start = ae.Target.Span().Start
+	}
+	return span
+}
+func (ae *AssignExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString(ae.Target.String())
+	out.WriteString(" " + ae.Token.Literal + " ")
+	if ae.Value != nil {
+		out.WriteString(ae.Value.String())
+	}
+	return out.String()
+}
+
 func (ie *InfixExpression) expressionNode()      {}
 func (ie *InfixExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *InfixExpression) Span() Span {
+	span := spanTo(ie.Token, ie.Right)
+	if ie.Left != nil {
+		span.Start = ie.Left.Span().Start
+	}
+	return span
+}
 func (ie *InfixExpression) String() string {
 	var out bytes.Buffer
 	out.WriteString("(")
@@ -165,6 +374,7 @@ type BooleanLiteral struct {
 func (b *BooleanLiteral) expressionNode()      {}
 func (b *BooleanLiteral) TokenLiteral() string { return b.Token.Literal }
 func (b *BooleanLiteral) String() string       { return b.Token.Literal }
+func (b *BooleanLiteral) Span() Span           { return b.Token.Span }
 
 type IfExpression struct {
 	Token       Token // The 'if' token
@@ -177,6 +387,16 @@ func (ie *IfExpression) expressionNode() {}
 
 func (ie *IfExpression) TokenLiteral() string { return ie.Token.Literal }
 
+func (ie *IfExpression) Span() Span {
+	if ie.Alternative != nil {
+		return spanTo(ie.Token, ie.Alternative)
+	}
+	if ie.Consequence != nil {
+		return spanTo(ie.Token, ie.Consequence)
+	}
+	return ie.Token.Span
+}
+
 func (ie *IfExpression) String() string {
 	var out bytes.Buffer
 	out.WriteString("if")
@@ -190,15 +410,63 @@ func (ie *IfExpression) String() string {
 	return out.String()
 }
 
+// TryExpression evaluates Block, handing a runtime error raised by it to
+// Catch and running Finally either way. Catch or Finally may be nil, but
+// not both.
+type TryExpression struct {
+	Token      Token // The 'try' token
+	Block      *BlockStatement
+	CatchParam *Identifier // nil when the error isn't bound
+	Catch      *BlockStatement
+	Finally    *BlockStatement
+}
+
+func (te *TryExpression) expressionNode() {}
+
+func (te *TryExpression) TokenLiteral() string { return te.Token.Literal }
+
+func (te *TryExpression) Span() Span {
+	switch {
+	case te.Finally != nil:
+		return spanTo(te.Token, te.Finally)
+	case te.Catch != nil:
+		return spanTo(te.Token, te.Catch)
+	case te.Block != nil:
+		return spanTo(te.Token, te.Block)
+	}
+	return te.Token.Span
+}
+
+func (te *TryExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString("try ")
+	out.WriteString(te.Block.String())
+	if te.Catch != nil {
+		out.WriteString(" catch")
+		if te.CatchParam != nil {
+			out.WriteString("(" + te.CatchParam.String() + ")")
+		}
+		out.WriteString(" " + te.Catch.String())
+	}
+	if te.Finally != nil {
+		out.WriteString(" finally ")
+		out.WriteString(te.Finally.String())
+	}
+	return out.String()
+}
+
 type BlockStatement struct {
 	Token      Token // the { token
 	Statements []Statement
+	Close      Token // the } token
 }
 
 func (bs *BlockStatement) statementNode() {}
 
 func (bs *BlockStatement) TokenLiteral() string { return bs.Token.Literal }
 
+func (bs *BlockStatement) Span() Span { return closedSpan(bs.Token.Span.Start, bs.Token, bs.Close) }
+
 func (bs *BlockStatement) String() string {
 	var out bytes.Buffer
 	for _, s := range bs.Statements {
@@ -217,6 +485,13 @@ func (fl *FunctionLiteral) expressionNode() {}
 
 func (fl *FunctionLiteral) TokenLiteral() string { return fl.Token.Literal }
 
+func (fl *FunctionLiteral) Span() Span {
+	if fl.Body != nil {
+		return spanTo(fl.Token, fl.Body)
+	}
+	return fl.Token.Span
+}
+
 func (fl *FunctionLiteral) String() string {
 	var (
 		out    bytes.Buffer
@@ -237,12 +512,20 @@ type CallExpression struct {
 	Token     Token      // The '(' token
 	Function  Expression // Identifier or FunctionLiteral
 	Arguments []Expression
+	Close     Token // The ')' token
 }
 
 func (ce *CallExpression) expressionNode() {}
 func (ce *CallExpression) TokenLiteral() string {
 	return ce.Token.Literal
 }
+func (ce *CallExpression) Span() Span {
+	start := ce.Token.Span.Start
+	if ce.Function != nil {
+		start = ce.Function.Span().Start
+	}
+	return closedSpan(start, ce.Token, ce.Close)
+}
 func (ce *CallExpression) String() string {
 	var (
 		out  bytes.Buffer
@@ -266,14 +549,44 @@ type StringLiteral struct {
 func (sl *StringLiteral) expressionNode()      {}
 func (sl *StringLiteral) TokenLiteral() string { return sl.Token.Literal }
 func (sl *StringLiteral) String() string       { return sl.Token.Literal }
+func (sl *StringLiteral) Span() Span           { return sl.Token.Span }
+
+// InterpolatedString is a string literal with embedded expressions, e.g.
+// "a ${b} c". Strings holds the text around them, so it has one more
+// element than Expressions.
+type InterpolatedString struct {
+	Token       Token // the TEMPLATE_HEAD token
+	Strings     []string
+	Expressions []Expression
+	Close       Token // the TEMPLATE_TAIL token
+}
+
+func (is *InterpolatedString) expressionNode()      {}
+func (is *InterpolatedString) TokenLiteral() string { return is.Token.Literal }
+func (is *InterpolatedString) Span() Span {
+	return closedSpan(is.Token.Span.Start, is.Token, is.Close)
+}
+func (is *InterpolatedString) String() string {
+	var out bytes.Buffer
+	out.WriteString(is.Strings[0])
+	for i, exp := range is.Expressions {
+		out.WriteString("${" + exp.String() + "}")
+		if i+1 < len(is.Strings) {
+			out.WriteString(is.Strings[i+1])
+		}
+	}
+	return out.String()
+}
 
 type ArrayLiteral struct {
-	Token    Token
+	Token    Token // the '[' token
 	Elements []Expression
+	Close    Token // the ']' token
 }
 
 func (al *ArrayLiteral) expressionNode()      {}
 func (al *ArrayLiteral) TokenLiteral() string { return al.Token.Literal }
+func (al *ArrayLiteral) Span() Span           { return closedSpan(al.Token.Span.Start, al.Token, al.Close) }
 func (al *ArrayLiteral) String() string {
 	var out bytes.Buffer
 	var elements []string
@@ -290,10 +603,53 @@ type IndexExpression struct {
 	Token Token // The [ token
 	Left  Expression
 	Index Expression
+	Close Token // The ] token
+}
+
+// SliceExpression is Left[Low:High], either bound may be nil
+type SliceExpression struct {
+	Token Token // The [ token
+	Left  Expression
+	Low   Expression
+	High  Expression
+	Close Token // The ] token
+}
+
+func (se *SliceExpression) expressionNode()      {}
+func (se *SliceExpression) TokenLiteral() string { return se.Token.Literal }
+func (se *SliceExpression) Span() Span {
+	start := se.Token.Span.Start
+	if se.Left != nil {
+		start = se.Left.Span().Start
+	}
+	return closedSpan(start, se.Token, se.Close)
+}
+
+func (se *SliceExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString("(")
+	out.WriteString(se.Left.String())
+	out.WriteString("[")
+	if se.Low != nil {
+		out.WriteString(se.Low.String())
+	}
+	out.WriteString(":")
+	if se.High != nil {
+		out.WriteString(se.High.String())
+	}
+	out.WriteString("])")
+	return out.String()
 }
 
 func (ie *IndexExpression) expressionNode()      {}
 func (ie *IndexExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *IndexExpression) Span() Span {
+	start := ie.Token.Span.Start
+	if ie.Left != nil {
+		start = ie.Left.Span().Start
+	}
+	return closedSpan(start, ie.Token, ie.Close)
+}
 
 func (ie *IndexExpression) String() string {
 	var out bytes.Buffer
@@ -306,17 +662,24 @@ func (ie *IndexExpression) String() string {
 }
 
 type HashLiteral struct {
-	Token Token // the '{' token
-	Pairs map[Expression]Expression
+	Token Token             // the '{' token
+	Pairs []HashLiteralPair // in source order
+	Close Token             // the '}' token
+}
+
+type HashLiteralPair struct {
+	Key   Expression
+	Value Expression
 }
 
 func (hl *HashLiteral) expressionNode()      {}
 func (hl *HashLiteral) TokenLiteral() string { return hl.Token.Literal }
+func (hl *HashLiteral) Span() Span           { return closedSpan(hl.Token.Span.Start, hl.Token, hl.Close) }
 func (hl *HashLiteral) String() string {
 	var out bytes.Buffer
 	var pairs []string
-	for key, value := range hl.Pairs {
-		pairs = append(pairs, key.String()+":"+value.String())
+	for _, pair := range hl.Pairs {
+		pairs = append(pairs, pair.Key.String()+":"+pair.Value.String())
 	}
 	out.WriteString("{")
 	out.WriteString(strings.Join(pairs, ", "))
@@ -0,0 +1,86 @@
+package monkey_interpreter
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+var (
+	nodeType  = reflect.TypeOf((*Node)(nil)).Elem()
+	tokenType = reflect.TypeOf(Token{})
+)
+
+// DumpTree renders node as an indented tree, one node per line, e.g.
+//
+//	InfixExpression "+" 1:1-1:6
+//	  Left: IntegerLiteral 1 1:1-1:2
+//	  Right: IntegerLiteral 2 1:5-1:6
+//
+// It walks nodes by reflection, so new node types need no changes here.
+func DumpTree(node Node) string {
+	var out bytes.Buffer
+	dumpNode(&out, "", "", reflect.ValueOf(node))
+	return out.String()
+}
+
+func dumpNode(out *bytes.Buffer, indent, label string, v reflect.Value) {
+	if v.Kind() == reflect.Interface {
+		v = v.Elem()
+	}
+	if !v.IsValid() || (v.Kind() == reflect.Ptr && v.IsNil()) {
+		fmt.Fprintf(out, "%s%s<nil>\n", indent, label)
+		return
+	}
+	node := v.Interface().(Node)
+	elem := v.Elem()
+
+	// scalar fields go on the node's own line, children on the lines below
+	var scalars []string
+	type child struct {
+		label string
+		value reflect.Value
+	}
+	var children []child
+	for i := 0; i < elem.NumField(); i++ {
+		field := elem.Type().Field(i)
+		fv := elem.Field(i)
+		switch {
+		case !field.IsExported() || field.Type == tokenType:
+		case field.Type.Implements(nodeType) || field.Type == nodeType:
+			children = append(children, child{field.Name, fv})
+		case fv.Kind() == reflect.Slice && isNodeType(field.Type.Elem()):
+			for j := 0; j < fv.Len(); j++ {
+				children = append(children, child{fmt.Sprintf("%s[%d]", field.Name, j), fv.Index(j)})
+			}
+		case fv.Kind() == reflect.Slice && field.Type.Elem().Kind() == reflect.Struct:
+			// e.g. the key and value of each pair of a hash literal
+			for j := 0; j < fv.Len(); j++ {
+				item := fv.Index(j)
+				for k := 0; k < item.NumField(); k++ {
+					if isNodeType(item.Type().Field(k).Type) {
+						label := fmt.Sprintf("%s[%d].%s", field.Name, j, item.Type().Field(k).Name)
+						children = append(children, child{label, item.Field(k)})
+					}
+				}
+			}
+		case fv.Kind() == reflect.String:
+			scalars = append(scalars, fmt.Sprintf("%q", fv.String()))
+		case fv.Kind() != reflect.Slice && fv.Kind() != reflect.Map && fv.Kind() != reflect.Struct:
+			scalars = append(scalars, fmt.Sprint(fv.Interface()))
+		}
+	}
+
+	span := node.Span()
+	line := append([]string{elem.Type().Name()}, scalars...)
+	fmt.Fprintf(out, "%s%s%s %d:%d-%d:%d\n", indent, label, strings.Join(line, " "),
+		span.Start.Line, span.Start.Column, span.End.Line, span.End.Column)
+	for _, c := range children {
+		dumpNode(out, indent+"  ", c.label+": ", c.value)
+	}
+}
+
+func isNodeType(t reflect.Type) bool {
+	return t == nodeType || t.Implements(nodeType)
+}
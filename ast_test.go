@@ -24,3 +24,30 @@ func TestString(t *testing.T) {
 		t.Errorf("program.String() wrong. got=%q", program.String())
 	}
 }
+
+func TestNodeSpans(t *testing.T) {
+	input := "let add = fn(x, y) {\n  x + y;\n};\nadd(1, [2][0])"
+	l := NewLexer(input)
+	p := NewParser(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	tests := []struct {
+		node  Node
+		start string
+		end   string
+	}{
+		{program, "1:1", "4:15"},
+		{program.Statements[0], "1:1", "3:2"},
+		{program.Statements[0].(*LetStatement).Value, "1:11", "3:2"},
+		{program.Statements[1], "4:1", "4:15"},
+		{program.Statements[1].(*ExpressionStatement).Expression.(*CallExpression).Arguments[1], "4:8", "4:14"},
+	}
+	for _, tt := range tests {
+		span := tt.node.Span()
+		if span.Start.String() != tt.start || span.End.String() != tt.end {
+			t.Errorf("wrong span for %q. want=%s-%s, got=%s-%s",
+				tt.node.String(), tt.start, tt.end, span.Start, span.End)
+		}
+	}
+}
@@ -1,52 +1,1206 @@
 package monkey_interpreter
 
-import "fmt"
+import (
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
 
-var builtins = map[string]*Builtin{
-	"len": {
-		Fn: func(args ...Object) Object {
-			if len(args) != 1 {
-				return newError("wrong number of arguments. got=%d, want=1", len(args))
-			}
-			switch arg := args[0].(type) {
-			case *String:
-				return &Integer{Value: int64(len(arg.Value))}
-			case *Array:
-				return &Integer{Value: int64(len(arg.Elements))}
-			default:
-				return newError("argument to `len` not supported, got %s", args[0].Type())
-			}
+// builtins is the table used by environments that don't belong to an
+// Interpreter, it does I/O on the process' stdin, stdout and stderr
+var builtins map[string]*Builtin
+
+func init() {
+	// not in the declarations, builtins calling back into functions would
+	// make their initialization depend on themselves
+	builtins = newBuiltins(nil)
+}
+
+// newBuiltins returns a fresh builtin table doing I/O through interp
+func newBuiltins(interp *Interpreter) map[string]*Builtin {
+	return map[string]*Builtin{
+		"len": {
+			Fn: func(args ...Object) Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1", len(args))
+				}
+				switch arg := args[0].(type) {
+				case *String:
+					// in code points, bytes(s) has the bytes
+					return &Integer{Value: int64(utf8.RuneCountInString(arg.Value))}
+				case *Array:
+					return &Integer{Value: int64(arg.Len())}
+				case *MutableArray:
+					return &Integer{Value: int64(len(arg.Elements))}
+				case *Hash:
+					return &Integer{Value: int64(arg.Len())}
+				default:
+					return newError("argument to `len` not supported, got %s", args[0].Type())
+				}
+			},
 		},
-	},
-	"push": {
-		Fn: func(args ...Object) Object {
-			if len(args) != 2 {
-				return newError("wrong number of arguments. got=%d, want=2",
-					len(args))
-			}
-			if args[0].Type() != ARRAY_OBJ_TYPE {
-				return newError("argument to `push` must be ARRAY, got %s", args[0].Type())
+		"int": {
+			Fn: func(args ...Object) Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1", len(args))
+				}
+				switch arg := args[0].(type) {
+				case *Integer, *BigInteger:
+					return arg
+				case *Float:
+					// truncates toward zero, like Go
+					if math.IsNaN(arg.Value) || math.IsInf(arg.Value, 0) {
+						return newError("cannot convert %s to INTEGER", arg.Inspect())
+					}
+					value, _ := big.NewFloat(arg.Value).Int(nil)
+					return newInteger(value)
+				case *String:
+					value, ok := new(big.Int).SetString(arg.Value, 10)
+					if !ok {
+						return newError("could not parse %q as INTEGER", arg.Value)
+					}
+					return newInteger(value)
+				default:
+					return newError("argument to `int` not supported, got %s", args[0].Type())
+				}
+			},
+		},
+		"float": {
+			Fn: func(args ...Object) Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1", len(args))
+				}
+				switch arg := args[0].(type) {
+				case *Integer, *BigInteger:
+					return &Float{Value: toFloat(arg)}
+				case *Float:
+					return arg
+				case *String:
+					value, err := strconv.ParseFloat(arg.Value, 64)
+					if err != nil {
+						return newError("could not parse %q as FLOAT", arg.Value)
+					}
+					return &Float{Value: value}
+				default:
+					return newError("argument to `float` not supported, got %s", args[0].Type())
+				}
+			},
+		},
+		"bytes": {
+			Fn: func(args ...Object) Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1", len(args))
+				}
+				str, ok := args[0].(*String)
+				if !ok {
+					return newError("argument to `bytes` must be STRING, got %s", args[0].Type())
+				}
+				if err := interp.checkArrayLen(len(str.Value)); err != nil {
+					return err
+				}
+				elements := make([]Object, len(str.Value))
+				for i := 0; i < len(str.Value); i++ {
+					elements[i] = &Integer{Value: int64(str.Value[i])}
+				}
+				return NewArray(elements)
+			},
+		},
+		"split": {
+			Fn: func(args ...Object) Object {
+				if len(args) != 1 && len(args) != 2 {
+					return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+				}
+				s, err := stringArg("split", "first argument", args[0])
+				if err != nil {
+					return err
+				}
+				var parts []string
+				if len(args) == 1 {
+					// around runs of white space
+					parts = strings.Fields(s)
+				} else {
+					sep, err := stringArg("split", "second argument", args[1])
+					if err != nil {
+						return err
+					}
+					// an empty separator splits into characters
+					parts = strings.Split(s, sep)
+				}
+				return stringArray(interp, parts)
+			},
+		},
+		"join": {
+			Fn: func(args ...Object) Object {
+				if len(args) != 1 && len(args) != 2 {
+					return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+				}
+				arr, err := arrayArg("join", "first argument", args[0])
+				if err != nil {
+					return err
+				}
+				sep := ""
+				if len(args) == 2 {
+					if sep, err = stringArg("join", "second argument", args[1]); err != nil {
+						return err
+					}
+				}
+				// elements are printed like in interpolations
+				var out strings.Builder
+				for i, el := range arr.Elements() {
+					if i > 0 {
+						out.WriteString(sep)
+					}
+					out.WriteString(el.Inspect())
+					if err := interp.checkStringLen(out.Len()); err != nil {
+						return err
+					}
+				}
+				return &String{Value: out.String()}
+			},
+		},
+		"trim": {
+			Fn: func(args ...Object) Object {
+				if len(args) != 1 && len(args) != 2 {
+					return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+				}
+				s, err := stringArg("trim", "first argument", args[0])
+				if err != nil {
+					return err
+				}
+				if len(args) == 1 {
+					return &String{Value: strings.TrimSpace(s)}
+				}
+				// the characters of cutset
+				cutset, err := stringArg("trim", "second argument", args[1])
+				if err != nil {
+					return err
+				}
+				return &String{Value: strings.Trim(s, cutset)}
+			},
+		},
+		"upper": {
+			Fn: stringBuiltin("upper", strings.ToUpper),
+		},
+		"lower": {
+			Fn: stringBuiltin("lower", strings.ToLower),
+		},
+		"replace": {
+			Fn: func(args ...Object) Object {
+				if len(args) != 3 {
+					return newError("wrong number of arguments. got=%d, want=3", len(args))
+				}
+				var strs [3]string
+				for i, which := range []string{"first argument", "second argument", "third argument"} {
+					s, err := stringArg("replace", which, args[i])
+					if err != nil {
+						return err
+					}
+					strs[i] = s
+				}
+				s, from, to := strs[0], strs[1], strs[2]
+				// all occurrences, checking the size before building it
+				size := int64(len(s)) + int64(strings.Count(s, from))*int64(len(to)-len(from))
+				if err := checkBuiltStringLen(interp, "replace", size); err != nil {
+					return err
+				}
+				return &String{Value: strings.ReplaceAll(s, from, to)}
+			},
+		},
+		"starts_with": {
+			Fn: stringPredicateBuiltin("starts_with", strings.HasPrefix),
+		},
+		"ends_with": {
+			Fn: stringPredicateBuiltin("ends_with", strings.HasSuffix),
+		},
+		"repeat": {
+			Fn: func(args ...Object) Object {
+				if len(args) != 2 {
+					return newError("wrong number of arguments. got=%d, want=2", len(args))
+				}
+				s, err := stringArg("repeat", "first argument", args[0])
+				if err != nil {
+					return err
+				}
+				count, ok := args[1].(*Integer)
+				if !ok {
+					return newError("second argument to `repeat` must be INTEGER, got %s", args[1].Type())
+				}
+				if count.Value < 0 {
+					return newError("negative repeat count: %d", count.Value)
+				}
+				if len(s) > 0 && count.Value > maxBuiltStringLen/int64(len(s)) {
+					return newError("result of `repeat` is too large")
+				}
+				if err := checkBuiltStringLen(interp, "repeat", int64(len(s))*count.Value); err != nil {
+					return err
+				}
+				return &String{Value: strings.Repeat(s, int(count.Value))}
+			},
+		},
+		"pad_left": {
+			Fn: padBuiltin(interp, "pad_left", func(s, padding string) string {
+				return padding + s
+			}),
+		},
+		"pad_right": {
+			Fn: padBuiltin(interp, "pad_right", func(s, padding string) string {
+				return s + padding
+			}),
+		},
+		"chars": {
+			Fn: func(args ...Object) Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1", len(args))
+				}
+				s, err := stringArg("chars", "argument", args[0])
+				if err != nil {
+					return err
+				}
+				return stringArray(interp, strings.Split(s, ""))
+			},
+		},
+		"format": {
+			Fn: func(args ...Object) Object {
+				if len(args) < 1 {
+					return newError("wrong number of arguments. got=%d, want=1 or more", len(args))
+				}
+				format, err := stringArg("format", "first argument", args[0])
+				if err != nil {
+					return err
+				}
+				return formatString(interp, format, args[1:])
+			},
+		},
+		"push": {
+			Fn: func(args ...Object) Object {
+				if len(args) != 2 {
+					return newError("wrong number of arguments. got=%d, want=2",
+						len(args))
+				}
+				if args[0].Type() != ARRAY_OBJ_TYPE {
+					return newError("argument to `push` must be ARRAY, got %s", args[0].Type())
+				}
+				arr := args[0].(*Array)
+				if err := interp.checkArrayLen(arr.Len() + 1); err != nil {
+					return err
+				}
+				// a new array sharing arr's elements, arr is left as it was
+				return arr.Push(args[1])
+			},
+		},
+		"mutable": {
+			Fn: func(args ...Object) Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1", len(args))
+				}
+				arr, err := arrayArg("mutable", "argument", args[0])
+				if err != nil {
+					return err
+				}
+				return &MutableArray{Elements: arr.Elements()}
+			},
+		},
+		"freeze": {
+			Fn: func(args ...Object) Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1", len(args))
+				}
+				arr, ok := args[0].(*MutableArray)
+				if !ok {
+					return newError("argument to `freeze` must be MUTABLE_ARRAY, got %s", args[0].Type())
+				}
+				return NewArray(arr.Elements)
+			},
+		},
+		"append": {
+			Fn: func(args ...Object) Object {
+				if len(args) < 1 {
+					return newError("wrong number of arguments. got=%d, want=1 or more", len(args))
+				}
+				arr, ok := args[0].(*MutableArray)
+				if !ok {
+					return newError("first argument to `append` must be MUTABLE_ARRAY, got %s", args[0].Type())
+				}
+				if err := interp.checkArrayLen(len(arr.Elements) + len(args) - 1); err != nil {
+					return err
+				}
+				// in place, unlike push
+				arr.Elements = append(arr.Elements, args[1:]...)
+				return arr
+			},
+		},
+		"pop": {
+			Fn: func(args ...Object) Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1", len(args))
+				}
+				arr, ok := args[0].(*MutableArray)
+				if !ok {
+					return newError("argument to `pop` must be MUTABLE_ARRAY, got %s", args[0].Type())
+				}
+				n := len(arr.Elements)
+				if n == 0 {
+					return NULL_OBJ
+				}
+				last := arr.Elements[n-1]
+				arr.Elements[n-1] = nil
+				arr.Elements = arr.Elements[:n-1]
+				return last
+			},
+		},
+		"first": {
+			Fn: func(args ...Object) Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1", len(args))
+				}
+				arr, err := arrayArg("first", "argument", args[0])
+				if err != nil {
+					return err
+				}
+				if arr.Len() == 0 {
+					return NULL_OBJ
+				}
+				return arr.Get(0)
+			},
+		},
+		"last": {
+			Fn: func(args ...Object) Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1", len(args))
+				}
+				arr, err := arrayArg("last", "argument", args[0])
+				if err != nil {
+					return err
+				}
+				if arr.Len() == 0 {
+					return NULL_OBJ
+				}
+				return arr.Get(arr.Len() - 1)
+			},
+		},
+		"rest": {
+			Fn: func(args ...Object) Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1", len(args))
+				}
+				arr, err := arrayArg("rest", "argument", args[0])
+				if err != nil {
+					return err
+				}
+				if arr.Len() == 0 {
+					return NULL_OBJ
+				}
+				return arr.Slice(1, arr.Len())
+			},
+		},
+		"map": {
+			Fn: func(args ...Object) Object {
+				arr, fn, err := arrayFunctionArgs("map", args)
+				if err != nil {
+					return err
+				}
+				elements := make([]Object, arr.Len())
+				for i, el := range arr.Elements() {
+					result := callback(fn, el)
+					if isError(result) {
+						return result
+					}
+					elements[i] = result
+				}
+				return NewArray(elements)
+			},
+		},
+		"filter": {
+			Fn: func(args ...Object) Object {
+				arr, fn, err := arrayFunctionArgs("filter", args)
+				if err != nil {
+					return err
+				}
+				elements := []Object{}
+				for _, el := range arr.Elements() {
+					result := callback(fn, el)
+					if isError(result) {
+						return result
+					}
+					if isTruthy(result) {
+						elements = append(elements, el)
+					}
+				}
+				return NewArray(elements)
+			},
+		},
+		"reduce": {
+			Fn: func(args ...Object) Object {
+				if len(args) != 2 && len(args) != 3 {
+					return newError("wrong number of arguments. got=%d, want=2 or 3", len(args))
+				}
+				arr, err := arrayArg("reduce", "first argument", args[0])
+				if err != nil {
+					return err
+				}
+				if err := functionArg("reduce", "second argument", args[1]); err != nil {
+					return err
+				}
+				elements := arr.Elements()
+				var acc Object
+				if len(args) == 3 {
+					acc = args[2]
+				} else {
+					if len(elements) == 0 {
+						return newError("reduce of empty array with no initial value")
+					}
+					acc, elements = elements[0], elements[1:]
+				}
+				for _, el := range elements {
+					acc = callback(args[1], acc, el)
+					if isError(acc) {
+						return acc
+					}
+				}
+				return acc
+			},
+		},
+		"find": {
+			Fn: func(args ...Object) Object {
+				arr, fn, err := arrayFunctionArgs("find", args)
+				if err != nil {
+					return err
+				}
+				for _, el := range arr.Elements() {
+					result := callback(fn, el)
+					if isError(result) {
+						return result
+					}
+					if isTruthy(result) {
+						return el
+					}
+				}
+				return NULL_OBJ
+			},
+		},
+		"any": {
+			Fn: func(args ...Object) Object {
+				arr, fn, err := arrayFunctionArgs("any", args)
+				if err != nil {
+					return err
+				}
+				for _, el := range arr.Elements() {
+					result := callback(fn, el)
+					if isError(result) {
+						return result
+					}
+					if isTruthy(result) {
+						return TRUE_OBJ
+					}
+				}
+				return FALSE_OBJ
+			},
+		},
+		"all": {
+			Fn: func(args ...Object) Object {
+				arr, fn, err := arrayFunctionArgs("all", args)
+				if err != nil {
+					return err
+				}
+				for _, el := range arr.Elements() {
+					result := callback(fn, el)
+					if isError(result) {
+						return result
+					}
+					if !isTruthy(result) {
+						return FALSE_OBJ
+					}
+				}
+				return TRUE_OBJ
+			},
+		},
+		"zip": {
+			Fn: func(args ...Object) Object {
+				arrays, err := arrayArgs("zip", args)
+				if err != nil {
+					return err
+				}
+				// as long as the shortest array
+				n := arrays[0].Len()
+				for _, arr := range arrays[1:] {
+					if arr.Len() < n {
+						n = arr.Len()
+					}
+				}
+				if err := interp.checkArrayLen(n); err != nil {
+					return err
+				}
+				elements := make([]Object, n)
+				for i := range elements {
+					tuple := make([]Object, len(arrays))
+					for j, arr := range arrays {
+						tuple[j] = arr.Get(i)
+					}
+					elements[i] = NewArray(tuple)
+				}
+				return NewArray(elements)
+			},
+		},
+		"range": {
+			Fn: func(args ...Object) Object {
+				if len(args) < 1 || len(args) > 3 {
+					return newError("wrong number of arguments. got=%d, want=1 to 3", len(args))
+				}
+				bounds := make([]int64, len(args))
+				for i, arg := range args {
+					switch arg := arg.(type) {
+					case *Integer:
+						bounds[i] = arg.Value
+					case *BigInteger:
+						return newError("argument to `range` out of range: %s", arg.Inspect())
+					default:
+						return newError("arguments to `range` must be INTEGER, got %s", arg.Type())
+					}
+				}
+				start, end, step := int64(0), bounds[0], int64(1)
+				if len(bounds) > 1 {
+					start, end = bounds[0], bounds[1]
+				}
+				if len(bounds) > 2 {
+					step = bounds[2]
+				}
+				n, err := rangeLen(start, end, step)
+				if err != nil {
+					return err
+				}
+				if err := interp.checkRangeLen(n); err != nil {
+					return err
+				}
+				elements := make([]Object, n)
+				for i := range elements {
+					elements[i] = &Integer{Value: start + int64(i)*step}
+				}
+				return NewArray(elements)
+			},
+		},
+		"slice": {
+			Fn: func(args ...Object) Object {
+				if len(args) != 2 && len(args) != 3 {
+					return newError("wrong number of arguments. got=%d, want=2 or 3", len(args))
+				}
+				high := Object(NULL_OBJ)
+				if len(args) == 3 {
+					high = args[2]
+				}
+				// like x[low:high]
+				return sliceObject(args[0], args[1], high)
+			},
+		},
+		"concat": {
+			Fn: func(args ...Object) Object {
+				arrays, err := arrayArgs("concat", args)
+				if err != nil {
+					return err
+				}
+				n := 0
+				for _, arr := range arrays {
+					n += arr.Len()
+				}
+				if err := interp.checkArrayLen(n); err != nil {
+					return err
+				}
+				elements := make([]Object, 0, n)
+				for _, arr := range arrays {
+					elements = append(elements, arr.Elements()...)
+				}
+				return NewArray(elements)
+			},
+		},
+		"reverse": {
+			Fn: func(args ...Object) Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1", len(args))
+				}
+				arr, err := arrayArg("reverse", "argument", args[0])
+				if err != nil {
+					return err
+				}
+				n := arr.Len()
+				elements := make([]Object, n)
+				for i, el := range arr.Elements() {
+					elements[n-1-i] = el
+				}
+				return NewArray(elements)
+			},
+		},
+		"contains": {
+			Fn: func(args ...Object) Object {
+				if len(args) != 2 {
+					return newError("wrong number of arguments. got=%d, want=2", len(args))
+				}
+				arr, err := arrayArg("contains", "first argument", args[0])
+				if err != nil {
+					return err
+				}
+				return nativeBoolToBooleanObject(indexOf(arr, args[1]) >= 0)
+			},
+		},
+		"index_of": {
+			Fn: func(args ...Object) Object {
+				if len(args) != 2 {
+					return newError("wrong number of arguments. got=%d, want=2", len(args))
+				}
+				switch container := args[0].(type) {
+				case *Array, *MutableArray:
+					arr, _ := arrayArg("index_of", "first argument", container)
+					return &Integer{Value: int64(indexOf(arr, args[1]))}
+				case *String:
+					sub, err := stringArg("index_of", "second argument", args[1])
+					if err != nil {
+						return err
+					}
+					// in code points, like indexing
+					i := strings.Index(container.Value, sub)
+					if i > 0 {
+						i = utf8.RuneCountInString(container.Value[:i])
+					}
+					return &Integer{Value: int64(i)}
+				default:
+					return newError("first argument to `index_of` must be ARRAY or STRING, got %s", args[0].Type())
+				}
+			},
+		},
+		"flatten": {
+			Fn: func(args ...Object) Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1", len(args))
+				}
+				arr, err := arrayArg("flatten", "argument", args[0])
+				if err != nil {
+					return err
+				}
+				// one level deep
+				n := 0
+				for _, el := range arr.Elements() {
+					switch inner := el.(type) {
+					case *Array:
+						n += inner.Len()
+					case *MutableArray:
+						n += len(inner.Elements)
+					default:
+						n++
+					}
+				}
+				if err := interp.checkArrayLen(n); err != nil {
+					return err
+				}
+				elements := make([]Object, 0, n)
+				for _, el := range arr.Elements() {
+					switch inner := el.(type) {
+					case *Array:
+						elements = append(elements, inner.Elements()...)
+					case *MutableArray:
+						elements = append(elements, inner.Elements...)
+					default:
+						elements = append(elements, el)
+					}
+				}
+				return NewArray(elements)
+			},
+		},
+		"sort": {
+			Fn: func(args ...Object) Object {
+				if len(args) != 1 && len(args) != 2 {
+					return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+				}
+				arr, err := arrayArg("sort", "first argument", args[0])
+				if err != nil {
+					return err
+				}
+				if len(args) == 1 {
+					elements := arr.Elements()
+					return sortedArray(elements, elements, naturalOrder)
+				}
+				if err := functionArg("sort", "second argument", args[1]); err != nil {
+					return err
+				}
+				return sortedArray(arr.Elements(), arr.Elements(), func(a, b Object) (int, Object) {
+					result := callback(args[1], a, b)
+					switch result := result.(type) {
+					case *Error:
+						return 0, result
+					case *Integer:
+						switch {
+						case result.Value < 0:
+							return -1, nil
+						case result.Value > 0:
+							return 1, nil
+						}
+						return 0, nil
+					case *BigInteger:
+						return result.Value.Sign(), nil
+					}
+					return 0, newError("comparator passed to `sort` must return INTEGER, got %s", result.Type())
+				})
+			},
+		},
+		"sort_by": {
+			Fn: func(args ...Object) Object {
+				arr, fn, err := arrayFunctionArgs("sort_by", args)
+				if err != nil {
+					return err
+				}
+				// each key is computed once
+				keys := make([]Object, arr.Len())
+				for i, el := range arr.Elements() {
+					keys[i] = callback(fn, el)
+					if isError(keys[i]) {
+						return keys[i]
+					}
+				}
+				return sortedArray(arr.Elements(), keys, naturalOrder)
+			},
+		},
+		"keys": {
+			Fn: hashArrayBuiltin(interp, "keys", func(pair HashPair) Object {
+				return pair.Key
+			}),
+		},
+		"values": {
+			Fn: hashArrayBuiltin(interp, "values", func(pair HashPair) Object {
+				return pair.Value
+			}),
+		},
+		"entries": {
+			Fn: hashArrayBuiltin(interp, "entries", func(pair HashPair) Object {
+				return NewArray([]Object{pair.Key, pair.Value})
+			}),
+		},
+		"has": {
+			Fn: func(args ...Object) Object {
+				if len(args) != 2 {
+					return newError("wrong number of arguments. got=%d, want=2", len(args))
+				}
+				hash, key, err := hashKeyArgs("has", args)
+				if err != nil {
+					return err
+				}
+				_, ok := hash.Get(key)
+				return nativeBoolToBooleanObject(ok)
+			},
+		},
+		"get": {
+			Fn: func(args ...Object) Object {
+				if len(args) != 2 && len(args) != 3 {
+					return newError("wrong number of arguments. got=%d, want=2 or 3", len(args))
+				}
+				hash, key, err := hashKeyArgs("get", args)
+				if err != nil {
+					return err
+				}
+				if pair, ok := hash.Get(key); ok {
+					return pair.Value
+				}
+				if len(args) == 3 {
+					return args[2]
+				}
+				return NULL_OBJ
+			},
+		},
+		"delete": {
+			Fn: func(args ...Object) Object {
+				if len(args) != 2 {
+					return newError("wrong number of arguments. got=%d, want=2", len(args))
+				}
+				hash, key, err := hashKeyArgs("delete", args)
+				if err != nil {
+					return err
+				}
+				// in place, like index assignment
+				return nativeBoolToBooleanObject(hash.Delete(key))
+			},
+		},
+		"merge": {
+			Fn: func(args ...Object) Object {
+				if len(args) == 0 {
+					return newError("wrong number of arguments. got=0, want=1 or more")
+				}
+				merged := NewHash(0)
+				for _, arg := range args {
+					hash, ok := arg.(*Hash)
+					if !ok {
+						return newError("arguments to `merge` must be HASH, got %s", arg.Type())
+					}
+					// later hashes win, keys keep their first position
+					for _, pair := range hash.Pairs() {
+						merged.Set(pair.Key.(Hashable), pair.Value)
+					}
+				}
+				return merged
+			},
+		},
+		"puts": {
+			Fn: func(args ...Object) Object {
+				for _, arg := range args {
+					fmt.Fprintln(interp.stdout(), arg.Inspect())
+				}
+				return NULL_OBJ
+			},
+		},
+		"eputs": {
+			Fn: func(args ...Object) Object {
+				for _, arg := range args {
+					fmt.Fprintln(interp.stderr(), arg.Inspect())
+				}
+				return NULL_OBJ
+			},
+		},
+		"error": {
+			Fn: func(args ...Object) Object {
+				if len(args) != 1 && len(args) != 2 {
+					return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+				}
+				message, ok := args[0].(*String)
+				if !ok {
+					return newError("argument to `error` must be STRING, got %s", args[0].Type())
+				}
+				err := &Error{Message: message.Value}
+				if len(args) == 2 {
+					err.Payload = args[1]
+				}
+				return &ErrorValue{Error: err}
+			},
+		},
+		"error_message": {
+			Fn: errorValueBuiltin("error_message", func(err *Error) Object {
+				return &String{Value: err.Message}
+			}),
+		},
+		"error_payload": {
+			Fn: errorValueBuiltin("error_payload", func(err *Error) Object {
+				if err.Payload == nil {
+					return NULL_OBJ
+				}
+				return err.Payload
+			}),
+		},
+		"error_stack": {
+			Fn: errorValueBuiltin("error_stack", func(err *Error) Object {
+				frames := make([]Object, len(err.Stack))
+				for i, frame := range err.Stack {
+					frames[i] = &String{Value: frame.String()}
+				}
+				return NewArray(frames)
+			}),
+		},
+		"gets": {
+			Fn: func(args ...Object) Object {
+				if len(args) != 0 {
+					return newError("wrong number of arguments. got=%d, want=0", len(args))
+				}
+				line, err := interp.stdin().ReadString('\n')
+				if err != nil && (err != io.EOF || line == "") {
+					// end of input
+					return NULL_OBJ
+				}
+				return &String{Value: strings.TrimRight(line, "\r\n")}
+			},
+		},
+	}
+}
+
+// stringArg returns the value of arg, a string, which names the argument
+// in errors
+func stringArg(name, which string, arg Object) (string, *Error) {
+	str, ok := arg.(*String)
+	if !ok {
+		return "", newError("%s to `%s` must be STRING, got %s", which, name, arg.Type())
+	}
+	return str.Value, nil
+}
+
+// stringBuiltin returns a builtin mapping a string with fn
+func stringBuiltin(name string, fn func(string) string) BuiltinFunction {
+	return func(args ...Object) Object {
+		if len(args) != 1 {
+			return newError("wrong number of arguments. got=%d, want=1", len(args))
+		}
+		s, err := stringArg(name, "argument", args[0])
+		if err != nil {
+			return err
+		}
+		return &String{Value: fn(s)}
+	}
+}
+
+// stringPredicateBuiltin returns a builtin testing two strings with fn
+func stringPredicateBuiltin(name string, fn func(s, t string) bool) BuiltinFunction {
+	return func(args ...Object) Object {
+		if len(args) != 2 {
+			return newError("wrong number of arguments. got=%d, want=2", len(args))
+		}
+		s, err := stringArg(name, "first argument", args[0])
+		if err != nil {
+			return err
+		}
+		t, err := stringArg(name, "second argument", args[1])
+		if err != nil {
+			return err
+		}
+		return nativeBoolToBooleanObject(fn(s, t))
+	}
+}
+
+// padBuiltin returns a builtin padding a string to a width in code points
+// with a pad character, a space unless given, joined to it by join
+func padBuiltin(interp *Interpreter, name string, join func(s, padding string) string) BuiltinFunction {
+	return func(args ...Object) Object {
+		if len(args) != 2 && len(args) != 3 {
+			return newError("wrong number of arguments. got=%d, want=2 or 3", len(args))
+		}
+		s, err := stringArg(name, "first argument", args[0])
+		if err != nil {
+			return err
+		}
+		width, ok := args[1].(*Integer)
+		if !ok {
+			return newError("second argument to `%s` must be INTEGER, got %s", name, args[1].Type())
+		}
+		pad := " "
+		if len(args) == 3 {
+			if pad, err = stringArg(name, "third argument", args[2]); err != nil {
+				return err
 			}
-			arr := args[0].(*Array)
-
-			// NOTE: i would prefer to reuse the original array
-			// the book makes a new copy
-
-			//length := len(arr.Elements)
-			//newElements := make([]Object, length+1, length+1)
-			//copy(newElements, arr.Elements)
-			//newElements[length] = args[1]
-			arr.Elements = append(arr.Elements, args[1])
-			//return &Array{Elements: newElements}
-			return arr
-		},
-	},
-	"puts": {
-		Fn: func(args ...Object) Object {
-			for _, arg := range args {
-				fmt.Println(arg.Inspect())
+			if utf8.RuneCountInString(pad) != 1 {
+				return newError("pad passed to `%s` must be a single character, got %q", name, pad)
 			}
-			return NULL_OBJ
-		},
-	},
+		}
+		missing := width.Value - int64(utf8.RuneCountInString(s))
+		if missing <= 0 {
+			return args[0]
+		}
+		if missing > maxBuiltStringLen/int64(len(pad)) {
+			return newError("result of `%s` is too large", name)
+		}
+		if err := checkBuiltStringLen(interp, name, int64(len(s))+missing*int64(len(pad))); err != nil {
+			return err
+		}
+		return &String{Value: join(s, strings.Repeat(pad, int(missing)))}
+	}
+}
+
+// maxBuiltStringLen bounds the strings built by builtins like `repeat`,
+// whatever the interpreter's limits, so a huge one fails instead of
+// exhausting memory
+const maxBuiltStringLen = math.MaxInt32
+
+// checkBuiltStringLen reports whether the builtin name may build a string
+// of size bytes
+func checkBuiltStringLen(interp *Interpreter, name string, size int64) *Error {
+	if size > maxBuiltStringLen {
+		return newError("result of `%s` is too large", name)
+	}
+	return interp.checkStringLen(int(size))
+}
+
+// stringArray returns an array of strs, if it isn't too long
+func stringArray(interp *Interpreter, strs []string) Object {
+	if err := interp.checkArrayLen(len(strs)); err != nil {
+		return err
+	}
+	elements := make([]Object, len(strs))
+	for i, s := range strs {
+		elements[i] = &String{Value: s}
+	}
+	return NewArray(elements)
+}
+
+// arrayArg returns arg as an array, which names the argument in errors. A
+// mutable array is copied, so builtins reading arrays take either kind.
+func arrayArg(name, which string, arg Object) (*Array, *Error) {
+	switch arg := arg.(type) {
+	case *Array:
+		return arg, nil
+	case *MutableArray:
+		// read as a snapshot, results are plain arrays
+		return NewArray(arg.Elements), nil
+	}
+	return nil, newError("%s to `%s` must be ARRAY, got %s", which, name, arg.Type())
+}
+
+// arrayArgs checks the arguments of a builtin taking one or more arrays
+func arrayArgs(name string, args []Object) ([]*Array, *Error) {
+	if len(args) == 0 {
+		return nil, newError("wrong number of arguments. got=0, want=1 or more")
+	}
+	arrays := make([]*Array, len(args))
+	for i, arg := range args {
+		arr, err := arrayArg(name, "arguments", arg)
+		if err != nil {
+			return nil, err
+		}
+		arrays[i] = arr
+	}
+	return arrays, nil
+}
+
+// functionArg checks that arg can be called back
+func functionArg(name, which string, arg Object) *Error {
+	switch arg.(type) {
+	case *Function, *Builtin, Callable:
+		return nil
+	}
+	return newError("%s to `%s` must be FUNCTION, got %s", which, name, arg.Type())
+}
+
+// arrayFunctionArgs checks the arguments of a builtin like `map`, taking
+// an array and a function called with each element
+func arrayFunctionArgs(name string, args []Object) (*Array, Object, *Error) {
+	if len(args) != 2 {
+		return nil, nil, newError("wrong number of arguments. got=%d, want=2", len(args))
+	}
+	arr, err := arrayArg(name, "first argument", args[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := functionArg(name, "second argument", args[1]); err != nil {
+		return nil, nil, err
+	}
+	return arr, args[1], nil
+}
+
+// callback calls fn on behalf of a builtin, a function without a result
+// gives null
+func callback(fn Object, args ...Object) Object {
+	result := applyFunction(fn, args)
+	if result == nil {
+		return NULL_OBJ
+	}
+	return result
+}
+
+// maxRangeLen bounds the arrays built by `range` whatever the
+// interpreter's limits, their length must fit an int on every platform
+const maxRangeLen = math.MaxInt32
+
+// rangeLen returns the number of elements of range(start, end, step)
+func rangeLen(start, end, step int64) (int, *Error) {
+	if step == 0 {
+		return 0, newError("range step must not be zero")
+	}
+	// in uint64, as end - start may overflow int64
+	var distance, stride uint64
+	switch {
+	case step > 0 && start < end:
+		distance, stride = uint64(end)-uint64(start), uint64(step)
+	case step < 0 && start > end:
+		distance, stride = uint64(start)-uint64(end), -uint64(step)
+	default:
+		return 0, nil
+	}
+	n := (distance-1)/stride + 1
+	if n > maxRangeLen {
+		return 0, newError("range of %d elements is too large", n)
+	}
+	return int(n), nil
+}
+
+// indexOf returns the position of the first element of arr equal to
+// value, or -1
+func indexOf(arr *Array, value Object) int {
+	for i, el := range arr.Elements() {
+		if valuesEqual(el, value) {
+			return i
+		}
+	}
+	return -1
+}
+
+// sortedArray returns a copy of elements stably sorted by their keys, the
+// element at the same position in keys. The first error from compare is
+// returned instead.
+func sortedArray(elements, keys []Object, compare func(a, b Object) (int, Object)) Object {
+	order := make([]int, len(elements))
+	for i := range order {
+		order[i] = i
+	}
+	var err Object
+	sort.SliceStable(order, func(i, j int) bool {
+		if err != nil {
+			return false
+		}
+		c, cmpErr := compare(keys[order[i]], keys[order[j]])
+		if cmpErr != nil {
+			err = cmpErr
+			return false
+		}
+		return c < 0
+	})
+	if err != nil {
+		return err
+	}
+	sorted := make([]Object, len(order))
+	for i, k := range order {
+		sorted[i] = elements[k]
+	}
+	return NewArray(sorted)
+}
+
+// naturalOrder is compareValues for sortedArray
+func naturalOrder(a, b Object) (int, Object) {
+	c, err := compareValues(a, b)
+	if err != nil {
+		return 0, err
+	}
+	return c, nil
+}
+
+// hashArrayBuiltin returns a builtin taking a single hash and returning an
+// array with fn's result for each of its pairs
+func hashArrayBuiltin(interp *Interpreter, name string, fn func(pair HashPair) Object) BuiltinFunction {
+	return func(args ...Object) Object {
+		if len(args) != 1 {
+			return newError("wrong number of arguments. got=%d, want=1", len(args))
+		}
+		hash, ok := args[0].(*Hash)
+		if !ok {
+			return newError("argument to `%s` must be HASH, got %s", name, args[0].Type())
+		}
+		if err := interp.checkArrayLen(hash.Len()); err != nil {
+			return err
+		}
+		elements := make([]Object, hash.Len())
+		for i, pair := range hash.Pairs() {
+			elements[i] = fn(pair)
+		}
+		return NewArray(elements)
+	}
+}
+
+// hashKeyArgs checks the hash and key arguments of a builtin like `has`
+func hashKeyArgs(name string, args []Object) (*Hash, Hashable, *Error) {
+	hash, ok := args[0].(*Hash)
+	if !ok {
+		return nil, nil, newError("first argument to `%s` must be HASH, got %s", name, args[0].Type())
+	}
+	key, err := hashableKey(args[1])
+	if err != nil {
+		return nil, nil, err
+	}
+	return hash, key, nil
+}
+
+// errorValueBuiltin returns a builtin taking a single error value, as
+// caught by a catch clause
+func errorValueBuiltin(name string, fn func(err *Error) Object) BuiltinFunction {
+	return func(args ...Object) Object {
+		if len(args) != 1 {
+			return newError("wrong number of arguments. got=%d, want=1", len(args))
+		}
+		val, ok := args[0].(*ErrorValue)
+		if !ok {
+			return newError("argument to `%s` must be ERROR_VALUE, got %s", name, args[0].Type())
+		}
+		return fn(val.Error)
+	}
 }
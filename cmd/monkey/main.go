@@ -1,19 +1,204 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	monkey "monkey-interpreter"
+	"monkey-interpreter/compiler"
+	"monkey-interpreter/vm"
 	"os"
 	"os/user"
+	"strings"
+)
+
+const usage = `Usage:
+  monkey                      start the REPL, or run a program piped to stdin
+  monkey run [flags] FILE [--] [ARGS...]
+                              run the script FILE
+  monkey -e EXPR [ARGS...]    evaluate EXPR and print its value
+
+Script arguments are available to the program as the array ` + "`args`" + `.
+
+With -vm, programs run on the bytecode VM. It doesn't support loops,
+assignment, throw and try, programs using them run on the interpreter.
+
+Flags:
+`
+
+// exit codes
+const (
+	exitOK    = 0
+	exitError = 1 // the program failed to parse or raised a runtime error
+	exitUsage = 2
 )
 
 func main() {
-	usr, err := user.Current()
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(argv []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("monkey", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	expr := flags.String("e", "", "evaluate `EXPR` instead of a file")
+	noCache := flags.Bool("no-cache", false, "do not use the on-disk parse cache")
+	useVM := flags.Bool("vm", false, "run programs on the bytecode VM")
+	flags.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(argv); err != nil {
+		return exitUsage
+	}
+	rest := flags.Args()
+
+	isSet := func(name string) bool {
+		set := false
+		flags.Visit(func(f *flag.Flag) { set = set || f.Name == name })
+		return set
+	}
+
+	switch {
+	case isSet("e"):
+		program, diagnostics := parse("<expr>", *expr)
+		return execute(program, diagnostics, rest, true, *useVM, stdin, stdout, stderr)
+	case len(rest) > 0 && rest[0] == "run":
+		runFlags := flag.NewFlagSet("monkey run", flag.ContinueOnError)
+		runFlags.SetOutput(stderr)
+		runFlags.BoolVar(noCache, "no-cache", *noCache, "do not use the on-disk parse cache")
+		runFlags.BoolVar(useVM, "vm", *useVM, "run programs on the bytecode VM")
+		runFlags.Usage = flags.Usage
+		if err := runFlags.Parse(rest[1:]); err != nil {
+			return exitUsage
+		}
+		if runFlags.NArg() == 0 {
+			fmt.Fprintln(stderr, "monkey run: missing script file")
+			flags.Usage()
+			return exitUsage
+		}
+		path := runFlags.Arg(0)
+		program, diagnostics, err := monkey.ParseFile(path, parseCache(*noCache))
+		if err != nil {
+			fmt.Fprintf(stderr, "monkey: %s\n", err)
+			return exitError
+		}
+		args := runFlags.Args()[1:]
+		if len(args) > 0 && args[0] == "--" {
+			// monkey run FILE -- ARGS... keeps ARGS from looking like flags
+			args = args[1:]
+		}
+		return execute(program, diagnostics, args, false, *useVM, stdin, stdout, stderr)
+	case len(rest) > 0:
+		fmt.Fprintf(stderr, "monkey: unknown command %q\n", rest[0])
+		flags.Usage()
+		return exitUsage
+	case !isTerminal(stdin):
+		source, err := io.ReadAll(stdin)
+		if err != nil {
+			fmt.Fprintf(stderr, "monkey: %s\n", err)
+			return exitError
+		}
+		program, diagnostics := parse("<stdin>", string(source))
+		return execute(program, diagnostics, nil, false, *useVM, nil, stdout, stderr)
+	default:
+		greet(stdout)
+		r := monkey.NewREPL(stdin, stdout)
+		r.HistoryFile = monkey.DefaultHistoryFile()
+		r.ParseCache = parseCache(*noCache)
+		r.Run()
+		return exitOK
+	}
+}
+
+// parseCache returns the on-disk parse cache, or nil if it is disabled or
+// the platform has no cache directory
+func parseCache(disabled bool) *monkey.ParseCache {
+	if dir := monkey.DefaultParseCacheDir(); dir != "" && !disabled {
+		return monkey.NewParseCache(dir)
+	}
+	return nil
+}
+
+func parse(filename, source string) (*monkey.Program, []monkey.Diagnostic) {
+	p := monkey.NewParser(monkey.NewFileLexer(filename, source))
+	program := p.ParseProgram()
+	return program, p.Errors()
+}
+
+// execute evaluates program with args bound, reporting errors on stderr.
+// printResult echoes the value of the program, as for -e. useVM runs it on
+// the bytecode VM if it can be compiled. stdin is nil when the program
+// itself was read from it.
+func execute(
+	program *monkey.Program, diagnostics []monkey.Diagnostic, args []string,
+	printResult, useVM bool, stdin io.Reader, stdout, stderr io.Writer,
+) int {
+	if len(diagnostics) != 0 {
+		for _, d := range diagnostics {
+			fmt.Fprintln(stderr, d.String())
+		}
+		return exitError
+	}
+	interp := monkey.NewInterpreter()
+	interp.Stdout = stdout
+	interp.Stderr = stderr
+	if stdin != nil {
+		interp.Stdin = stdin
+	} else {
+		interp.Stdin = strings.NewReader("")
+	}
+	interp.SetGlobal("args", scriptArgs(args))
+	var (
+		result monkey.Object
+		err    error
+	)
+	if useVM {
+		result, err = vm.Run(interp, program)
+	}
+	if !useVM || errors.Is(err, compiler.ErrUnsupported) {
+		result, err = interp.EvalProgram(program)
+	}
+	if errObj, ok := err.(*monkey.Error); ok {
+		fmt.Fprintf(stderr, "%s: runtime error: %s\n", errObj.Pos, errObj.Message)
+		if len(errObj.Stack) > 1 {
+			// a trace of just <main> says nothing the position doesn't
+			fmt.Fprint(stderr, errObj.StackTrace())
+		}
+		return exitError
+	}
+	if printResult && result != monkey.NULL_OBJ {
+		fmt.Fprintln(stdout, result.Inspect())
+	}
+	return exitOK
+}
+
+func scriptArgs(args []string) *monkey.Array {
+	elements := make([]monkey.Object, len(args))
+	for i, a := range args {
+		elements[i] = &monkey.String{Value: a}
+	}
+	return monkey.NewArray(elements)
+}
+
+// isTerminal reports whether r is an interactive terminal
+func isTerminal(r io.Reader) bool {
+	f, ok := r.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
 	if err != nil {
-		panic(err)
+		return false
 	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
 
-	fmt.Printf("Hello %s! This is the Monkey programming language!\n", usr.Username)
-	fmt.Printf("Feel free to type in commands\n")
-	monkey.Start(os.Stdin, os.Stdout)
+func greet(out io.Writer) {
+	name := "there"
+	if usr, err := user.Current(); err == nil {
+		name = usr.Username
+	}
+	fmt.Fprintf(out, "Hello %s! This is the Monkey programming language!\n", name)
+	fmt.Fprintf(out, "Feel free to type in commands\n")
 }
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "script.mk")
+	require.NoError(t, os.WriteFile(script, []byte(`
+let greet = fn(name) {
+  puts("hello " + name);
+};
+greet(args[0]);
+`), 0o644))
+	broken := filepath.Join(dir, "broken.mk")
+	require.NoError(t, os.WriteFile(broken, []byte("let x = 1;\nx + y;\n"), 0o644))
+	unparsable := filepath.Join(dir, "unparsable.mk")
+	require.NoError(t, os.WriteFile(unparsable, []byte("let = 1;\n"), 0o644))
+
+	tests := []struct {
+		name     string
+		argv     []string
+		stdin    string
+		code     int
+		stdout   string
+		stderr   string
+		contains bool // stderr only needs to contain the expected text
+	}{
+		{"expression", []string{"-e", "1 + 2 * 3"}, "", exitOK, "7\n", "", false},
+		{"expression args", []string{"-e", "len(args)", "a", "b"}, "", exitOK, "2\n", "", false},
+		{"expression error", []string{"-e", "1 + true"}, "", exitError, "",
+			"<expr>:1:1: runtime error: type mismatch: INTEGER + BOOLEAN\n", false},
+		{"uncaught throw", []string{"-e", `let f = fn() { throw "bad" }; f()`}, "", exitError, "",
+			"<expr>:1:16: runtime error: bad\n\tat f (<expr>:1:16)\n\tat <main> (<expr>:1:31)\n", false},
+		{"stdin program", nil, "let f = fn(x) {\n  x * 2\n};\nputs(f(21));", exitOK, "42\n", "", false},
+		{"script file", []string{"run", "-no-cache", script, "world"}, "", exitOK, "hello world\n", "", false},
+		{"script args after --", []string{"run", "-no-cache", script, "--", "-x"}, "", exitOK, "hello -x\n", "", false},
+		{"script reads stdin", []string{"-e", "gets() + gets()", "x"}, "a\nb\n", exitOK, "ab\n", "", false},
+		{"runtime error", []string{"run", "-no-cache", broken}, "", exitError, "",
+			broken + ":2:5: runtime error: identifier not found: y\n", false},
+		{"parse error", []string{"run", "-no-cache", unparsable}, "", exitError, "",
+			unparsable + ":1:5: error: expected next token to be IDENT, got = instead\n", false},
+		{"vm expression", []string{"-vm", "-e", "let f = fn(x) { x * 2 }; f(21)"}, "", exitOK, "42\n", "", false},
+		{"vm script file", []string{"run", "-no-cache", "-vm", script, "world"}, "", exitOK, "hello world\n", "", false},
+		{"vm falls back to the interpreter", []string{"-vm", "-e", "let s = 0; for (x in [1, 2]) { s += x }; s"},
+			"", exitOK, "3\n", "", false},
+		{"vm runtime error", []string{"-vm", "-e", "1 + true"}, "", exitError, "",
+			"-: runtime error: type mismatch: INTEGER + BOOLEAN\n", false},
+		{"missing file", []string{"run", filepath.Join(dir, "nope.mk")}, "", exitError, "", "no such file", true},
+		{"missing script", []string{"run"}, "", exitUsage, "", "missing script file", true},
+		{"unknown command", []string{"walk"}, "", exitUsage, "", `unknown command "walk"`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			code := run(tt.argv, strings.NewReader(tt.stdin), &stdout, &stderr)
+			require.Equal(t, tt.code, code, stderr.String())
+			require.Equal(t, tt.stdout, stdout.String())
+			if tt.contains {
+				require.Contains(t, stderr.String(), tt.stderr)
+			} else {
+				require.Equal(t, tt.stderr, stderr.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,242 @@
+package compiler
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// Instructions is a flat sequence of encoded bytecode instructions
+type Instructions []byte
+
+type Opcode byte
+
+const (
+	OpConstant Opcode = iota
+	OpPop
+	OpTrue
+	OpFalse
+	OpNull
+
+	OpAdd
+	OpSub
+	OpMul
+	OpDiv
+	OpEqual
+	OpNotEqual
+	OpLessThan
+	OpGreaterThan
+	OpMod
+	OpLessEqual
+	OpGreaterEqual
+	OpBitAnd
+	OpBitOr
+	OpBitXor
+	OpShiftLeft
+	OpShiftRight
+	OpMinus
+	OpBang
+
+	OpJump
+	OpJumpNotTruthy
+	// OpJumpIfFalsyOrPop and OpJumpIfTruthyOrPop implement && and ||: they
+	// keep the operand that decides the result, otherwise pop it
+	OpJumpIfFalsyOrPop
+	OpJumpIfTruthyOrPop
+
+	OpGetGlobal
+	OpSetGlobal
+	OpGetLocal
+	OpSetLocal
+	OpGetBuiltin
+	OpGetFree
+	OpCurrentClosure
+
+	OpArray
+	OpHash
+	OpIndex
+	OpSlice
+	OpInterpolate
+
+	OpCall
+	OpReturnValue
+	OpReturn
+	OpClosure
+)
+
+// Definition describes an opcode for debugging and encoding
+type Definition struct {
+	Name          string
+	OperandWidths []int // width in bytes of each operand
+}
+
+var definitions = map[Opcode]*Definition{
+	OpConstant: {"OpConstant", []int{2}},
+	OpPop:      {"OpPop", []int{}},
+	OpTrue:     {"OpTrue", []int{}},
+	OpFalse:    {"OpFalse", []int{}},
+	OpNull:     {"OpNull", []int{}},
+
+	OpAdd:          {"OpAdd", []int{}},
+	OpSub:          {"OpSub", []int{}},
+	OpMul:          {"OpMul", []int{}},
+	OpDiv:          {"OpDiv", []int{}},
+	OpEqual:        {"OpEqual", []int{}},
+	OpNotEqual:     {"OpNotEqual", []int{}},
+	OpLessThan:     {"OpLessThan", []int{}},
+	OpGreaterThan:  {"OpGreaterThan", []int{}},
+	OpMod:          {"OpMod", []int{}},
+	OpLessEqual:    {"OpLessEqual", []int{}},
+	OpGreaterEqual: {"OpGreaterEqual", []int{}},
+	OpBitAnd:       {"OpBitAnd", []int{}},
+	OpBitOr:        {"OpBitOr", []int{}},
+	OpBitXor:       {"OpBitXor", []int{}},
+	OpShiftLeft:    {"OpShiftLeft", []int{}},
+	OpShiftRight:   {"OpShiftRight", []int{}},
+	OpMinus:        {"OpMinus", []int{}},
+	OpBang:         {"OpBang", []int{}},
+
+	OpJump:              {"OpJump", []int{2}},
+	OpJumpNotTruthy:     {"OpJumpNotTruthy", []int{2}},
+	OpJumpIfFalsyOrPop:  {"OpJumpIfFalsyOrPop", []int{2}},
+	OpJumpIfTruthyOrPop: {"OpJumpIfTruthyOrPop", []int{2}},
+
+	OpGetGlobal:      {"OpGetGlobal", []int{2}},
+	OpSetGlobal:      {"OpSetGlobal", []int{2}},
+	OpGetLocal:       {"OpGetLocal", []int{1}},
+	OpSetLocal:       {"OpSetLocal", []int{1}},
+	OpGetBuiltin:     {"OpGetBuiltin", []int{2}},
+	OpGetFree:        {"OpGetFree", []int{1}},
+	OpCurrentClosure: {"OpCurrentClosure", []int{}},
+
+	OpArray: {"OpArray", []int{2}},
+	OpHash:  {"OpHash", []int{2}}, // operand is the number of keys + values
+	OpIndex: {"OpIndex", []int{}},
+	OpSlice: {"OpSlice", []int{}}, // pops the high and low bounds, null if omitted
+	// operand is the number of strings and values to join
+	OpInterpolate: {"OpInterpolate", []int{2}},
+
+	OpCall:        {"OpCall", []int{1}}, // operand is the number of arguments
+	OpReturnValue: {"OpReturnValue", []int{}},
+	OpReturn:      {"OpReturn", []int{}},
+	OpClosure:     {"OpClosure", []int{2, 1}}, // constant index, number of free variables
+}
+
+// InfixOperators maps the binary opcodes back to their source operator,
+// so the VM can share error messages and semantics with Eval
+var InfixOperators = map[Opcode]string{
+	OpAdd:          "+",
+	OpSub:          "-",
+	OpMul:          "*",
+	OpDiv:          "/",
+	OpEqual:        "==",
+	OpNotEqual:     "!=",
+	OpLessThan:     "<",
+	OpGreaterThan:  ">",
+	OpMod:          "%",
+	OpLessEqual:    "<=",
+	OpGreaterEqual: ">=",
+	OpBitAnd:       "&",
+	OpBitOr:        "|",
+	OpBitXor:       "^",
+	OpShiftLeft:    "<<",
+	OpShiftRight:   ">>",
+}
+
+// infixOpcodes is the inverse of InfixOperators, used by the compiler
+var infixOpcodes = func() map[string]Opcode {
+	opcodes := make(map[string]Opcode, len(InfixOperators))
+	for op, operator := range InfixOperators {
+		opcodes[operator] = op
+	}
+	return opcodes
+}()
+
+func LookupOpcode(op byte) (*Definition, error) {
+	def, ok := definitions[Opcode(op)]
+	if !ok {
+		return nil, fmt.Errorf("opcode %d undefined", op)
+	}
+	return def, nil
+}
+
+// MakeInstruction encodes op and its operands, big endian
+func MakeInstruction(op Opcode, operands ...int) []byte {
+	def, ok := definitions[op]
+	if !ok {
+		return []byte{}
+	}
+	instructionLen := 1
+	for _, w := range def.OperandWidths {
+		instructionLen += w
+	}
+	instruction := make([]byte, instructionLen)
+	instruction[0] = byte(op)
+	offset := 1
+	for i, o := range operands {
+		width := def.OperandWidths[i]
+		switch width {
+		case 2:
+			binary.BigEndian.PutUint16(instruction[offset:], uint16(o))
+		case 1:
+			instruction[offset] = byte(o)
+		}
+		offset += width
+	}
+	return instruction
+}
+
+// ReadOperands decodes the operands of def from ins, returning them and
+// the number of bytes read
+func ReadOperands(def *Definition, ins Instructions) ([]int, int) {
+	operands := make([]int, len(def.OperandWidths))
+	offset := 0
+	for i, width := range def.OperandWidths {
+		switch width {
+		case 2:
+			operands[i] = int(ReadUint16(ins[offset:]))
+		case 1:
+			operands[i] = int(ReadUint8(ins[offset:]))
+		}
+		offset += width
+	}
+	return operands, offset
+}
+
+func ReadUint16(ins Instructions) uint16 { return binary.BigEndian.Uint16(ins) }
+func ReadUint8(ins Instructions) uint8   { return ins[0] }
+
+// String disassembles the instructions, one per line
+func (ins Instructions) String() string {
+	var out bytes.Buffer
+	i := 0
+	for i < len(ins) {
+		def, err := LookupOpcode(ins[i])
+		if err != nil {
+			fmt.Fprintf(&out, "ERROR: %s\n", err)
+			i++
+			continue
+		}
+		operands, read := ReadOperands(def, ins[i+1:])
+		fmt.Fprintf(&out, "%04d %s\n", i, ins.fmtInstruction(def, operands))
+		i += 1 + read
+	}
+	return out.String()
+}
+
+func (ins Instructions) fmtInstruction(def *Definition, operands []int) string {
+	operandCount := len(def.OperandWidths)
+	if len(operands) != operandCount {
+		return fmt.Sprintf("ERROR: operand len %d does not match defined %d\n",
+			len(operands), operandCount)
+	}
+	switch operandCount {
+	case 0:
+		return def.Name
+	case 1:
+		return fmt.Sprintf("%s %d", def.Name, operands[0])
+	case 2:
+		return fmt.Sprintf("%s %d %d", def.Name, operands[0], operands[1])
+	}
+	return fmt.Sprintf("ERROR: unhandled operandCount for %s\n", def.Name)
+}
@@ -0,0 +1,46 @@
+package compiler
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMakeInstruction(t *testing.T) {
+	tests := []struct {
+		op       Opcode
+		operands []int
+		expected []byte
+	}{
+		{OpConstant, []int{65534}, []byte{byte(OpConstant), 255, 254}},
+		{OpAdd, []int{}, []byte{byte(OpAdd)}},
+		{OpGetLocal, []int{255}, []byte{byte(OpGetLocal), 255}},
+		{OpClosure, []int{65534, 255}, []byte{byte(OpClosure), 255, 254, 255}},
+	}
+	for _, tt := range tests {
+		require.Equal(t, tt.expected, MakeInstruction(tt.op, tt.operands...))
+	}
+}
+
+func TestReadOperands(t *testing.T) {
+	ins := MakeInstruction(OpClosure, 65535, 255)
+	def, err := LookupOpcode(byte(OpClosure))
+	require.NoError(t, err)
+	operands, read := ReadOperands(def, ins[1:])
+	require.Equal(t, 3, read)
+	require.Equal(t, []int{65535, 255}, operands)
+}
+
+func TestInstructionsString(t *testing.T) {
+	var ins Instructions
+	ins = append(ins, MakeInstruction(OpAdd)...)
+	ins = append(ins, MakeInstruction(OpGetLocal, 1)...)
+	ins = append(ins, MakeInstruction(OpConstant, 2)...)
+	ins = append(ins, MakeInstruction(OpClosure, 65535, 255)...)
+	expected := `0000 OpAdd
+0001 OpGetLocal 1
+0003 OpConstant 2
+0006 OpClosure 65535 255
+`
+	require.Equal(t, expected, ins.String())
+}
@@ -0,0 +1,485 @@
+// Package compiler lowers parsed Monkey programs to bytecode for the VM in
+// package vm.
+//
+// The compiler covers the expression language: let and const, return,
+// functions and closures, conditionals, && and ||, arrays, hashes, indexing,
+// slicing and string interpolation. While and for loops, break, continue,
+// assignment, throw and try are left to the tree-walking interpreter,
+// compiling them fails with an error wrapping ErrUnsupported, so callers
+// can fall back to Interpreter.EvalProgram.
+package compiler
+
+import (
+	"errors"
+	"fmt"
+
+	monkey "monkey-interpreter"
+)
+
+// ErrUnsupported is wrapped by the errors for constructs the compiler
+// can't lower
+var ErrUnsupported = errors.New("unsupported by the bytecode VM")
+
+// Bytecode is the output of the compiler, ready to be run by the VM
+type Bytecode struct {
+	Instructions Instructions
+	Constants    []monkey.Object
+	// Builtins names the builtins by their OpGetBuiltin operand
+	Builtins []string
+}
+
+type EmittedInstruction struct {
+	Opcode   Opcode
+	Position int
+}
+
+// CompilationScope holds the instructions of the function being compiled
+type CompilationScope struct {
+	instructions        Instructions
+	lastInstruction     EmittedInstruction
+	previousInstruction EmittedInstruction
+}
+
+// Compiler lowers a parsed Program into Bytecode
+type Compiler struct {
+	constants   []monkey.Object
+	symbolTable *SymbolTable
+	builtins    []string
+
+	scopes     []CompilationScope
+	scopeIndex int
+}
+
+// New returns a compiler resolving the names in builtins, typically
+// Interpreter.BuiltinNames, to builtins
+func New(builtins []string) *Compiler {
+	symbolTable := NewSymbolTable()
+	for i, name := range builtins {
+		symbolTable.DefineBuiltin(i, name)
+	}
+	return &Compiler{
+		constants:   []monkey.Object{},
+		symbolTable: symbolTable,
+		builtins:    builtins,
+		scopes:      []CompilationScope{{instructions: Instructions{}}},
+	}
+}
+
+// SymbolTable returns the global symbol table, globals defined in it
+// before compiling are visible to the program
+func (c *Compiler) SymbolTable() *SymbolTable { return c.symbolTable }
+
+func (c *Compiler) Bytecode() *Bytecode {
+	return &Bytecode{
+		Instructions: c.currentInstructions(),
+		Constants:    c.constants,
+		Builtins:     c.builtins,
+	}
+}
+
+func (c *Compiler) Compile(node monkey.Node) error {
+	switch node := node.(type) {
+	case *monkey.Program:
+		for _, s := range node.Statements {
+			if err := c.Compile(s); err != nil {
+				return err
+			}
+		}
+	case *monkey.ExpressionStatement:
+		if err := c.Compile(node.Expression); err != nil {
+			return err
+		}
+		c.emit(OpPop)
+	case *monkey.BlockStatement:
+		for _, s := range node.Statements {
+			if err := c.Compile(s); err != nil {
+				return err
+			}
+		}
+	case *monkey.LetStatement:
+		return c.compileLetStatement(node)
+	case *monkey.ReturnStatement:
+		if err := c.Compile(node.ReturnValue); err != nil {
+			return err
+		}
+		c.emit(OpReturnValue)
+	case *monkey.Identifier:
+		symbol, ok := c.symbolTable.Resolve(node.Value)
+		if !ok {
+			return c.errorf(node, "identifier not found: %s", node.Value)
+		}
+		c.loadSymbol(symbol)
+
+	// Expressions
+	case *monkey.IntegerLiteral:
+		c.emit(OpConstant, c.addConstant(&monkey.Integer{Value: node.Value}))
+	case *monkey.BigIntegerLiteral:
+		c.emit(OpConstant, c.addConstant(&monkey.BigInteger{Value: node.Value}))
+	case *monkey.FloatLiteral:
+		c.emit(OpConstant, c.addConstant(&monkey.Float{Value: node.Value}))
+	case *monkey.StringLiteral:
+		c.emit(OpConstant, c.addConstant(&monkey.String{Value: node.Value}))
+	case *monkey.SliceExpression:
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+		for _, bound := range []monkey.Expression{node.Low, node.High} {
+			if bound == nil {
+				c.emit(OpNull)
+			} else if err := c.Compile(bound); err != nil {
+				return err
+			}
+		}
+		c.emit(OpSlice)
+	case *monkey.InterpolatedString:
+		c.emit(OpConstant, c.addConstant(&monkey.String{Value: node.Strings[0]}))
+		for i, exp := range node.Expressions {
+			if err := c.Compile(exp); err != nil {
+				return err
+			}
+			c.emit(OpConstant, c.addConstant(&monkey.String{Value: node.Strings[i+1]}))
+		}
+		c.emit(OpInterpolate, 2*len(node.Expressions)+1)
+	case *monkey.BooleanLiteral:
+		if node.Value {
+			c.emit(OpTrue)
+		} else {
+			c.emit(OpFalse)
+		}
+	case *monkey.PrefixExpression:
+		if err := c.Compile(node.Right); err != nil {
+			return err
+		}
+		switch node.Operator {
+		case "!":
+			c.emit(OpBang)
+		case "-":
+			c.emit(OpMinus)
+		default:
+			return c.errorf(node, "unknown operator %s", node.Operator)
+		}
+	case *monkey.InfixExpression:
+		return c.compileInfixExpression(node)
+	case *monkey.IfExpression:
+		return c.compileIfExpression(node)
+	case *monkey.ArrayLiteral:
+		for _, el := range node.Elements {
+			if err := c.Compile(el); err != nil {
+				return err
+			}
+		}
+		c.emit(OpArray, len(node.Elements))
+	case *monkey.HashLiteral:
+		return c.compileHashLiteral(node)
+	case *monkey.IndexExpression:
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+		if err := c.Compile(node.Index); err != nil {
+			return err
+		}
+		c.emit(OpIndex)
+	case *monkey.FunctionLiteral:
+		return c.compileFunctionLiteral(node, "")
+	case *monkey.CallExpression:
+		if err := c.Compile(node.Function); err != nil {
+			return err
+		}
+		for _, a := range node.Arguments {
+			if err := c.Compile(a); err != nil {
+				return err
+			}
+		}
+		c.emit(OpCall, len(node.Arguments))
+	default:
+		err := c.errorf(node, "the bytecode VM does not support %s", unsupportedConstruct(node))
+		err.Err = ErrUnsupported
+		return err
+	}
+	return nil
+}
+
+// unsupportedConstruct names node, which the compiler can't lower, for
+// the error reported to the user
+func unsupportedConstruct(node monkey.Node) string {
+	switch node.(type) {
+	case *monkey.WhileStatement:
+		return "while loops"
+	case *monkey.ForStatement:
+		return "for loops"
+	case *monkey.BreakStatement:
+		return "break"
+	case *monkey.ContinueStatement:
+		return "continue"
+	case *monkey.AssignExpression:
+		return "assignment"
+	case *monkey.ThrowStatement:
+		return "throw"
+	case *monkey.TryExpression:
+		return "try expressions"
+	}
+	return fmt.Sprintf("%q", node.TokenLiteral())
+}
+
+func (c *Compiler) compileLetStatement(node *monkey.LetStatement) error {
+	if c.symbolTable.IsConst(node.Name.Value) {
+		return c.errorf(node, "cannot redeclare constant: %s", node.Name.Value)
+	}
+	define := c.symbolTable.Define
+	if node.IsConst() {
+		define = c.symbolTable.DefineConst
+	}
+	fn, isFunction := node.Value.(*monkey.FunctionLiteral)
+	if !isFunction {
+		// the value can't see the binding it initialises
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		c.storeSymbol(define(node.Name.Value))
+		return nil
+	}
+	// define first, so later functions in the same scope can call this one
+	symbol := define(node.Name.Value)
+	if err := c.compileFunctionLiteral(fn, node.Name.Value); err != nil {
+		return err
+	}
+	c.storeSymbol(symbol)
+	return nil
+}
+
+func (c *Compiler) compileInfixExpression(node *monkey.InfixExpression) error {
+	if err := c.Compile(node.Left); err != nil {
+		return err
+	}
+	if node.Operator == "&&" || node.Operator == "||" {
+		return c.compileLogicalExpression(node)
+	}
+	if err := c.Compile(node.Right); err != nil {
+		return err
+	}
+	op, ok := infixOpcodes[node.Operator]
+	if !ok {
+		return c.errorf(node, "unknown operator %s", node.Operator)
+	}
+	c.emit(op)
+	return nil
+}
+
+// compileLogicalExpression compiles && and || once the left operand is on
+// the stack, the right one is skipped if the left one decides the result
+func (c *Compiler) compileLogicalExpression(node *monkey.InfixExpression) error {
+	op := OpJumpIfFalsyOrPop
+	if node.Operator == "||" {
+		op = OpJumpIfTruthyOrPop
+	}
+	// operand is patched once the right operand is compiled
+	jumpPos := c.emit(op, 9999)
+	if err := c.Compile(node.Right); err != nil {
+		return err
+	}
+	c.changeOperand(jumpPos, len(c.currentInstructions()))
+	return nil
+}
+
+func (c *Compiler) compileIfExpression(node *monkey.IfExpression) error {
+	if err := c.Compile(node.Condition); err != nil {
+		return err
+	}
+	// operand is patched once the consequence is compiled
+	jumpNotTruthyPos := c.emit(OpJumpNotTruthy, 9999)
+	if err := c.compileBlockValue(node.Consequence); err != nil {
+		return err
+	}
+	jumpPos := c.emit(OpJump, 9999)
+	c.changeOperand(jumpNotTruthyPos, len(c.currentInstructions()))
+
+	if node.Alternative == nil {
+		c.emit(OpNull)
+	} else if err := c.compileBlockValue(node.Alternative); err != nil {
+		return err
+	}
+	c.changeOperand(jumpPos, len(c.currentInstructions()))
+	return nil
+}
+
+// compileBlockValue compiles block so that it leaves its value on the stack
+func (c *Compiler) compileBlockValue(block *monkey.BlockStatement) error {
+	if err := c.Compile(block); err != nil {
+		return err
+	}
+	switch {
+	case c.lastInstructionIs(OpPop):
+		c.removeLastPop()
+	case !c.lastInstructionIs(OpReturnValue):
+		// empty block, or one ending in a let
+		c.emit(OpNull)
+	}
+	return nil
+}
+
+func (c *Compiler) compileHashLiteral(node *monkey.HashLiteral) error {
+	for _, pair := range node.Pairs {
+		if err := c.Compile(pair.Key); err != nil {
+			return err
+		}
+		if err := c.Compile(pair.Value); err != nil {
+			return err
+		}
+	}
+	c.emit(OpHash, len(node.Pairs)*2)
+	return nil
+}
+
+func (c *Compiler) compileFunctionLiteral(node *monkey.FunctionLiteral, name string) error {
+	c.enterScope()
+	if name != "" {
+		c.symbolTable.DefineFunctionName(name)
+	}
+	for _, p := range node.Parameters {
+		c.symbolTable.Define(p.Value)
+	}
+	if err := c.Compile(node.Body); err != nil {
+		return err
+	}
+	if c.lastInstructionIs(OpPop) {
+		c.replaceLastPopWithReturn()
+	}
+	if !c.lastInstructionIs(OpReturnValue) {
+		c.emit(OpReturn)
+	}
+
+	freeSymbols := c.symbolTable.FreeSymbols
+	numLocals := c.symbolTable.numDefinitions
+	instructions := c.leaveScope()
+
+	// push the captured values, so OpClosure can pack them up
+	for _, s := range freeSymbols {
+		c.loadSymbol(s)
+	}
+	compiledFn := &CompiledFunction{
+		Instructions:  instructions,
+		NumLocals:     numLocals,
+		NumParameters: len(node.Parameters),
+		Name:          name,
+	}
+	c.emit(OpClosure, c.addConstant(compiledFn), len(freeSymbols))
+	return nil
+}
+
+// errorf returns a compile error located at node. It uses the same *Error
+// as Eval, so both backends report problems the same way.
+func (c *Compiler) errorf(node monkey.Node, format string, a ...interface{}) *monkey.Error {
+	return &monkey.Error{Message: fmt.Sprintf(format, a...), Pos: node.Span().Start}
+}
+
+// helpers -------------------------------------------------------------------
+
+func (c *Compiler) loadSymbol(s Symbol) {
+	switch s.Scope {
+	case GlobalScope:
+		c.emit(OpGetGlobal, s.Index)
+	case LocalScope:
+		c.emit(OpGetLocal, s.Index)
+	case BuiltinScope:
+		c.emit(OpGetBuiltin, s.Index)
+	case FreeScope:
+		c.emit(OpGetFree, s.Index)
+	case FunctionScope:
+		c.emit(OpCurrentClosure)
+	}
+}
+
+func (c *Compiler) storeSymbol(s Symbol) {
+	if s.Scope == GlobalScope {
+		c.emit(OpSetGlobal, s.Index)
+	} else {
+		c.emit(OpSetLocal, s.Index)
+	}
+}
+
+func (c *Compiler) addConstant(obj monkey.Object) int {
+	c.constants = append(c.constants, obj)
+	return len(c.constants) - 1
+}
+
+// emit appends an instruction and returns its position
+func (c *Compiler) emit(op Opcode, operands ...int) int {
+	ins := MakeInstruction(op, operands...)
+	pos := c.addInstruction(ins)
+	c.setLastInstruction(op, pos)
+	return pos
+}
+
+func (c *Compiler) addInstruction(ins []byte) int {
+	posNewInstruction := len(c.currentInstructions())
+	c.scopes[c.scopeIndex].instructions = append(c.currentInstructions(), ins...)
+	return posNewInstruction
+}
+
+func (c *Compiler) setLastInstruction(op Opcode, pos int) {
+	previous := c.scopes[c.scopeIndex].lastInstruction
+	last := EmittedInstruction{Opcode: op, Position: pos}
+	c.scopes[c.scopeIndex].previousInstruction = previous
+	c.scopes[c.scopeIndex].lastInstruction = last
+}
+
+func (c *Compiler) currentInstructions() Instructions {
+	return c.scopes[c.scopeIndex].instructions
+}
+
+func (c *Compiler) lastInstructionIs(op Opcode) bool {
+	if len(c.currentInstructions()) == 0 {
+		return false
+	}
+	return c.scopes[c.scopeIndex].lastInstruction.Opcode == op
+}
+
+func (c *Compiler) removeLastPop() {
+	last := c.scopes[c.scopeIndex].lastInstruction
+	previous := c.scopes[c.scopeIndex].previousInstruction
+	c.scopes[c.scopeIndex].instructions = c.currentInstructions()[:last.Position]
+	c.scopes[c.scopeIndex].lastInstruction = previous
+}
+
+func (c *Compiler) replaceLastPopWithReturn() {
+	lastPos := c.scopes[c.scopeIndex].lastInstruction.Position
+	c.replaceInstruction(lastPos, MakeInstruction(OpReturnValue))
+	c.scopes[c.scopeIndex].lastInstruction.Opcode = OpReturnValue
+}
+
+func (c *Compiler) replaceInstruction(pos int, newInstruction []byte) {
+	ins := c.currentInstructions()
+	copy(ins[pos:], newInstruction)
+}
+
+func (c *Compiler) changeOperand(opPos int, operand int) {
+	op := Opcode(c.currentInstructions()[opPos])
+	c.replaceInstruction(opPos, MakeInstruction(op, operand))
+}
+
+func (c *Compiler) enterScope() {
+	c.scopes = append(c.scopes, CompilationScope{instructions: Instructions{}})
+	c.scopeIndex++
+	c.symbolTable = NewEnclosedSymbolTable(c.symbolTable)
+}
+
+func (c *Compiler) leaveScope() Instructions {
+	instructions := c.currentInstructions()
+	c.scopes = c.scopes[:len(c.scopes)-1]
+	c.scopeIndex--
+	c.symbolTable = c.symbolTable.Outer
+	return instructions
+}
+
+// CompiledFunction is a function literal lowered to bytecode
+type CompiledFunction struct {
+	Instructions  Instructions
+	NumLocals     int
+	NumParameters int
+	Name          string // name of the let binding, if any
+}
+
+func (cf *CompiledFunction) Type() monkey.ObjectType { return monkey.COMPILED_FUNCTION_OBJ_TYPE }
+func (cf *CompiledFunction) Inspect() string {
+	return fmt.Sprintf("CompiledFunction[%p]", cf)
+}
@@ -0,0 +1,168 @@
+package compiler
+
+import (
+	"errors"
+	"testing"
+
+	monkey "monkey-interpreter"
+
+	"github.com/stretchr/testify/require"
+)
+
+func parse(t *testing.T, input string) *monkey.Program {
+	p := monkey.NewParser(monkey.NewLexer(input))
+	program := p.ParseProgram()
+	require.Empty(t, p.Errors())
+	return program
+}
+
+func concatInstructions(s ...[]byte) Instructions {
+	out := Instructions{}
+	for _, ins := range s {
+		out = append(out, ins...)
+	}
+	return out
+}
+
+func TestCompileExpressions(t *testing.T) {
+	tests := []struct {
+		name         string
+		input        string
+		constants    []interface{}
+		instructions Instructions
+	}{
+		{
+			"infix",
+			"1 + 2; 3 < 4",
+			[]interface{}{1, 2, 3, 4},
+			concatInstructions(
+				MakeInstruction(OpConstant, 0),
+				MakeInstruction(OpConstant, 1),
+				MakeInstruction(OpAdd),
+				MakeInstruction(OpPop),
+				MakeInstruction(OpConstant, 2),
+				MakeInstruction(OpConstant, 3),
+				MakeInstruction(OpLessThan),
+				MakeInstruction(OpPop),
+			),
+		},
+		{
+			"logical",
+			"1 && 2 || 3",
+			[]interface{}{1, 2, 3},
+			concatInstructions(
+				MakeInstruction(OpConstant, 0),
+				MakeInstruction(OpJumpIfFalsyOrPop, 9),
+				MakeInstruction(OpConstant, 1),
+				MakeInstruction(OpJumpIfTruthyOrPop, 15),
+				MakeInstruction(OpConstant, 2),
+				MakeInstruction(OpPop),
+			),
+		},
+		{
+			"conditional without alternative",
+			"if (true) { 10 }; 3333;",
+			[]interface{}{10, 3333},
+			concatInstructions(
+				MakeInstruction(OpTrue),
+				MakeInstruction(OpJumpNotTruthy, 10),
+				MakeInstruction(OpConstant, 0),
+				MakeInstruction(OpJump, 11),
+				MakeInstruction(OpNull),
+				MakeInstruction(OpPop),
+				MakeInstruction(OpConstant, 1),
+				MakeInstruction(OpPop),
+			),
+		},
+		{
+			"globals",
+			"let one = 1; one;",
+			[]interface{}{1},
+			concatInstructions(
+				MakeInstruction(OpConstant, 0),
+				MakeInstruction(OpSetGlobal, 0),
+				MakeInstruction(OpGetGlobal, 0),
+				MakeInstruction(OpPop),
+			),
+		},
+		{
+			"builtins",
+			`len([]);`,
+			[]interface{}{},
+			concatInstructions(
+				MakeInstruction(OpGetBuiltin, 1),
+				MakeInstruction(OpArray, 0),
+				MakeInstruction(OpCall, 1),
+				MakeInstruction(OpPop),
+			),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New([]string{"first", "len"})
+			require.NoError(t, c.Compile(parse(t, tt.input)))
+			bytecode := c.Bytecode()
+			require.Equal(t, tt.instructions.String(), bytecode.Instructions.String())
+			require.Len(t, bytecode.Constants, len(tt.constants))
+			for i, constant := range tt.constants {
+				require.Equal(t, &monkey.Integer{Value: int64(constant.(int))}, bytecode.Constants[i])
+			}
+		})
+	}
+}
+
+func TestCompileClosures(t *testing.T) {
+	input := "fn(a) { fn(b) { a + b } }"
+	c := New(nil)
+	require.NoError(t, c.Compile(parse(t, input)))
+	constants := c.Bytecode().Constants
+	require.Len(t, constants, 2)
+
+	inner := constants[0].(*CompiledFunction)
+	require.Equal(t, concatInstructions(
+		MakeInstruction(OpGetFree, 0),
+		MakeInstruction(OpGetLocal, 0),
+		MakeInstruction(OpAdd),
+		MakeInstruction(OpReturnValue),
+	).String(), inner.Instructions.String())
+
+	outer := constants[1].(*CompiledFunction)
+	require.Equal(t, concatInstructions(
+		MakeInstruction(OpGetLocal, 0),
+		MakeInstruction(OpClosure, 0, 1),
+		MakeInstruction(OpReturnValue),
+	).String(), outer.Instructions.String())
+}
+
+func TestSymbolTableResolveFree(t *testing.T) {
+	global := NewSymbolTable()
+	global.Define("a")
+	first := NewEnclosedSymbolTable(global)
+	first.Define("b")
+	second := NewEnclosedSymbolTable(first)
+	second.Define("c")
+
+	expected := map[string]Symbol{
+		"a": {Name: "a", Scope: GlobalScope, Index: 0},
+		"b": {Name: "b", Scope: FreeScope, Index: 0},
+		"c": {Name: "c", Scope: LocalScope, Index: 0},
+	}
+	for name, sym := range expected {
+		got, ok := second.Resolve(name)
+		require.True(t, ok)
+		require.Equal(t, sym, got)
+	}
+	require.Equal(t, []Symbol{{Name: "b", Scope: LocalScope, Index: 0}}, second.FreeSymbols)
+	_, ok := second.Resolve("d")
+	require.False(t, ok)
+}
+
+func TestCompilerErrors(t *testing.T) {
+	err := New(nil).Compile(parse(t, "let a = 1;\nb;"))
+	require.EqualError(t, err, "2:1: identifier not found: b")
+	require.False(t, errors.Is(err, ErrUnsupported))
+
+	err = New(nil).Compile(parse(t, "try { 1 } catch (e) { 2 }"))
+	require.EqualError(t, err, "1:1: the bytecode VM does not support try expressions")
+	require.True(t, errors.Is(err, ErrUnsupported))
+}
@@ -0,0 +1,548 @@
+package monkey_interpreter
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// maxConvertDepth guards against cyclic Go values
+const maxConvertDepth = 100
+
+var (
+	objectType = reflect.TypeOf((*Object)(nil)).Elem()
+	errorType  = reflect.TypeOf((*error)(nil)).Elem()
+	bigIntType = reflect.TypeOf(big.Int{})
+)
+
+// ToObject converts a Go value to a Monkey Object:
+//
+//	nil, nil pointers, maps and slices -> NULL
+//	bool                               -> BOOLEAN
+//	int*, uint*, big.Int               -> INTEGER
+//	float*                             -> FLOAT
+//	string                             -> STRING
+//	slices and arrays                  -> ARRAY
+//	maps                               -> HASH, keys must be hashable
+//	structs                            -> HASH keyed by field name, or the
+//	                                      name in a `monkey:"name"` tag;
+//	                                      `monkey:"-"` skips the field
+//	funcs                              -> BUILTIN, see WrapFunction
+//
+// Pointers are followed, Objects are returned as they are.
+func ToObject(v any) (Object, error) {
+	return toObject(reflect.ValueOf(v), "", 0)
+}
+
+func toObject(v reflect.Value, path string, depth int) (Object, error) {
+	if depth > maxConvertDepth {
+		return nil, convertErrorf(path, "value is nested too deeply")
+	}
+	if !v.IsValid() {
+		return NULL_OBJ, nil
+	}
+	if v.Type().Implements(objectType) {
+		if v.Kind() == reflect.Interface || v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return NULL_OBJ, nil
+			}
+		}
+		return v.Interface().(Object), nil
+	}
+	if v.Type() == bigIntType {
+		x := v.Interface().(big.Int)
+		return newInteger(new(big.Int).Set(&x)), nil
+	}
+	switch v.Kind() {
+	case reflect.Interface, reflect.Ptr:
+		if v.IsNil() {
+			return NULL_OBJ, nil
+		}
+		return toObject(v.Elem(), path, depth+1)
+	case reflect.Bool:
+		return nativeBoolToBooleanObject(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &Integer{Value: v.Int()}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if v.Uint() > math.MaxInt64 {
+			return &BigInteger{Value: new(big.Int).SetUint64(v.Uint())}, nil
+		}
+		return &Integer{Value: int64(v.Uint())}, nil
+	case reflect.Float32, reflect.Float64:
+		return &Float{Value: v.Float()}, nil
+	case reflect.String:
+		return &String{Value: v.String()}, nil
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return NULL_OBJ, nil
+		}
+		elements := make([]Object, v.Len())
+		for i := range elements {
+			el, err := toObject(v.Index(i), fmt.Sprintf("%s[%d]", path, i), depth+1)
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = el
+		}
+		return NewArray(elements), nil
+	case reflect.Map:
+		if v.IsNil() {
+			return NULL_OBJ, nil
+		}
+		pairs := make([]HashPair, 0, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			keyPath := fmt.Sprintf("%s[%v]", path, iter.Key().Interface())
+			key, err := toObject(iter.Key(), keyPath, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			if _, err := hashableKey(key); err != nil {
+				return nil, convertErrorf(keyPath, "%s", err.Message)
+			}
+			value, err := toObject(iter.Value(), keyPath, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			pairs = append(pairs, HashPair{Key: key, Value: value})
+		}
+		// Go maps are unordered, sorting the keys keeps the hash deterministic
+		sort.Slice(pairs, func(i, j int) bool { return lessHashKey(pairs[i].Key, pairs[j].Key) })
+		hash := NewHash(len(pairs))
+		for _, pair := range pairs {
+			hash.Set(pair.Key.(Hashable), pair.Value)
+		}
+		return hash, nil
+	case reflect.Struct:
+		hash := NewHash(v.NumField())
+		for _, f := range structFields(v.Type()) {
+			fieldPath := path + "." + f.name
+			value, err := toObject(v.Field(f.index), fieldPath, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			key := &String{Value: f.name}
+			hash.Set(key, value)
+		}
+		return hash, nil
+	case reflect.Func:
+		if v.IsNil() {
+			return NULL_OBJ, nil
+		}
+		b, err := WrapFunction(v.Interface())
+		if err != nil {
+			return nil, convertErrorf(path, "%s", err)
+		}
+		return b, nil
+	default:
+		return nil, convertErrorf(path, "cannot convert %s to an Object", v.Type())
+	}
+}
+
+// FromObject stores obj in the Go value target points to, converting it
+// the opposite way to ToObject. Targets of type any receive int64 (or
+// *big.Int beyond its range), float64, string, bool, nil, []any, and
+// map[string]any for hashes with only string keys or map[any]any
+// otherwise; targets implementing Object receive obj itself. Integers may
+// be stored in floats. Mutable arrays convert like arrays.
+func FromObject(obj Object, target any) error {
+	v := reflect.ValueOf(target)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return fmt.Errorf("FromObject target must be a non-nil pointer, got %T", target)
+	}
+	return fromObject(obj, v.Elem(), "")
+}
+
+func fromObject(obj Object, v reflect.Value, path string) error {
+	t := v.Type()
+	if obj == nil {
+		return convertErrorf(path, "cannot convert a nil Object to %s", t)
+	}
+	isEmptyInterface := t.Kind() == reflect.Interface && t.NumMethod() == 0
+	if !isEmptyInterface && reflect.TypeOf(obj).AssignableTo(t) {
+		v.Set(reflect.ValueOf(obj))
+		return nil
+	}
+	if obj == NULL_OBJ {
+		switch t.Kind() {
+		case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice, reflect.Func:
+			v.Set(reflect.Zero(t))
+			return nil
+		}
+	}
+	mismatch := func() error {
+		return convertErrorf(path, "cannot convert %s to %s", obj.Type(), t)
+	}
+
+	switch t.Kind() {
+	case reflect.Interface:
+		if !isEmptyInterface {
+			return mismatch()
+		}
+		native, err := toNative(obj, path)
+		if err != nil {
+			return err
+		}
+		if native == nil {
+			v.Set(reflect.Zero(t))
+		} else {
+			v.Set(reflect.ValueOf(native))
+		}
+	case reflect.Ptr:
+		elem := reflect.New(t.Elem())
+		if err := fromObject(obj, elem.Elem(), path); err != nil {
+			return err
+		}
+		v.Set(elem)
+	case reflect.Bool:
+		b, ok := obj.(*BooleanObject)
+		if !ok {
+			return mismatch()
+		}
+		v.SetBool(b.Value)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, ok := obj.(*Integer)
+		if !ok {
+			return integerMismatch(obj, t, path)
+		}
+		if v.OverflowInt(i.Value) {
+			return convertErrorf(path, "%d overflows %s", i.Value, t)
+		}
+		v.SetInt(i.Value)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		var u uint64
+		switch i := obj.(type) {
+		case *Integer:
+			if i.Value < 0 {
+				return convertErrorf(path, "%d overflows %s", i.Value, t)
+			}
+			u = uint64(i.Value)
+		case *BigInteger:
+			// beyond int64, but possibly within uint64
+			if !i.Value.IsUint64() {
+				return convertErrorf(path, "%s overflows %s", i.Value, t)
+			}
+			u = i.Value.Uint64()
+		default:
+			return integerMismatch(obj, t, path)
+		}
+		if v.OverflowUint(u) {
+			return convertErrorf(path, "%d overflows %s", u, t)
+		}
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		// integers are promoted, as in arithmetic
+		if !isNumber(obj) {
+			return mismatch()
+		}
+		v.SetFloat(toFloat(obj))
+	case reflect.String:
+		s, ok := obj.(*String)
+		if !ok {
+			return mismatch()
+		}
+		v.SetString(s.Value)
+	case reflect.Slice:
+		elements, ok := arrayElements(obj)
+		if !ok {
+			return mismatch()
+		}
+		slice := reflect.MakeSlice(t, len(elements), len(elements))
+		for i, el := range elements {
+			if err := fromObject(el, slice.Index(i), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+	case reflect.Array:
+		elements, ok := arrayElements(obj)
+		if !ok {
+			return mismatch()
+		}
+		if len(elements) != t.Len() {
+			return convertErrorf(path, "cannot convert %s of length %d to %s", obj.Type(), len(elements), t)
+		}
+		for i, el := range elements {
+			if err := fromObject(el, v.Index(i), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		hash, ok := obj.(*Hash)
+		if !ok {
+			return mismatch()
+		}
+		m := reflect.MakeMapWithSize(t, hash.Len())
+		for _, pair := range hash.Pairs() {
+			keyPath := fmt.Sprintf("%s[%s]", path, pair.Key.Inspect())
+			key := reflect.New(t.Key()).Elem()
+			if err := fromObject(pair.Key, key, keyPath); err != nil {
+				return err
+			}
+			value := reflect.New(t.Elem()).Elem()
+			if err := fromObject(pair.Value, value, keyPath); err != nil {
+				return err
+			}
+			m.SetMapIndex(key, value)
+		}
+		v.Set(m)
+	case reflect.Struct:
+		if t == bigIntType {
+			if obj.Type() != INT_OBJ_TYPE {
+				return mismatch()
+			}
+			v.Set(reflect.ValueOf(new(big.Int).Set(toBig(obj))).Elem())
+			return nil
+		}
+		hash, ok := obj.(*Hash)
+		if !ok {
+			return mismatch()
+		}
+		for _, f := range structFields(t) {
+			key := &String{Value: f.name}
+			pair, ok := hash.Get(key)
+			if !ok {
+				continue
+			}
+			if err := fromObject(pair.Value, v.Field(f.index), path+"."+f.name); err != nil {
+				return err
+			}
+		}
+	default:
+		return mismatch()
+	}
+	return nil
+}
+
+// arrayElements returns the elements of obj if it is an array, mutable or
+// not
+func arrayElements(obj Object) ([]Object, bool) {
+	switch obj := obj.(type) {
+	case *Array:
+		return obj.Elements(), true
+	case *MutableArray:
+		return obj.Elements, true
+	}
+	return nil, false
+}
+
+// integerMismatch is the error for storing a non *Integer obj in an integer
+// of type t
+func integerMismatch(obj Object, t reflect.Type, path string) error {
+	if obj, ok := obj.(*BigInteger); ok {
+		return convertErrorf(path, "%s overflows %s", obj.Inspect(), t)
+	}
+	return convertErrorf(path, "cannot convert %s to %s", obj.Type(), t)
+}
+
+// toNative converts obj to the natural Go type for an `any` target
+func toNative(obj Object, path string) (any, error) {
+	switch obj := obj.(type) {
+	case *Null:
+		return nil, nil
+	case *Integer:
+		return obj.Value, nil
+	case *BigInteger:
+		return new(big.Int).Set(obj.Value), nil
+	case *Float:
+		return obj.Value, nil
+	case *String:
+		return obj.Value, nil
+	case *BooleanObject:
+		return obj.Value, nil
+	case *Array, *MutableArray:
+		elements, _ := arrayElements(obj)
+		out := make([]any, len(elements))
+		for i, el := range elements {
+			native, err := toNative(el, fmt.Sprintf("%s[%d]", path, i))
+			if err != nil {
+				return nil, err
+			}
+			out[i] = native
+		}
+		return out, nil
+	case *Hash:
+		allStrings := true
+		for _, pair := range obj.Pairs() {
+			if _, ok := pair.Key.(*String); !ok {
+				allStrings = false
+				break
+			}
+		}
+		stringKeyed := make(map[string]any, obj.Len())
+		anyKeyed := make(map[any]any, obj.Len())
+		for _, pair := range obj.Pairs() {
+			keyPath := fmt.Sprintf("%s[%s]", path, pair.Key.Inspect())
+			value, err := toNative(pair.Value, keyPath)
+			if err != nil {
+				return nil, err
+			}
+			if allStrings {
+				stringKeyed[pair.Key.(*String).Value] = value
+				continue
+			}
+			if _, ok := pair.Key.(*Array); ok {
+				// slices aren't comparable, so can't key a Go map
+				return nil, convertErrorf(keyPath, "cannot use ARRAY as a map key")
+			}
+			key, err := toNative(pair.Key, keyPath)
+			if err != nil {
+				return nil, err
+			}
+			anyKeyed[key] = value
+		}
+		if allStrings {
+			return stringKeyed, nil
+		}
+		return anyKeyed, nil
+	default:
+		// functions and the like have no Go equivalent, hand over the Object
+		return obj, nil
+	}
+}
+
+type structField struct {
+	name  string
+	index int
+}
+
+// structFields lists the exported fields of t with their Monkey names
+func structFields(t reflect.Type) []structField {
+	var fields []structField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name := f.Name
+		if tag, ok := f.Tag.Lookup("monkey"); ok {
+			tag, _, _ = strings.Cut(tag, ",")
+			if tag == "-" {
+				continue
+			}
+			if tag != "" {
+				name = tag
+			}
+		}
+		fields = append(fields, structField{name: name, index: i})
+	}
+	return fields
+}
+
+func convertErrorf(path, format string, a ...any) error {
+	msg := fmt.Sprintf(format, a...)
+	if path == "" {
+		return fmt.Errorf("%s", msg)
+	}
+	return fmt.Errorf("%s: %s", strings.TrimPrefix(path, "."), msg)
+}
+
+// WrapFunction turns a Go function into a *Builtin. Arguments are converted
+// with FromObject and results with ToObject, so fn can take and return any
+// convertible types, e.g. func(int, string) (bool, error). A trailing error
+// result that is not nil becomes an *Error. Variadic functions are
+// supported. Calls with the wrong number or types of arguments return an
+// *Error instead of calling fn.
+func WrapFunction(fn any) (*Builtin, error) {
+	if fn == nil {
+		return nil, fmt.Errorf("WrapFunction: fn is nil")
+	}
+	v := reflect.ValueOf(fn)
+	t := v.Type()
+	if t.Kind() != reflect.Func {
+		return nil, fmt.Errorf("WrapFunction: %T is not a function", fn)
+	}
+	if v.IsNil() {
+		return nil, fmt.Errorf("WrapFunction: fn is a nil %s", t)
+	}
+	returnsError := t.NumOut() > 0 && t.Out(t.NumOut()-1) == errorType
+	numResults := t.NumOut()
+	if returnsError {
+		numResults--
+	}
+	if numResults > 1 {
+		return nil, fmt.Errorf("WrapFunction: %s returns more than one value", t)
+	}
+
+	numFixed := t.NumIn()
+	if t.IsVariadic() {
+		numFixed--
+	}
+	return &Builtin{Fn: func(args ...Object) Object {
+		if len(args) < numFixed || (!t.IsVariadic() && len(args) != numFixed) {
+			want := fmt.Sprintf("%d", numFixed)
+			if t.IsVariadic() {
+				want = fmt.Sprintf("%d or more", numFixed)
+			}
+			return newError("wrong number of arguments. got=%d, want=%s", len(args), want)
+		}
+		in := make([]reflect.Value, len(args))
+		for i, arg := range args {
+			var paramType reflect.Type
+			if t.IsVariadic() && i >= numFixed {
+				paramType = t.In(numFixed).Elem()
+			} else {
+				paramType = t.In(i)
+			}
+			param := reflect.New(paramType).Elem()
+			if err := fromObject(arg, param, ""); err != nil {
+				return newError("argument %d: %s", i+1, err)
+			}
+			in[i] = param
+		}
+		out := v.Call(in)
+		if returnsError {
+			if err, _ := out[len(out)-1].Interface().(error); err != nil {
+				return newError("%s", err)
+			}
+		}
+		if numResults == 0 {
+			return NULL_OBJ
+		}
+		result, err := toObject(out[0], "", 0)
+		if err != nil {
+			return newError("result: %s", err)
+		}
+		return result
+	}}, nil
+}
+
+// RegisterFunc wraps fn with WrapFunction and registers it as name
+func (i *Interpreter) RegisterFunc(name string, fn any) error {
+	b, err := WrapFunction(fn)
+	if err != nil {
+		return err
+	}
+	i.builtins[name] = b
+	return nil
+}
+
+// lessHashKey orders hash keys by type, then by value
+func lessHashKey(a, b Object) bool {
+	if a.Type() != b.Type() {
+		return a.Type() < b.Type()
+	}
+	switch a := a.(type) {
+	case *String:
+		return a.Value < b.(*String).Value
+	case *BooleanObject:
+		return !a.Value && b.(*BooleanObject).Value
+	case *Integer, *BigInteger:
+		return toBig(a).Cmp(toBig(b)) < 0
+	case *Array:
+		b := b.(*Array)
+		for i := 0; i < a.Len() && i < b.Len(); i++ {
+			if lessHashKey(a.Get(i), b.Get(i)) {
+				return true
+			}
+			if lessHashKey(b.Get(i), a.Get(i)) {
+				return false
+			}
+		}
+		return a.Len() < b.Len()
+	}
+	return false
+}
@@ -0,0 +1,246 @@
+package monkey_interpreter
+
+import (
+	"errors"
+	"math"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type convertAddress struct {
+	City string `monkey:"city"`
+	Zip  int
+}
+
+type convertPerson struct {
+	Name    string `monkey:"name"`
+	Age     int    `monkey:"age"`
+	Tags    []string
+	Address *convertAddress
+	Secret  string `monkey:"-"`
+	private int
+}
+
+func TestToObject(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    any
+		expected string
+	}{
+		{"nil", nil, "null"},
+		{"bool", true, "true"},
+		{"int", 42, "42"},
+		{"uint8", uint8(7), "7"},
+		{"huge uint64", uint64(math.MaxUint64), "18446744073709551615"},
+		{"float", float32(1.5), "1.5"},
+		{"big int", new(big.Int).Lsh(big.NewInt(1), 70), "1180591620717411303424"},
+		{"small big int", *big.NewInt(5), "5"},
+		{"string", "hi", "hi"},
+		{"slice", []int{1, 2, 3}, "[1, 2, 3]"},
+		{"array", [2]string{"a", "b"}, "[a, b]"},
+		{"nil slice", []int(nil), "null"},
+		{"nested", [][]bool{{true}, {}}, "[[true], []]"},
+		{"map", map[string]int{"one": 1}, "{one: 1}"},
+		{"sorted map", map[string]int{"c": 3, "a": 1, "b": 2}, "{a: 1, b: 2, c: 3}"},
+		{"sorted int keys", map[int]bool{10: true, 9: false, -1: true}, "{-1: true, 9: false, 10: true}"},
+		{"pointer", &[]int{1}, "[1]"},
+		{"nil pointer", (*int)(nil), "null"},
+		{"object", &Integer{Value: 3}, "3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obj, err := ToObject(tt.input)
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, obj.Inspect())
+		})
+	}
+}
+
+func TestToObjectStruct(t *testing.T) {
+	obj, err := ToObject(convertPerson{
+		Name: "Ada", Age: 36, Tags: []string{"math"},
+		Address: &convertAddress{City: "London", Zip: 1},
+		Secret:  "hidden", private: 1,
+	})
+	require.NoError(t, err)
+	hash, ok := obj.(*Hash)
+	require.True(t, ok)
+	require.Equal(t, 4, hash.Len())
+	get := func(h *Hash, key string) Object {
+		pair, _ := h.Get(&String{Value: key})
+		return pair.Value
+	}
+	require.Equal(t, "Ada", get(hash, "name").Inspect())
+	require.Equal(t, "36", get(hash, "age").Inspect())
+	require.Equal(t, "[math]", get(hash, "Tags").Inspect())
+	address := get(hash, "Address").(*Hash)
+	require.Equal(t, "London", get(address, "city").Inspect())
+}
+
+func TestToObjectErrors(t *testing.T) {
+	_, err := ToObject(map[string][]chan int{"c": {make(chan int)}})
+	require.EqualError(t, err, "[c][0]: cannot convert chan int to an Object")
+}
+
+func TestFromObject(t *testing.T) {
+	interp := NewInterpreter()
+	obj, err := interp.Eval(`{"name": "Ada", "age": 36, "Tags": ["a", "b"], "Address": {"city": "London"}, "extra": 1}`)
+	require.NoError(t, err)
+
+	var person convertPerson
+	require.NoError(t, FromObject(obj, &person))
+	require.Equal(t, convertPerson{
+		Name: "Ada", Age: 36, Tags: []string{"a", "b"},
+		Address: &convertAddress{City: "London"},
+	}, person)
+
+	var native any
+	require.NoError(t, FromObject(obj, &native))
+	require.Equal(t, map[string]any{
+		"name": "Ada", "age": int64(36), "Tags": []any{"a", "b"},
+		"Address": map[string]any{"city": "London"}, "extra": int64(1),
+	}, native)
+
+	var ints map[int]bool
+	obj, err = interp.Eval(`{1: true, 2: false}`)
+	require.NoError(t, err)
+	require.NoError(t, FromObject(obj, &ints))
+	require.Equal(t, map[int]bool{1: true, 2: false}, ints)
+
+	var floats []float64
+	obj, err = interp.Eval(`[1, 2.5]`)
+	require.NoError(t, err)
+	require.NoError(t, FromObject(obj, &floats))
+	require.Equal(t, []float64{1, 2.5}, floats)
+
+	// mutable arrays convert like arrays
+	obj, err = interp.Eval(`let m = mutable([1, 2]); append(m, 3)`)
+	require.NoError(t, err)
+	var ints3 []int
+	require.NoError(t, FromObject(obj, &ints3))
+	require.Equal(t, []int{1, 2, 3}, ints3)
+	var fixed [3]int
+	require.NoError(t, FromObject(obj, &fixed))
+	require.Equal(t, [3]int{1, 2, 3}, fixed)
+	native = nil
+	require.NoError(t, FromObject(obj, &native))
+	require.Equal(t, []any{int64(1), int64(2), int64(3)}, native)
+	var same *MutableArray
+	require.NoError(t, FromObject(obj, &same))
+	require.Same(t, obj, same)
+
+	var bigInt *big.Int
+	obj, err = interp.Eval(`9223372036854775807 * 4`)
+	require.NoError(t, err)
+	require.NoError(t, FromObject(obj, &bigInt))
+	require.Equal(t, "36893488147419103228", bigInt.String())
+
+	// integers beyond int64 still fit unsigned targets
+	var u64 uint64
+	require.NoError(t, FromObject(&BigInteger{Value: new(big.Int).Lsh(big.NewInt(1), 63)}, &u64))
+	require.Equal(t, uint64(1<<63), u64)
+	obj, err = ToObject(uint64(math.MaxUint64))
+	require.NoError(t, err)
+	require.NoError(t, FromObject(obj, &u64))
+	require.Equal(t, uint64(math.MaxUint64), u64)
+
+	var ptr *int
+	require.NoError(t, FromObject(NULL_OBJ, &ptr))
+	require.Nil(t, ptr)
+
+	var fn Object
+	obj, err = interp.Eval(`fn(x) { x }`)
+	require.NoError(t, err)
+	require.NoError(t, FromObject(obj, &fn))
+	require.Equal(t, obj, fn)
+}
+
+func TestFromObjectErrors(t *testing.T) {
+	var huge int64
+	require.EqualError(t, FromObject(&BigInteger{Value: new(big.Int).Lsh(big.NewInt(1), 70)}, &huge),
+		"1180591620717411303424 overflows int64")
+	var small int8
+	require.EqualError(t, FromObject(&Integer{Value: 300}, &small), "300 overflows int8")
+	var u uint
+	require.EqualError(t, FromObject(&Integer{Value: -1}, &u), "-1 overflows uint")
+	require.EqualError(t, FromObject(&BigInteger{Value: new(big.Int).Lsh(big.NewInt(1), 64)}, &u),
+		"18446744073709551616 overflows uint")
+	var u32 uint32
+	require.EqualError(t, FromObject(&BigInteger{Value: new(big.Int).Lsh(big.NewInt(1), 63)}, &u32),
+		"9223372036854775808 overflows uint32")
+	var list []int
+	arr := NewArray([]Object{&Integer{Value: 1}, &String{Value: "x"}})
+	require.EqualError(t, FromObject(arr, &list), "[1]: cannot convert STRING to int")
+	var s string
+	require.EqualError(t, FromObject(NULL_OBJ, &s), "cannot convert NULL to string")
+	require.Error(t, FromObject(arr, list))
+	var x int
+	require.EqualError(t, FromObject(nil, &x), "cannot convert a nil Object to int")
+	require.EqualError(t, FromObject(NewArray([]Object{nil}), &list), "[0]: cannot convert a nil Object to int")
+}
+
+func TestWrapFunction(t *testing.T) {
+	interp := NewInterpreter()
+	require.NoError(t, interp.RegisterFunc("repeat", func(n int, s string) (string, error) {
+		if n < 0 {
+			return "", errors.New("negative count")
+		}
+		return strings.Repeat(s, n), nil
+	}))
+	require.NoError(t, interp.RegisterFunc("sum", func(nums ...int) int {
+		total := 0
+		for _, n := range nums {
+			total += n
+		}
+		return total
+	}))
+	require.NoError(t, interp.RegisterFunc("noop", func() {}))
+	require.NoError(t, interp.RegisterFunc("person", func() convertPerson {
+		return convertPerson{Name: "Bob"}
+	}))
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`repeat(3, "ab")`, "ababab"},
+		{`sum()`, "0"},
+		{`sum(1, 2, 3)`, "6"},
+		{`noop()`, "null"},
+		{`person()["name"]`, "Bob"},
+	}
+	for _, tt := range tests {
+		result, err := interp.Eval(tt.input)
+		require.NoError(t, err, tt.input)
+		require.Equal(t, tt.expected, result.Inspect(), tt.input)
+	}
+
+	errorTests := []struct {
+		input    string
+		expected string
+	}{
+		{`repeat(-1, "ab")`, "negative count"},
+		{`repeat(1)`, "wrong number of arguments. got=1, want=2"},
+		{`repeat("1", "ab")`, "argument 1: cannot convert STRING to int"},
+		{`sum(1, true)`, "argument 2: cannot convert BOOLEAN to int"},
+	}
+	for _, tt := range errorTests {
+		_, err := interp.Eval(tt.input)
+		errObj, ok := err.(*Error)
+		require.True(t, ok, tt.input)
+		require.Equal(t, tt.expected, errObj.Message, tt.input)
+	}
+
+	_, err := WrapFunction(42)
+	require.EqualError(t, err, "WrapFunction: int is not a function")
+	_, err = WrapFunction(func() (int, int) { return 1, 2 })
+	require.EqualError(t, err, "WrapFunction: func() (int, int) returns more than one value")
+	_, err = WrapFunction(nil)
+	require.EqualError(t, err, "WrapFunction: fn is nil")
+	var nilFunc func(int) int
+	_, err = WrapFunction(nilFunc)
+	require.EqualError(t, err, "WrapFunction: fn is a nil func(int) int")
+}
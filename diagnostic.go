@@ -0,0 +1,53 @@
+package monkey_interpreter
+
+import (
+	"bytes"
+	"fmt"
+)
+
+type Severity int
+
+const (
+	SeverityError Severity = iota
+	SeverityWarning
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityError:
+		return "error"
+	case SeverityWarning:
+		return "warning"
+	default:
+		return fmt.Sprintf("severity(%d)", int(s))
+	}
+}
+
+// Diagnostic is a problem found in the source, e.g. a syntax error
+type Diagnostic struct {
+	Severity Severity
+	Message  string
+	Span     Span
+	Expected TokenType // the token the parser wanted, if any
+	Actual   TokenType // the token the parser found, if any
+	Hint     string    // optional suggestion on how to fix the problem
+}
+
+// String formats the diagnostic as file:line:column: severity: message
+func (d Diagnostic) String() string {
+	var out bytes.Buffer
+	out.WriteString(d.Span.Start.String())
+	out.WriteString(": ")
+	out.WriteString(d.Severity.String())
+	out.WriteString(": ")
+	out.WriteString(d.Message)
+	if d.Hint != "" {
+		out.WriteString(" (hint: ")
+		out.WriteString(d.Hint)
+		out.WriteString(")")
+	}
+	return out.String()
+}
+
+// Error lets a Diagnostic be returned where a Go error is expected
+func (d Diagnostic) Error() string { return d.String() }
@@ -0,0 +1,722 @@
+package monkey_interpreter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"math"
+	"math/big"
+)
+
+// The binary AST format is
+//
+//	magic "MKAST" | version uint16 | crc32 of payload uint32 | payload
+//
+// The payload is the filename followed by the program's statements. Every
+// node is a tag byte followed by its token and children; strings are
+// interned, so repeated token types and identifiers cost a single uvarint.
+const (
+	astMagic   = "MKAST"
+	ASTVersion = 8
+)
+
+var (
+	ErrBadMagic = errors.New("not an encoded monkey program")
+	ErrVersion  = errors.New("unsupported encoded program version")
+	ErrChecksum = errors.New("encoded program is corrupted")
+
+	errTruncated      = errors.New("encoded program is truncated")
+	errDecodeTooDeep  = errors.New("encoded program is nested too deeply")
+	errUnknownNodeTag = errors.New("encoded program contains an unknown node")
+	errBadBigInteger  = errors.New("encoded program contains a malformed integer")
+)
+
+var (
+	astHeaderLen     = len(astMagic) + 2 + 4
+	astByteOrder     = binary.BigEndian
+	astChecksumTable = crc32.MakeTable(crc32.Castagnoli)
+)
+
+// maxDecodeNesting bounds recursion, so hostile input can't blow the stack
+const maxDecodeNesting = 10000
+
+// node tags, never reorder, append only and bump ASTVersion on changes
+const (
+	tagNil byte = iota
+	tagLetStatement
+	tagReturnStatement
+	tagExpressionStatement
+	tagBlockStatement
+	tagIdentifier
+	tagIntegerLiteral
+	tagStringLiteral
+	tagBooleanLiteral
+	tagPrefixExpression
+	tagInfixExpression
+	tagIfExpression
+	tagFunctionLiteral
+	tagCallExpression
+	tagArrayLiteral
+	tagIndexExpression
+	tagHashLiteral
+	tagThrowStatement
+	tagTryExpression
+	tagFloatLiteral
+	tagBigIntegerLiteral
+	tagWhileStatement
+	tagForStatement
+	tagBreakStatement
+	tagContinueStatement
+	tagAssignExpression
+	tagInterpolatedString
+	tagSliceExpression
+)
+
+// EncodeProgram serialises a parsed program, including source positions
+func EncodeProgram(program *Program) ([]byte, error) {
+	e := &astEncoder{strings: map[string]uint64{}}
+	e.filename = programFilename(program)
+	e.string(e.filename)
+	e.uvarint(uint64(len(program.Statements)))
+	for _, s := range program.Statements {
+		if err := e.node(s); err != nil {
+			return nil, err
+		}
+	}
+	payload := e.buf.Bytes()
+
+	out := make([]byte, astHeaderLen, astHeaderLen+len(payload))
+	copy(out, astMagic)
+	astByteOrder.PutUint16(out[len(astMagic):], ASTVersion)
+	astByteOrder.PutUint32(out[len(astMagic)+2:], crc32.Checksum(payload, astChecksumTable))
+	return append(out, payload...), nil
+}
+
+// DecodeProgram is the inverse of EncodeProgram. It fails with ErrBadMagic,
+// ErrVersion or ErrChecksum if data wasn't written by this version.
+func DecodeProgram(data []byte) (*Program, error) {
+	if len(data) < astHeaderLen || string(data[:len(astMagic)]) != astMagic {
+		return nil, ErrBadMagic
+	}
+	if v := astByteOrder.Uint16(data[len(astMagic):]); v != ASTVersion {
+		return nil, fmt.Errorf("%w: got %d, want %d", ErrVersion, v, ASTVersion)
+	}
+	payload := data[astHeaderLen:]
+	if astByteOrder.Uint32(data[len(astMagic)+2:]) != crc32.Checksum(payload, astChecksumTable) {
+		return nil, ErrChecksum
+	}
+
+	d := &astDecoder{data: payload}
+	d.filename = d.string()
+	n := d.length()
+	program := &Program{Statements: make([]Statement, 0, n)}
+	for i := 0; i < n && d.err == nil; i++ {
+		stmt, ok := d.node().(Statement)
+		if !ok {
+			d.fail(errUnknownNodeTag)
+			break
+		}
+		program.Statements = append(program.Statements, stmt)
+	}
+	if d.err == nil && len(d.data) != 0 {
+		d.fail(fmt.Errorf("%d trailing bytes after encoded program", len(d.data)))
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	return program, nil
+}
+
+func programFilename(program *Program) string {
+	if len(program.Statements) == 0 {
+		return ""
+	}
+	return program.Span().Start.Filename
+}
+
+// encoder -------------------------------------------------------------------
+
+type astEncoder struct {
+	buf      bytes.Buffer
+	strings  map[string]uint64
+	filename string
+}
+
+func (e *astEncoder) uvarint(v uint64) {
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(b[:], v)
+	e.buf.Write(b[:n])
+}
+
+func (e *astEncoder) varint(v int64) {
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutVarint(b[:], v)
+	e.buf.Write(b[:n])
+}
+
+// string writes the index of s in the string table, followed by s itself
+// the first time it is seen
+func (e *astEncoder) string(s string) {
+	if idx, ok := e.strings[s]; ok {
+		e.uvarint(idx)
+		return
+	}
+	idx := uint64(len(e.strings))
+	e.strings[s] = idx
+	e.uvarint(idx)
+	e.uvarint(uint64(len(s)))
+	e.buf.WriteString(s)
+}
+
+func (e *astEncoder) position(p Position) {
+	e.uvarint(uint64(p.Offset))
+	e.uvarint(uint64(p.Line))
+	e.uvarint(uint64(p.Column))
+}
+
+func (e *astEncoder) token(t Token) {
+	e.string(string(t.Type))
+	e.string(t.Literal)
+	e.position(t.Span.Start)
+	e.position(t.Span.End)
+}
+
+func (e *astEncoder) nodes(list []Expression) error {
+	e.uvarint(uint64(len(list)))
+	for _, n := range list {
+		if err := e.node(n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *astEncoder) node(node Node) error {
+	switch n := node.(type) {
+	case nil:
+		e.buf.WriteByte(tagNil)
+	case *LetStatement:
+		e.buf.WriteByte(tagLetStatement)
+		e.token(n.Token)
+		if err := e.node(n.Name); err != nil {
+			return err
+		}
+		return e.node(n.Value)
+	case *ReturnStatement:
+		e.buf.WriteByte(tagReturnStatement)
+		e.token(n.Token)
+		return e.node(n.ReturnValue)
+	case *ThrowStatement:
+		e.buf.WriteByte(tagThrowStatement)
+		e.token(n.Token)
+		return e.node(n.Value)
+	case *WhileStatement:
+		e.buf.WriteByte(tagWhileStatement)
+		e.token(n.Token)
+		if err := e.node(n.Condition); err != nil {
+			return err
+		}
+		return e.optionalBlock(n.Body)
+	case *ForStatement:
+		e.buf.WriteByte(tagForStatement)
+		e.token(n.Token)
+		if n.Key == nil {
+			e.buf.WriteByte(tagNil)
+		} else if err := e.node(n.Key); err != nil {
+			return err
+		}
+		if err := e.node(n.Value); err != nil {
+			return err
+		}
+		if err := e.node(n.Iterable); err != nil {
+			return err
+		}
+		return e.optionalBlock(n.Body)
+	case *BreakStatement:
+		e.buf.WriteByte(tagBreakStatement)
+		e.token(n.Token)
+	case *ContinueStatement:
+		e.buf.WriteByte(tagContinueStatement)
+		e.token(n.Token)
+	case *ExpressionStatement:
+		e.buf.WriteByte(tagExpressionStatement)
+		e.token(n.Token)
+		return e.node(n.Expression)
+	case *BlockStatement:
+		e.buf.WriteByte(tagBlockStatement)
+		e.token(n.Token)
+		e.token(n.Close)
+		e.uvarint(uint64(len(n.Statements)))
+		for _, s := range n.Statements {
+			if err := e.node(s); err != nil {
+				return err
+			}
+		}
+	case *Identifier:
+		e.buf.WriteByte(tagIdentifier)
+		e.token(n.Token)
+		e.string(n.Value)
+	case *IntegerLiteral:
+		e.buf.WriteByte(tagIntegerLiteral)
+		e.token(n.Token)
+		e.varint(n.Value)
+	case *BigIntegerLiteral:
+		e.buf.WriteByte(tagBigIntegerLiteral)
+		e.token(n.Token)
+		e.string(n.Value.String())
+	case *FloatLiteral:
+		e.buf.WriteByte(tagFloatLiteral)
+		e.token(n.Token)
+		e.uvarint(math.Float64bits(n.Value))
+	case *StringLiteral:
+		e.buf.WriteByte(tagStringLiteral)
+		e.token(n.Token)
+		e.string(n.Value)
+	case *SliceExpression:
+		e.buf.WriteByte(tagSliceExpression)
+		e.token(n.Token)
+		e.token(n.Close)
+		for _, child := range []Node{n.Left, n.Low, n.High} {
+			if err := e.node(child); err != nil {
+				return err
+			}
+		}
+	case *InterpolatedString:
+		e.buf.WriteByte(tagInterpolatedString)
+		e.token(n.Token)
+		e.token(n.Close)
+		e.uvarint(uint64(len(n.Expressions)))
+		e.string(n.Strings[0])
+		for i, exp := range n.Expressions {
+			if err := e.node(exp); err != nil {
+				return err
+			}
+			e.string(n.Strings[i+1])
+		}
+	case *BooleanLiteral:
+		e.buf.WriteByte(tagBooleanLiteral)
+		e.token(n.Token)
+		e.bool(n.Value)
+	case *PrefixExpression:
+		e.buf.WriteByte(tagPrefixExpression)
+		e.token(n.Token)
+		e.string(n.Operator)
+		return e.node(n.Right)
+	case *InfixExpression:
+		e.buf.WriteByte(tagInfixExpression)
+		e.token(n.Token)
+		e.string(n.Operator)
+		if err := e.node(n.Left); err != nil {
+			return err
+		}
+		return e.node(n.Right)
+	case *AssignExpression:
+		e.buf.WriteByte(tagAssignExpression)
+		e.token(n.Token)
+		e.string(n.Operator)
+		if err := e.node(n.Target); err != nil {
+			return err
+		}
+		return e.node(n.Value)
+	case *IfExpression:
+		e.buf.WriteByte(tagIfExpression)
+		e.token(n.Token)
+		if err := e.node(n.Condition); err != nil {
+			return err
+		}
+		if err := e.optionalBlock(n.Consequence); err != nil {
+			return err
+		}
+		return e.optionalBlock(n.Alternative)
+	case *TryExpression:
+		e.buf.WriteByte(tagTryExpression)
+		e.token(n.Token)
+		if err := e.optionalBlock(n.Block); err != nil {
+			return err
+		}
+		if n.CatchParam == nil {
+			e.buf.WriteByte(tagNil)
+		} else if err := e.node(n.CatchParam); err != nil {
+			return err
+		}
+		if err := e.optionalBlock(n.Catch); err != nil {
+			return err
+		}
+		return e.optionalBlock(n.Finally)
+	case *FunctionLiteral:
+		e.buf.WriteByte(tagFunctionLiteral)
+		e.token(n.Token)
+		e.uvarint(uint64(len(n.Parameters)))
+		for _, p := range n.Parameters {
+			if err := e.node(p); err != nil {
+				return err
+			}
+		}
+		return e.optionalBlock(n.Body)
+	case *CallExpression:
+		e.buf.WriteByte(tagCallExpression)
+		e.token(n.Token)
+		e.token(n.Close)
+		if err := e.node(n.Function); err != nil {
+			return err
+		}
+		return e.nodes(n.Arguments)
+	case *ArrayLiteral:
+		e.buf.WriteByte(tagArrayLiteral)
+		e.token(n.Token)
+		e.token(n.Close)
+		return e.nodes(n.Elements)
+	case *IndexExpression:
+		e.buf.WriteByte(tagIndexExpression)
+		e.token(n.Token)
+		e.token(n.Close)
+		if err := e.node(n.Left); err != nil {
+			return err
+		}
+		return e.node(n.Index)
+	case *HashLiteral:
+		e.buf.WriteByte(tagHashLiteral)
+		e.token(n.Token)
+		e.token(n.Close)
+		e.uvarint(uint64(len(n.Pairs)))
+		for _, pair := range n.Pairs {
+			if err := e.node(pair.Key); err != nil {
+				return err
+			}
+			if err := e.node(pair.Value); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("cannot encode node %T", node)
+	}
+	return nil
+}
+
+// optionalBlock encodes a block that may be missing, without writing a
+// typed nil pointer as a real node
+func (e *astEncoder) optionalBlock(b *BlockStatement) error {
+	if b == nil {
+		return e.node(nil)
+	}
+	return e.node(b)
+}
+
+func (e *astEncoder) bool(b bool) {
+	if b {
+		e.buf.WriteByte(1)
+	} else {
+		e.buf.WriteByte(0)
+	}
+}
+
+// decoder -------------------------------------------------------------------
+
+// astDecoder reads nodes from data. The first error is kept in err, after
+// which every read returns zero values, so callers only check err at the end.
+type astDecoder struct {
+	data     []byte
+	strings  []string
+	filename string
+	depth    int
+	err      error
+}
+
+func (d *astDecoder) fail(err error) {
+	if d.err == nil {
+		d.err = err
+	}
+	d.data = nil
+}
+
+func (d *astDecoder) uvarint() uint64 {
+	v, n := binary.Uvarint(d.data)
+	if n <= 0 {
+		d.fail(errTruncated)
+		return 0
+	}
+	d.data = d.data[n:]
+	return v
+}
+
+func (d *astDecoder) varint() int64 {
+	v, n := binary.Varint(d.data)
+	if n <= 0 {
+		d.fail(errTruncated)
+		return 0
+	}
+	d.data = d.data[n:]
+	return v
+}
+
+// length reads a count of following items, each of which takes at least a
+// byte, so a corrupted count can't make us allocate huge slices
+func (d *astDecoder) length() int {
+	n := d.uvarint()
+	if n > uint64(len(d.data)) {
+		d.fail(errTruncated)
+		return 0
+	}
+	return int(n)
+}
+
+func (d *astDecoder) byte() byte {
+	if len(d.data) == 0 {
+		d.fail(errTruncated)
+		return 0
+	}
+	b := d.data[0]
+	d.data = d.data[1:]
+	return b
+}
+
+func (d *astDecoder) bool() bool { return d.byte() != 0 }
+
+func (d *astDecoder) string() string {
+	idx := d.uvarint()
+	if idx < uint64(len(d.strings)) {
+		return d.strings[idx]
+	}
+	if idx != uint64(len(d.strings)) {
+		d.fail(fmt.Errorf("bad string table index %d", idx))
+		return ""
+	}
+	n := d.uvarint()
+	if n > uint64(len(d.data)) {
+		d.fail(errTruncated)
+		return ""
+	}
+	s := string(d.data[:n])
+	d.data = d.data[n:]
+	d.strings = append(d.strings, s)
+	return s
+}
+
+func (d *astDecoder) position() Position {
+	return Position{
+		Filename: d.filename,
+		Offset:   int(d.uvarint()),
+		Line:     int(d.uvarint()),
+		Column:   int(d.uvarint()),
+	}
+}
+
+func (d *astDecoder) token() Token {
+	t := Token{Type: TokenType(d.string()), Literal: d.string()}
+	t.Span.Start = d.position()
+	t.Span.End = d.position()
+	if !t.Span.Start.IsValid() {
+		// a zero token, e.g. the Close of a block that was never closed
+		return Token{Type: t.Type, Literal: t.Literal}
+	}
+	return t
+}
+
+func (d *astDecoder) expression() Expression {
+	n := d.node()
+	if n == nil {
+		return nil
+	}
+	exp, ok := n.(Expression)
+	if !ok {
+		d.fail(errUnknownNodeTag)
+		return nil
+	}
+	return exp
+}
+
+func (d *astDecoder) expressions() []Expression {
+	n := d.length()
+	if n == 0 {
+		// the parser leaves empty lists nil
+		return nil
+	}
+	list := make([]Expression, 0, n)
+	for i := 0; i < n && d.err == nil; i++ {
+		list = append(list, d.expression())
+	}
+	return list
+}
+
+func (d *astDecoder) identifier() *Identifier {
+	ident, ok := d.node().(*Identifier)
+	if !ok {
+		d.fail(errUnknownNodeTag)
+	}
+	return ident
+}
+
+func (d *astDecoder) block() *BlockStatement {
+	n := d.node()
+	if n == nil {
+		return nil
+	}
+	block, ok := n.(*BlockStatement)
+	if !ok {
+		d.fail(errUnknownNodeTag)
+	}
+	return block
+}
+
+func (d *astDecoder) node() Node {
+	if d.depth >= maxDecodeNesting {
+		d.fail(errDecodeTooDeep)
+		return nil
+	}
+	d.depth++
+	defer func() { d.depth-- }()
+
+	switch tag := d.byte(); tag {
+	case tagNil:
+		return nil
+	case tagLetStatement:
+		n := &LetStatement{Token: d.token()}
+		n.Name = d.identifier()
+		n.Value = d.expression()
+		return n
+	case tagReturnStatement:
+		return &ReturnStatement{Token: d.token(), ReturnValue: d.expression()}
+	case tagThrowStatement:
+		return &ThrowStatement{Token: d.token(), Value: d.expression()}
+	case tagWhileStatement:
+		n := &WhileStatement{Token: d.token()}
+		n.Condition = d.expression()
+		n.Body = d.block()
+		return n
+	case tagForStatement:
+		n := &ForStatement{Token: d.token()}
+		if key := d.node(); key != nil {
+			ident, ok := key.(*Identifier)
+			if !ok {
+				d.fail(errUnknownNodeTag)
+			}
+			n.Key = ident
+		}
+		n.Value = d.identifier()
+		n.Iterable = d.expression()
+		n.Body = d.block()
+		return n
+	case tagBreakStatement:
+		return &BreakStatement{Token: d.token()}
+	case tagContinueStatement:
+		return &ContinueStatement{Token: d.token()}
+	case tagExpressionStatement:
+		return &ExpressionStatement{Token: d.token(), Expression: d.expression()}
+	case tagBlockStatement:
+		n := &BlockStatement{Token: d.token(), Close: d.token()}
+		count := d.length()
+		n.Statements = make([]Statement, 0, count)
+		for i := 0; i < count && d.err == nil; i++ {
+			stmt, ok := d.node().(Statement)
+			if !ok {
+				d.fail(errUnknownNodeTag)
+				return nil
+			}
+			n.Statements = append(n.Statements, stmt)
+		}
+		return n
+	case tagIdentifier:
+		return &Identifier{Token: d.token(), Value: d.string()}
+	case tagIntegerLiteral:
+		return &IntegerLiteral{Token: d.token(), Value: d.varint()}
+	case tagBigIntegerLiteral:
+		n := &BigIntegerLiteral{Token: d.token()}
+		value, ok := new(big.Int).SetString(d.string(), 10)
+		if !ok {
+			d.fail(errBadBigInteger)
+			return nil
+		}
+		n.Value = value
+		return n
+	case tagFloatLiteral:
+		return &FloatLiteral{Token: d.token(), Value: math.Float64frombits(d.uvarint())}
+	case tagStringLiteral:
+		return &StringLiteral{Token: d.token(), Value: d.string()}
+	case tagSliceExpression:
+		n := &SliceExpression{Token: d.token(), Close: d.token()}
+		n.Left = d.expression()
+		n.Low = d.expression()
+		n.High = d.expression()
+		return n
+	case tagInterpolatedString:
+		n := &InterpolatedString{Token: d.token(), Close: d.token()}
+		count := d.length()
+		n.Strings = []string{d.string()}
+		for i := 0; i < count && d.err == nil; i++ {
+			n.Expressions = append(n.Expressions, d.expression())
+			n.Strings = append(n.Strings, d.string())
+		}
+		return n
+	case tagBooleanLiteral:
+		return &BooleanLiteral{Token: d.token(), Value: d.bool()}
+	case tagPrefixExpression:
+		n := &PrefixExpression{Token: d.token(), Operator: d.string()}
+		n.Right = d.expression()
+		return n
+	case tagInfixExpression:
+		n := &InfixExpression{Token: d.token(), Operator: d.string()}
+		n.Left = d.expression()
+		n.Right = d.expression()
+		return n
+	case tagAssignExpression:
+		n := &AssignExpression{Token: d.token(), Operator: d.string()}
+		n.Target = d.expression()
+		n.Value = d.expression()
+		return n
+	case tagIfExpression:
+		n := &IfExpression{Token: d.token()}
+		n.Condition = d.expression()
+		n.Consequence = d.block()
+		n.Alternative = d.block()
+		return n
+	case tagTryExpression:
+		n := &TryExpression{Token: d.token()}
+		n.Block = d.block()
+		if param := d.node(); param != nil {
+			ident, ok := param.(*Identifier)
+			if !ok {
+				d.fail(errUnknownNodeTag)
+			}
+			n.CatchParam = ident
+		}
+		n.Catch = d.block()
+		n.Finally = d.block()
+		return n
+	case tagFunctionLiteral:
+		n := &FunctionLiteral{Token: d.token()}
+		count := d.length()
+		for i := 0; i < count && d.err == nil; i++ {
+			n.Parameters = append(n.Parameters, d.identifier())
+		}
+		n.Body = d.block()
+		return n
+	case tagCallExpression:
+		n := &CallExpression{Token: d.token(), Close: d.token()}
+		n.Function = d.expression()
+		n.Arguments = d.expressions()
+		return n
+	case tagArrayLiteral:
+		n := &ArrayLiteral{Token: d.token(), Close: d.token()}
+		n.Elements = d.expressions()
+		return n
+	case tagIndexExpression:
+		n := &IndexExpression{Token: d.token(), Close: d.token()}
+		n.Left = d.expression()
+		n.Index = d.expression()
+		return n
+	case tagHashLiteral:
+		n := &HashLiteral{Token: d.token(), Close: d.token()}
+		count := d.length()
+		n.Pairs = make([]HashLiteralPair, 0, count)
+		for i := 0; i < count && d.err == nil; i++ {
+			key := d.expression()
+			n.Pairs = append(n.Pairs, HashLiteralPair{Key: key, Value: d.expression()})
+		}
+		return n
+	default:
+		d.fail(fmt.Errorf("%w: tag %d", errUnknownNodeTag, tag))
+		return nil
+	}
+}
@@ -0,0 +1,122 @@
+package monkey_interpreter
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const encodingInput = `let add = fn(x, y) { x + y; };
+let result = add(5, -10);
+let arr = [1, "two", true, add, 2.5, 123456789012345678901234567890];
+let h = {"one": 1, 2: arr[0], false: !true};
+let safe = try { throw error("x", arr); } catch (e) { error_payload(e) } finally { puts("done") };
+try { safe } catch { 0 };
+if (result < 0) { return h["one"]; } else { puts("ok") }
+fn() {}();
+while (false) { break; }
+for (k, v in h) { if (v) { continue } }
+for (x in arr) { x };
+const limit = 3;
+let n = 0;
+n += limit;
+arr[0] = h["one"] = n;
+let msg = "n=${n}\t${h["one"] + 1}";
+let naïve = msg[1:][:2] + arr[:1][0:];
+`
+
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	p := NewParser(NewFileLexer("enc.mk", encodingInput))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	data, err := EncodeProgram(program)
+	require.NoError(t, err)
+	decoded, err := DecodeProgram(data)
+	require.NoError(t, err)
+
+	require.Equal(t, program.String(), decoded.String())
+	require.Len(t, decoded.Statements, len(program.Statements))
+	for i := range program.Statements {
+		require.Equal(t, program.Statements[i].Span(), decoded.Statements[i].Span())
+	}
+	// the decoded program must evaluate the same
+	require.Equal(t,
+		Eval(program, NewEnvironment()).Inspect(),
+		Eval(decoded, NewEnvironment()).Inspect())
+
+	// encoding must be deterministic
+	again, err := EncodeProgram(decoded)
+	require.NoError(t, err)
+	require.Equal(t, data, again)
+}
+
+func TestDecodeProgramRejectsBadInput(t *testing.T) {
+	program := NewParser(NewLexer("let a = 1; a + 2;")).ParseProgram()
+	data, err := EncodeProgram(program)
+	require.NoError(t, err)
+
+	badMagic := append([]byte{}, data...)
+	badMagic[0] = 'X'
+	_, err = DecodeProgram(badMagic)
+	require.True(t, errors.Is(err, ErrBadMagic))
+
+	stale := append([]byte{}, data...)
+	stale[len(astMagic)+1]++
+	_, err = DecodeProgram(stale)
+	require.True(t, errors.Is(err, ErrVersion))
+
+	corrupted := append([]byte{}, data...)
+	corrupted[len(corrupted)-1] ^= 0xff
+	_, err = DecodeProgram(corrupted)
+	require.True(t, errors.Is(err, ErrChecksum))
+
+	_, err = DecodeProgram(data[:3])
+	require.True(t, errors.Is(err, ErrBadMagic))
+}
+
+func TestParseCache(t *testing.T) {
+	dir := t.TempDir()
+	cache := NewParseCache(dir)
+
+	program, diagnostics := cache.Parse("a.mk", encodingInput)
+	require.Empty(t, diagnostics)
+	entries, err := filepath.Glob(filepath.Join(dir, "*.mkast"))
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+
+	cached, diagnostics := cache.Parse("a.mk", encodingInput)
+	require.Empty(t, diagnostics)
+	requireSameProgram(t, program, cached)
+	require.Equal(t, "a.mk:1:1", cached.Span().Start.String())
+
+	// a corrupted entry is re-parsed and replaced
+	require.NoError(t, os.WriteFile(entries[0], []byte("MKAST garbage"), 0o644))
+	reparsed, diagnostics := cache.Parse("a.mk", encodingInput)
+	require.Empty(t, diagnostics)
+	requireSameProgram(t, program, reparsed)
+	data, err := os.ReadFile(entries[0])
+	require.NoError(t, err)
+	_, err = DecodeProgram(data)
+	require.NoError(t, err)
+
+	// programs with errors are reported and not cached
+	_, diagnostics = cache.Parse("b.mk", "let = 1;")
+	require.Len(t, diagnostics, 1)
+	entries, err = filepath.Glob(filepath.Join(dir, "*.mkast"))
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+}
+
+// requireSameProgram compares programs by their encoding, which unlike
+// String() covers positions too
+func requireSameProgram(t *testing.T, expected, actual *Program) {
+	expectedData, err := EncodeProgram(expected)
+	require.NoError(t, err)
+	actualData, err := EncodeProgram(actual)
+	require.NoError(t, err)
+	require.Equal(t, expectedData, actualData)
+}
@@ -1,18 +1,31 @@
 package monkey_interpreter
 
+import (
+	"errors"
+	"sort"
+)
+
+var (
+	ErrUndeclared = errors.New("assignment to undeclared variable")
+	ErrConstant   = errors.New("assignment to constant")
+)
+
 type Environment struct {
 	store map[string]Object
-	outer *Environment
+	// consts holds the names in store declared with const, nil if none
+	consts map[string]bool
+	outer  *Environment
+	// interp owns the environment chain. Standalone environments get a bare
+	// one, which uses the default builtins and only tracks the call depth.
+	interp *Interpreter
 }
 
 func NewEnvironment() *Environment {
-	return &Environment{store: map[string]Object{}}
+	return &Environment{store: map[string]Object{}, interp: &Interpreter{}}
 }
 
 func NewEnclosedEnvironment(outer *Environment) *Environment {
-	env := NewEnvironment()
-	env.outer = outer
-	return env
+	return &Environment{store: map[string]Object{}, outer: outer, interp: outer.interp}
 }
 
 func (e *Environment) Get(name string) (Object, bool) {
@@ -23,7 +36,48 @@ func (e *Environment) Get(name string) (Object, bool) {
 	return obj, ok
 }
 
+// Set binds name in e, replacing any binding of name in e itself
 func (e *Environment) Set(name string, val Object) Object {
 	e.store[name] = val
+	delete(e.consts, name)
+	return val
+}
+
+// SetConst is like Set, but the binding can't be assigned to
+func (e *Environment) SetConst(name string, val Object) Object {
+	e.store[name] = val
+	if e.consts == nil {
+		e.consts = map[string]bool{}
+	}
+	e.consts[name] = true
 	return val
 }
+
+// Assign updates the nearest binding of name, in e or an enclosing scope.
+// It fails with ErrUndeclared or ErrConstant.
+func (e *Environment) Assign(name string, val Object) error {
+	for scope := e; scope != nil; scope = scope.outer {
+		if _, ok := scope.store[name]; !ok {
+			continue
+		}
+		if scope.consts[name] {
+			return ErrConstant
+		}
+		scope.store[name] = val
+		return nil
+	}
+	return ErrUndeclared
+}
+
+// IsConst reports whether name is bound in e itself by SetConst
+func (e *Environment) IsConst(name string) bool { return e.consts[name] }
+
+// Names returns the names bound directly in e, not in enclosing scopes, sorted
+func (e *Environment) Names() []string {
+	names := make([]string, 0, len(e.store))
+	for name := range e.store {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,29 @@
+package monkey_interpreter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnvironmentAssign(t *testing.T) {
+	outer := NewEnvironment()
+	outer.Set("x", &Integer{Value: 1})
+	outer.SetConst("c", &Integer{Value: 2})
+	inner := NewEnclosedEnvironment(outer)
+
+	require.NoError(t, inner.Assign("x", &Integer{Value: 3}))
+	x, _ := outer.Get("x")
+	testIntegerObject(t, x, 3)
+	_, ok := inner.store["x"]
+	require.False(t, ok, "assignment must not bind in the inner scope")
+
+	require.ErrorIs(t, inner.Assign("c", &Integer{Value: 4}), ErrConstant)
+	require.ErrorIs(t, inner.Assign("y", &Integer{Value: 4}), ErrUndeclared)
+
+	// Set replaces a constant
+	require.True(t, outer.IsConst("c"))
+	outer.Set("c", &Integer{Value: 5})
+	require.False(t, outer.IsConst("c"))
+	require.NoError(t, inner.Assign("c", &Integer{Value: 6}))
+}
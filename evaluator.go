@@ -2,15 +2,37 @@ package monkey_interpreter
 
 import (
 	"fmt"
+	"math"
+	"math/big"
+	"strings"
+	"unicode/utf8"
 )
 
 var (
 	NULL_OBJ  = &Null{}
 	FALSE_OBJ = &BooleanObject{Value: false}
 	TRUE_OBJ  = &BooleanObject{Value: true}
+
+	BREAK_OBJ    = &Break{}
+	CONTINUE_OBJ = &Continue{}
 )
 
+// Eval evaluates node in env. Errors raised while evaluating node are
+// stamped with the position of the innermost node that produced them.
 func Eval(node Node, env *Environment) Object {
+	if err := env.interp.step(); err != nil {
+		err.Pos = node.Span().Start
+		return err
+	}
+	result := evalNode(node, env)
+	if err, ok := result.(*Error); ok && !err.Pos.IsValid() {
+		err.Pos = node.Span().Start
+		err.Stack = env.interp.stackTrace(err.Pos)
+	}
+	return result
+}
+
+func evalNode(node Node, env *Environment) Object {
 	switch currNode := node.(type) {
 	// Statements
 	case *Program:
@@ -25,21 +47,51 @@ func Eval(node Node, env *Environment) Object {
 		val := Eval(currNode.ReturnValue, env)
 		return &ReturnValue{Value: val}
 	case *LetStatement:
+		if env.IsConst(currNode.Name.Value) {
+			return newError("cannot redeclare constant: %s", currNode.Name.Value)
+		}
 		// evaluate let expression
 		val := Eval(currNode.Value, env)
 		// if there is an error evaluating expression, return the error
 		if isError(val) {
 			return val
 		}
-		env.Set(currNode.Name.Value, val)
+		nameFunction(val, currNode.Name.Value)
+		if currNode.IsConst() {
+			env.SetConst(currNode.Name.Value, val)
+		} else {
+			env.Set(currNode.Name.Value, val)
+		}
+	case *ThrowStatement:
+		val := Eval(currNode.Value, env)
+		if isError(val) {
+			return val
+		}
+		return throw(val)
+	case *TryExpression:
+		return evalTryExpression(currNode, env)
+	case *WhileStatement:
+		return evalWhileStatement(currNode, env)
+	case *ForStatement:
+		return evalForStatement(currNode, env)
+	case *BreakStatement:
+		return BREAK_OBJ
+	case *ContinueStatement:
+		return CONTINUE_OBJ
 	case *Identifier:
 		return evalIdentifier(currNode, env)
 
 	// Expressions
 	case *IntegerLiteral:
 		return &Integer{Value: currNode.Value}
+	case *BigIntegerLiteral:
+		return &BigInteger{Value: currNode.Value}
+	case *FloatLiteral:
+		return &Float{Value: currNode.Value}
 	case *StringLiteral:
 		return &String{Value: currNode.Value}
+	case *InterpolatedString:
+		return evalInterpolatedString(currNode, env)
 	case *BooleanLiteral:
 		return nativeBoolToBooleanObject(currNode.Value)
 	case *ArrayLiteral:
@@ -47,16 +99,38 @@ func Eval(node Node, env *Environment) Object {
 		if len(elements) == 1 && isError(elements[0]) {
 			return elements[0]
 		}
-		return &Array{Elements: elements}
+		return env.interp.checkSize(NewArray(elements))
 	case *HashLiteral:
 		return evalHashLiteral(currNode, env)
 	case *PrefixExpression:
 		right := Eval(currNode.Right, env)
+		if isError(right) {
+			return right
+		}
 		return evalPrefixExpression(currNode.Operator, right)
 	case *InfixExpression:
 		left := Eval(currNode.Left, env)
+		if isError(left) {
+			return left
+		}
+		// && and || short-circuit and yield the operand that decided them
+		switch currNode.Operator {
+		case "&&":
+			if !isTruthy(left) {
+				return left
+			}
+			return Eval(currNode.Right, env)
+		case "||":
+			if isTruthy(left) {
+				return left
+			}
+			return Eval(currNode.Right, env)
+		}
 		right := Eval(currNode.Right, env)
-		return evalInfixExpression(currNode.Operator, left, right)
+		if isError(right) {
+			return right
+		}
+		return env.interp.checkSize(evalInfixExpression(currNode.Operator, left, right))
 	case *FunctionLiteral:
 		params := currNode.Parameters
 		body := currNode.Body
@@ -77,7 +151,9 @@ func Eval(node Node, env *Environment) Object {
 		if len(args) == 1 && isError(args[0]) {
 			return args[0]
 		}
-		return applyFunction(function, args)
+		return callFunction(function, args, currNode.Span().Start)
+	case *AssignExpression:
+		return evalAssignExpression(currNode, env)
 	case *IndexExpression:
 		left := Eval(currNode.Left, env)
 		if isError(left) {
@@ -88,6 +164,8 @@ func Eval(node Node, env *Environment) Object {
 			return index
 		}
 		return evalIndexExpression(left, index)
+	case *SliceExpression:
+		return evalSliceExpression(currNode, env)
 	}
 	return nil
 }
@@ -131,55 +209,158 @@ func evalBangOperatorExpression(right Object) Object {
 }
 
 func evalMinusPrefixOperatorExpression(right Object) Object {
-	if right.Type() != INT_OBJ_TYPE {
+	switch right := right.(type) {
+	case *Integer, *BigInteger:
+		return negateInteger(right)
+	case *Float:
+		return &Float{Value: -right.Value}
+	default:
 		return newError("unknown operator: -%s", right.Type())
 	}
-	value := right.(*Integer).Value
-	return &Integer{Value: -value}
 }
 
 func evalInfixExpression(operator string, left, right Object) Object {
 	switch {
 	case left.Type() == INT_OBJ_TYPE && right.Type() == INT_OBJ_TYPE:
 		return evalIntegerInfixExpression(operator, left, right)
+	case isNumber(left) && isNumber(right):
+		// mixed arithmetic promotes integers to floats
+		return evalFloatInfixExpression(operator, left, right)
+	case left.Type() == STRING_OBJ_TYPE && right.Type() == STRING_OBJ_TYPE:
+		return evalStringInfixExpression(operator, left, right)
 	case operator == "==":
 		return nativeBoolToBooleanObject(left == right)
 	case operator == "!=":
 		return nativeBoolToBooleanObject(left != right)
 	case left.Type() != right.Type():
 		return newError("type mismatch: %s %s %s", left.Type(), operator, right.Type())
-	case left.Type() == STRING_OBJ_TYPE && right.Type() == STRING_OBJ_TYPE:
-		return evalStringInfixExpression(operator, left, right)
 	default:
 		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
 	}
 }
 
-func evalStringInfixExpression(operator string, left, right Object) Object {
-	if operator != "+" {
-		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+// valuesEqual compares numbers, strings and booleans by value and arrays
+// and hashes element by element, anything else by identity
+func valuesEqual(a, b Object) bool {
+	switch a := a.(type) {
+	case *Integer, *BigInteger, *Float:
+		if !isNumber(b) {
+			return false
+		}
+		return evalInfixExpression("==", a, b) == TRUE_OBJ
+	case *String:
+		b, ok := b.(*String)
+		return ok && a.Value == b.Value
+	case *Array:
+		b, ok := b.(*Array)
+		if !ok || a.Len() != b.Len() {
+			return false
+		}
+		for i := 0; i < a.Len(); i++ {
+			if !valuesEqual(a.Get(i), b.Get(i)) {
+				return false
+			}
+		}
+		return true
+	case *Hash:
+		b, ok := b.(*Hash)
+		if !ok || a.Len() != b.Len() {
+			return false
+		}
+		for _, pair := range a.Pairs() {
+			other, ok := b.Get(pair.Key.(Hashable))
+			if !ok || !valuesEqual(pair.Value, other.Value) {
+				return false
+			}
+		}
+		return true
 	}
+	return a == b
+}
+
+// compareValues orders numbers by value and strings by code point,
+// returning -1, 0 or 1. Other values, or a number and a string, can't be
+// compared.
+func compareValues(a, b Object) (int, *Error) {
+	switch {
+	case a.Type() == INT_OBJ_TYPE && b.Type() == INT_OBJ_TYPE:
+		return toBig(a).Cmp(toBig(b)), nil
+	case isNumber(a) && isNumber(b):
+		x, y := toFloat(a), toFloat(b)
+		switch {
+		case x < y:
+			return -1, nil
+		case x > y:
+			return 1, nil
+		}
+		return 0, nil
+	case a.Type() == STRING_OBJ_TYPE && b.Type() == STRING_OBJ_TYPE:
+		return strings.Compare(a.(*String).Value, b.(*String).Value), nil
+	}
+	return 0, newError("cannot compare %s with %s", a.Type(), b.Type())
+}
+
+func evalInterpolatedString(is *InterpolatedString, env *Environment) Object {
+	var out strings.Builder
+	out.WriteString(is.Strings[0])
+	for i, exp := range is.Expressions {
+		val := Eval(exp, env)
+		if isError(val) {
+			return val
+		}
+		out.WriteString(val.Inspect())
+		out.WriteString(is.Strings[i+1])
+	}
+	return env.interp.checkSize(&String{Value: out.String()})
+}
+
+// evalStringInfixExpression concatenates strings and compares them by
+// value, ordered by code point
+func evalStringInfixExpression(operator string, left, right Object) Object {
 	leftVal := left.(*String).Value
 	rightVal := right.(*String).Value
-	return &String{Value: leftVal + rightVal}
+	switch operator {
+	case "+":
+		return &String{Value: leftVal + rightVal}
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
 }
 
-func evalIntegerInfixExpression(operator string, left, right Object) Object {
-	leftVal := left.(*Integer).Value
-	rightVal := right.(*Integer).Value
+func evalFloatInfixExpression(operator string, left, right Object) Object {
+	leftVal := toFloat(left)
+	rightVal := toFloat(right)
 	switch operator {
 	case "+":
-		return &Integer{Value: leftVal + rightVal}
+		return &Float{Value: leftVal + rightVal}
 	case "-":
-		return &Integer{Value: leftVal - rightVal}
+		return &Float{Value: leftVal - rightVal}
 	case "*":
-		return &Integer{Value: leftVal * rightVal}
+		return &Float{Value: leftVal * rightVal}
 	case "/":
-		return &Integer{Value: leftVal / rightVal}
+		return &Float{Value: leftVal / rightVal}
+	case "%":
+		return &Float{Value: math.Mod(leftVal, rightVal)}
 	case "<":
 		return nativeBoolToBooleanObject(leftVal < rightVal)
 	case ">":
 		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
 	case "==":
 		return nativeBoolToBooleanObject(leftVal == rightVal)
 	case "!=":
@@ -189,10 +370,38 @@ func evalIntegerInfixExpression(operator string, left, right Object) Object {
 	}
 }
 
+func isNumber(obj Object) bool {
+	switch obj.(type) {
+	case *Integer, *BigInteger, *Float:
+		return true
+	}
+	return false
+}
+
+// toFloat returns the value of a number as a float64
+func toFloat(obj Object) float64 {
+	switch obj := obj.(type) {
+	case *Integer:
+		return float64(obj.Value)
+	case *BigInteger:
+		f, _ := new(big.Float).SetInt(obj.Value).Float64()
+		return f
+	}
+	return obj.(*Float).Value
+}
+
 func evalIndexExpression(left, index Object) Object {
 	switch {
 	case left.Type() == ARRAY_OBJ_TYPE && index.Type() == INT_OBJ_TYPE:
 		return evalArrayIndexExpression(left, index)
+	case left.Type() == MUTABLE_ARRAY_OBJ_TYPE && index.Type() == INT_OBJ_TYPE:
+		elements := left.(*MutableArray).Elements
+		if i, ok := elementIndex(index, len(elements)); ok {
+			return elements[i]
+		}
+		return NULL_OBJ
+	case left.Type() == STRING_OBJ_TYPE && index.Type() == INT_OBJ_TYPE:
+		return evalStringIndexExpression(left, index)
 	case left.Type() == HASH_OBJ_TYPE:
 		return evalHashIndexExpression(left, index)
 	default:
@@ -202,50 +411,298 @@ func evalIndexExpression(left, index Object) Object {
 
 func evalArrayIndexExpression(array, index Object) Object {
 	arrayObject := array.(*Array)
-	idx := index.(*Integer).Value
-	max := int64(len(arrayObject.Elements) - 1)
-	if idx < 0 || idx > max {
+	i, ok := elementIndex(index, arrayObject.Len())
+	if !ok {
+		return NULL_OBJ
+	}
+	return arrayObject.Get(i)
+}
+
+// elementIndex returns index as a position in an array of length
+// elements, reporting whether it is in range
+func elementIndex(index Object, length int) (int, bool) {
+	i, ok := index.(*Integer)
+	if !ok {
+		// a *BigInteger, far out of range
+		return 0, false
+	}
+	if i.Value < 0 || i.Value >= int64(length) {
+		return 0, false
+	}
+	return int(i.Value), true
+}
+
+// evalStringIndexExpression returns the character at a code point index,
+// as a string
+func evalStringIndexExpression(str, index Object) Object {
+	i, ok := index.(*Integer)
+	if !ok || i.Value < 0 {
 		return NULL_OBJ
 	}
-	return arrayObject.Elements[idx]
+	n := i.Value
+	for _, r := range str.(*String).Value {
+		if n == 0 {
+			return &String{Value: string(r)}
+		}
+		n--
+	}
+	return NULL_OBJ
+}
+
+func evalSliceExpression(se *SliceExpression, env *Environment) Object {
+	left := Eval(se.Left, env)
+	if isError(left) {
+		return left
+	}
+	bounds := []Object{NULL_OBJ, NULL_OBJ}
+	for i, bound := range []Expression{se.Low, se.High} {
+		if bound == nil {
+			continue
+		}
+		bounds[i] = Eval(bound, env)
+		if isError(bounds[i]) {
+			return bounds[i]
+		}
+	}
+	return sliceObject(left, bounds[0], bounds[1])
+}
+
+// sliceObject slices an array or a string, by code point. A NULL bound
+// stands for the start or the end.
+func sliceObject(left, low, high Object) Object {
+	var length int
+	switch left := left.(type) {
+	case *Array:
+		length = left.Len()
+	case *String:
+		length = utf8.RuneCountInString(left.Value)
+	default:
+		return newError("slice operator not supported: %s", left.Type())
+	}
+	lo, err := sliceBound(low, 0)
+	if err != nil {
+		return err
+	}
+	hi, err := sliceBound(high, int64(length))
+	if err != nil {
+		return err
+	}
+	if lo < 0 || hi > int64(length) || lo > hi {
+		return newError("slice bounds out of range [%d:%d] with length %d", lo, hi, length)
+	}
+	if array, ok := left.(*Array); ok {
+		return array.Slice(int(lo), int(hi))
+	}
+	return &String{Value: substring(left.(*String).Value, int(lo), int(hi))}
+}
+
+func sliceBound(bound Object, omitted int64) (int64, *Error) {
+	switch bound := bound.(type) {
+	case *Null:
+		return omitted, nil
+	case *Integer:
+		return bound.Value, nil
+	case *BigInteger:
+		return 0, newError("slice bounds out of range: %s", bound.Inspect())
+	default:
+		return 0, newError("slice bounds must be INTEGER, got %s", bound.Type())
+	}
+}
+
+// substring returns the code points [lo, hi) of s, which must be in range
+func substring(s string, lo, hi int) string {
+	start, end := len(s), len(s)
+	n := 0
+	for offset := range s {
+		if n == lo {
+			start = offset
+		}
+		if n == hi {
+			end = offset
+			break
+		}
+		n++
+	}
+	return s[start:end]
 }
 
 func evalHashIndexExpression(hash, index Object) Object {
 	hashObject := hash.(*Hash)
-	key, ok := index.(Hashable)
-	if !ok {
-		return newError("unusable as hash key: %s", index.Type())
+	key, err := hashableKey(index)
+	if err != nil {
+		return err
 	}
-	pair, ok := hashObject.Pairs[key.HashKey()]
+	pair, ok := hashObject.Get(key)
 	if !ok {
 		return NULL_OBJ
 	}
 	return pair.Value
 }
 
+// nameFunction names an anonymous function after the binding it's stored in
+func nameFunction(val Object, name string) {
+	if fn, ok := val.(*Function); ok && fn.Name == "" {
+		fn.Name = name
+	}
+}
+
+// evalAssignExpression evaluates the target's operands, then its current
+// value for compound assignments, then the assigned value
+func evalAssignExpression(ae *AssignExpression, env *Environment) Object {
+	switch target := ae.Target.(type) {
+	case *Identifier:
+		var current Object
+		if ae.Operator != "" {
+			current = evalIdentifier(target, env)
+			if isError(current) {
+				return current
+			}
+		}
+		val := evalAssignedValue(ae, current, env)
+		if isError(val) {
+			return val
+		}
+		nameFunction(val, target.Value)
+		if err := env.Assign(target.Value, val); err != nil {
+			return newError("%v: %s", err, target.Value)
+		}
+		return val
+	case *IndexExpression:
+		container, store := evalPlace(target.Left, env)
+		if isError(container) {
+			return container
+		}
+		index := Eval(target.Index, env)
+		if isError(index) {
+			return index
+		}
+		var current Object
+		if ae.Operator != "" {
+			current = evalIndexExpression(container, index)
+			if isError(current) {
+				return current
+			}
+		}
+		val := evalAssignedValue(ae, current, env)
+		if isError(val) {
+			return val
+		}
+		if err := evalIndexAssignment(target.Left, container, index, val, store); err != nil {
+			return err
+		}
+		return val
+	default:
+		return newError("cannot assign to %s", ae.Target.String())
+	}
+}
+
+// evalAssignedValue evaluates the value of an assignment, applying the
+// operator of compound assignments to the target's current value
+func evalAssignedValue(ae *AssignExpression, current Object, env *Environment) Object {
+	val := Eval(ae.Value, env)
+	if isError(val) || ae.Operator == "" {
+		return val
+	}
+	return env.interp.checkSize(evalInfixExpression(ae.Operator, current, val))
+}
+
+// evalPlace evaluates exp, the container of an element being assigned,
+// along with the function storing an updated copy of it back where it came
+// from. The function is nil when exp is neither a variable nor an element
+// of one.
+func evalPlace(exp Expression, env *Environment) (Object, func(Object) Object) {
+	switch exp := exp.(type) {
+	case *Identifier:
+		val := evalIdentifier(exp, env)
+		return val, func(updated Object) Object {
+			if err := env.Assign(exp.Value, updated); err != nil {
+				return newError("%v: %s", err, exp.Value)
+			}
+			return nil
+		}
+	case *IndexExpression:
+		container, store := evalPlace(exp.Left, env)
+		if isError(container) {
+			return container, nil
+		}
+		index := Eval(exp.Index, env)
+		if isError(index) {
+			return index, nil
+		}
+		return evalIndexExpression(container, index), func(updated Object) Object {
+			return evalIndexAssignment(exp.Left, container, index, updated, store)
+		}
+	}
+	return Eval(exp, env), nil
+}
+
+// evalIndexAssignment sets the element at index of container, the value
+// of left, to val. Hashes and mutable arrays are updated in place. Arrays
+// are values, so an updated copy is stored in place of the array instead.
+// It returns nil or an error.
+func evalIndexAssignment(left Expression, container, index, val Object, store func(Object) Object) Object {
+	switch container := container.(type) {
+	case *Array:
+		i, err := assignedIndex(index, container.Len())
+		if err != nil {
+			return err
+		}
+		if store == nil {
+			return newError("cannot assign to an element of %s", left.String())
+		}
+		return store(container.Set(i, val))
+	case *MutableArray:
+		i, err := assignedIndex(index, len(container.Elements))
+		if err != nil {
+			return err
+		}
+		container.Elements[i] = val
+	case *Hash:
+		key, err := hashableKey(index)
+		if err != nil {
+			return err
+		}
+		container.Set(key, val)
+	default:
+		return newError("index assignment not supported: %s", container.Type())
+	}
+	return nil
+}
+
+// assignedIndex checks the index of an array element being assigned
+func assignedIndex(index Object, length int) (int, *Error) {
+	if index.Type() != INT_OBJ_TYPE {
+		return 0, newError("array index must be INTEGER, got %s", index.Type())
+	}
+	i, ok := elementIndex(index, length)
+	if !ok {
+		return 0, newError("index out of range: %s", index.Inspect())
+	}
+	return i, nil
+}
+
 func evalHashLiteral(
 	node *HashLiteral, env *Environment,
 ) Object {
 
-	pairs := make(map[HashKey]HashPair)
-	for keyNode, valueNode := range node.Pairs {
-		key := Eval(keyNode, env)
+	hash := NewHash(len(node.Pairs))
+	for _, pair := range node.Pairs {
+		key := Eval(pair.Key, env)
 		if isError(key) {
 			return key
 		}
-		hashKey, ok := key.(Hashable)
-		if !ok {
-			return newError("unusable as hash key: %s", key.Type())
+		hashKey, err := hashableKey(key)
+		if err != nil {
+			return err
 		}
-		value := Eval(valueNode, env)
+		value := Eval(pair.Value, env)
 		if isError(value) {
 			return value
 		}
 
-		hashed := hashKey.HashKey()
-		pairs[hashed] = HashPair{Key: key, Value: value}
+		hash.Set(hashKey, value)
 	}
-	return &Hash{Pairs: pairs}
+	return hash
 }
 
 func nativeBoolToBooleanObject(input bool) *BooleanObject {
@@ -257,6 +714,9 @@ func nativeBoolToBooleanObject(input bool) *BooleanObject {
 
 func evalIfExpression(ie *IfExpression, env *Environment) Object {
 	condition := Eval(ie.Condition, env)
+	if isError(condition) {
+		return condition
+	}
 	if isTruthy(condition) {
 		return Eval(ie.Consequence, env)
 	} else if ie.Alternative != nil {
@@ -278,18 +738,153 @@ func isTruthy(obj Object) bool {
 	}
 }
 
+func evalTryExpression(te *TryExpression, env *Environment) Object {
+	result := Eval(te.Block, env)
+	err, failed := result.(*Error)
+	if failed && err.Err != nil {
+		// the host aborted the evaluation, don't run any more of it
+		return err
+	}
+	if failed && te.Catch != nil {
+		catchEnv := NewEnclosedEnvironment(env)
+		if te.CatchParam != nil {
+			catchEnv.Set(te.CatchParam.Value, &ErrorValue{Error: err})
+		}
+		result = Eval(te.Catch, catchEnv)
+	}
+	if te.Finally != nil {
+		// finally can't change the value, only replace it by leaving early
+		finally := Eval(te.Finally, env)
+		if interrupts(finally) {
+			return finally
+		}
+	}
+	if result == nil {
+		return NULL_OBJ
+	}
+	return result
+}
+
+// throw raises val as an error. Thrown error values keep the position and
+// stack trace of the original error.
+func throw(val Object) Object {
+	switch val := val.(type) {
+	case *ErrorValue:
+		err := *val.Error
+		return &err
+	case *String:
+		return &Error{Message: val.Value}
+	default:
+		return &Error{Message: val.Inspect(), Payload: val}
+	}
+}
+
 func evalBlockStatement(block *BlockStatement, env *Environment) Object {
 	var result Object
 	for _, statement := range block.Statements {
 		result = Eval(statement, env)
-		if result != nil {
-			rt := result.Type()
-			if rt == RETURN_VALUE_OBJ_TYPE || rt == ERROR_OBJ_TYPE {
+		if interrupts(result) {
+			return result
+		}
+	}
+	return result
+}
+
+// interrupts reports whether result stops the evaluation of the enclosing
+// block: an error, or a return, break or continue on its way out
+func interrupts(result Object) bool {
+	switch result.(type) {
+	case *Error, *ReturnValue, *Break, *Continue:
+		return true
+	}
+	return false
+}
+
+func evalWhileStatement(ws *WhileStatement, env *Environment) Object {
+	for {
+		condition := Eval(ws.Condition, env)
+		if isError(condition) {
+			return condition
+		}
+		if !isTruthy(condition) {
+			return NULL_OBJ
+		}
+		if result, done := loopControl(Eval(ws.Body, env)); done {
+			return result
+		}
+	}
+}
+
+// evalForStatement iterates over the elements of an array, the characters
+// of a string or the keys of a hash. In the two variable form the key is
+// bound to the index, or to the key and the value to the hash value. Each
+// iteration gets its own environment, so closures capture that
+// iteration's variables.
+func evalForStatement(fs *ForStatement, env *Environment) Object {
+	iterable := Eval(fs.Iterable, env)
+	if isError(iterable) {
+		return iterable
+	}
+	iterate := func(key, value Object) (Object, bool) {
+		loopEnv := NewEnclosedEnvironment(env)
+		if fs.Key != nil {
+			loopEnv.Set(fs.Key.Value, key)
+		}
+		loopEnv.Set(fs.Value.Value, value)
+		return loopControl(Eval(fs.Body, loopEnv))
+	}
+	switch iterable := iterable.(type) {
+	case *Array:
+		for i, element := range iterable.Elements() {
+			if result, done := iterate(&Integer{Value: int64(i)}, element); done {
+				return result
+			}
+		}
+	case *MutableArray:
+		// changes made by the body to elements not yet reached are seen
+		for i, element := range iterable.Elements {
+			if result, done := iterate(&Integer{Value: int64(i)}, element); done {
 				return result
 			}
 		}
+	case *String:
+		// by code point, the key is the code point index
+		i := 0
+		for _, r := range iterable.Value {
+			if result, done := iterate(&Integer{Value: int64(i)}, &String{Value: string(r)}); done {
+				return result
+			}
+			i++
+		}
+	case *Hash:
+		for _, pair := range iterable.Pairs() {
+			value := pair.Value
+			if fs.Key == nil {
+				value = pair.Key
+			}
+			if result, done := iterate(pair.Key, value); done {
+				return result
+			}
+		}
+	default:
+		err := newError("cannot iterate over %s", iterable.Type())
+		err.Pos = fs.Iterable.Span().Start
+		err.Stack = env.interp.stackTrace(err.Pos)
+		return err
 	}
-	return result
+	return NULL_OBJ
+}
+
+// loopControl handles the result of one run of a loop body, it reports
+// whether the loop is done and if so what it evaluates to
+func loopControl(result Object) (Object, bool) {
+	switch result.(type) {
+	case *Break:
+		return NULL_OBJ, true
+	case *Error, *ReturnValue:
+		return result, true
+	}
+	return nil, false
 }
 
 func evalIdentifier(node *Identifier, env *Environment) Object {
@@ -297,7 +892,7 @@ func evalIdentifier(node *Identifier, env *Environment) Object {
 	if ok {
 		return val
 	}
-	if builtin, ok := builtins[node.Value]; ok {
+	if builtin, ok := env.interp.Builtin(node.Value); ok {
 		return builtin
 	}
 
@@ -318,14 +913,37 @@ func evalExpressions(
 	return result
 }
 
+// applyFunction calls fn on behalf of the host or a builtin
 func applyFunction(fn Object, args []Object) Object {
+	return callFunction(fn, args, Position{})
+}
+
+// callFunction calls fn from the call expression at call
+func callFunction(fn Object, args []Object, call Position) Object {
 	switch fn := fn.(type) {
 	case *Function:
+		if len(args) != len(fn.Parameters) {
+			return newError("wrong number of arguments: want=%d, got=%d", len(fn.Parameters), len(args))
+		}
+		name := fn.Name
+		if name == "" {
+			name = "<anonymous>"
+		}
+		if err := fn.Env.interp.enter(name, call); err != nil {
+			return err
+		}
+		defer fn.Env.interp.leave()
 		extendedEnv := extendFunctionEnv(fn, args)
-		evaluated := Eval(fn.Body, extendedEnv)
-		return unwrapReturnValue(evaluated)
+		evaluated := unwrapReturnValue(Eval(fn.Body, extendedEnv))
+		if evaluated == nil {
+			// an empty body, or one ending in a let, has no value
+			return NULL_OBJ
+		}
+		return evaluated
 	case *Builtin:
 		return fn.Fn(args...)
+	case Callable:
+		return fn.Call(args...)
 	default:
 		return newError("not a function: %s", fn.Type())
 	}
@@ -351,5 +969,5 @@ func newError(format string, a ...interface{}) *Error {
 }
 
 func isError(obj Object) bool {
-	return obj.Type() == ERROR_OBJ_TYPE
+	return obj != nil && obj.Type() == ERROR_OBJ_TYPE
 }
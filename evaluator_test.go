@@ -36,6 +36,126 @@ func TestEvalIntegerExpression(t *testing.T) {
 	}
 }
 
+func TestEvalFloatExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1.5", "1.5"},
+		{"-2.25", "-2.25"},
+		{"1e3", "1000.0"},
+		{"1.5e-3", "0.0015"},
+		{"1e21", "1e+21"},
+		{"1.5 + 1", "2.5"},
+		{"3 / 2.0", "1.5"},
+		{"2.0 * 3", "6.0"},
+		{"1 - 0.5", "0.5"},
+		{"0.1 + 0.2", "0.30000000000000004"},
+		{"1.0 / 0", "+Inf"},
+		{"1 < 1.5", "true"},
+		{"2.5 > 2", "true"},
+		{"1 == 1.0", "true"},
+		{"1.5 != 1.5", "false"},
+		{"int(2.9)", "2"},
+		{"int(-2.9)", "-2"},
+		{`int("42")`, "42"},
+		{"float(3)", "3.0"},
+		{`float("2.5")`, "2.5"},
+		{"1.5 + true", "ERROR: type mismatch: FLOAT + BOOLEAN"},
+		{`int("4.5")`, `ERROR: could not parse "4.5" as INTEGER`},
+		{"int(1e20)", "100000000000000000000"},
+		{"int(1.0 / 0)", "ERROR: cannot convert +Inf to INTEGER"},
+		{"float(true)", "ERROR: argument to `float` not supported, got BOOLEAN"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			testInspect(t, tt.input, tt.expected)
+		})
+	}
+}
+
+func TestEvalBigIntegers(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"9223372036854775807 + 1", "9223372036854775808"},
+		{"-9223372036854775807 - 2", "-9223372036854775809"},
+		{"4294967296 * 4294967296", "18446744073709551616"},
+		{"-(-9223372036854775807 - 1)", "9223372036854775808"},
+		{"(-9223372036854775807 - 1) / -1", "9223372036854775808"},
+		{"123456789012345678901234567890", "123456789012345678901234567890"},
+		{"-123456789012345678901234567890", "-123456789012345678901234567890"},
+		// results that fit again are plain integers
+		{"9223372036854775808 - 1", "9223372036854775807"},
+		{"18446744073709551616 / 4294967296", "4294967296"},
+		{"-7 / 2", "-3"},
+		{"-18446744073709551617 / 2", "-9223372036854775808"},
+		{"18446744073709551616 > 1", "true"},
+		{"18446744073709551616 == 18446744073709551616", "true"},
+		{"18446744073709551616 != 18446744073709551617", "true"},
+		{"18446744073709551616 * 0.5", "9.223372036854776e+18"},
+		{`let fact = fn(n) { if (n < 2) { 1 } else { n * fact(n - 1) } }; fact(25)`, "15511210043330985984000000"},
+		{`{18446744073709551616: "big"}[18446744073709551616]`, "big"},
+		{`int("18446744073709551616") - 18446744073709551615`, "1"},
+		{"float(18446744073709551616)", "1.8446744073709552e+19"},
+		{"1 / 0", "ERROR: division by zero"},
+		{"18446744073709551616 / 0", "ERROR: division by zero"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			evaluated := testInspect(t, tt.input, tt.expected)
+			if integer, ok := evaluated.(*BigInteger); ok {
+				require.False(t, integer.Value.IsInt64(), "%s should be an *Integer", tt.expected)
+			}
+		})
+	}
+}
+
+func TestEvalOperators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"7 % 3", "1"},
+		{"-7 % 3", "-1"},
+		{"7.5 % 2", "1.5"},
+		{"1 <= 1", "true"},
+		{"2 <= 1", "false"},
+		{"1 >= 2", "false"},
+		{"1.5 >= 1", "true"},
+		{"6 & 3", "2"},
+		{"6 | 3", "7"},
+		{"6 ^ 3", "5"},
+		{"1 << 4", "16"},
+		{"-16 >> 2", "-4"},
+		{"1 >> 100", "0"},
+		{"1 << 64", "18446744073709551616"},
+		{"(1 << 64) >> 63", "2"},
+		{"(1 << 64) | 1", "18446744073709551617"},
+		{"(1 << 64) <= (1 << 65)", "true"},
+		{"true && 2", "2"},
+		{"0 && false", "false"},
+		{"false && 1 / 0", "false"},
+		{"if (false) { 1 } || 3", "3"},
+		{"1 || 1 / 0", "1"},
+		{"false || false", "false"},
+		{"let n = 0; let f = fn() { n }; true || f(); n", "0"},
+		{"1 < 2 && 2 <= 3 || false", "true"},
+		{"5 % 0", "ERROR: division by zero"},
+		{"(1 << 64) % 0", "ERROR: division by zero"},
+		{"1 << -1", "ERROR: negative shift count: -1"},
+		{"1 << 100000", "ERROR: shift count too large: 100000"},
+		{"1.5 & 1", "ERROR: unknown operator: FLOAT & INTEGER"},
+		{"true && 1 / 0", "ERROR: division by zero"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			testInspect(t, tt.input, tt.expected)
+		})
+	}
+}
+
 func TestEvalBooleanExpression(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -187,6 +307,11 @@ func TestErrorHandling(t *testing.T) {
 			`{"name": "Monkey"}[fn(x) { x }];`,
 			"unusable as hash key: FUNCTION",
 		},
+		{
+			"too few arguments",
+			`fn(a, b) { a }(1);`,
+			"wrong number of arguments: want=2, got=1",
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -204,6 +329,18 @@ func TestErrorHandling(t *testing.T) {
 	}
 }
 
+func TestErrorPositions(t *testing.T) {
+	input := "let a = 1;\nlet b = fn() {\n  a + foo\n};\nb();"
+	program := NewParser(NewFileLexer("script.mk", input)).ParseProgram()
+	evaluated := Eval(program, NewEnvironment())
+	errObj, ok := evaluated.(*Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+	}
+	require.Equal(t, "script.mk:3:7", errObj.Pos.String())
+	require.Equal(t, "ERROR: script.mk:3:7: identifier not found: foo", errObj.Inspect())
+}
+
 func TestEvaluateLetStatements(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -251,6 +388,24 @@ func TestFunctionApplication(t *testing.T) {
 	}
 }
 
+func TestFunctionsWithoutValue(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let f = fn() {}; f()", "null"},
+		{"let f = fn() { let x = 1; }; f()", "null"},
+		{"let f = fn() {}; f() + 1", "ERROR: type mismatch: NULL + INTEGER"},
+		{"let f = fn() {}; [f()]", "[null]"},
+		{`{"a": fn() {}()}`, "{a: null}"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			testInspect(t, tt.input, tt.expected)
+		})
+	}
+}
+
 func TestClosures(t *testing.T) {
 	input := `
     let newAdder = fn(x) {
@@ -272,6 +427,60 @@ func TestStringLiteral(t *testing.T) {
 	require.Equal(t, "Hello World!", str.Value)
 }
 
+func TestStringInterpolation(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let name = "Ann"; let age = 41; "hello ${name}, you are ${age + 1}"`, "hello Ann, you are 42"},
+		{`"${1.5} ${true} ${[1, "a"]} ${if (false) { 1 }}"`, "1.5 true [1, a] null"},
+		{`let x = 2; "${"${x}${x}"}!"`, "22!"},
+		{`"tab\there \u00e9"`, "tab\there é"},
+		{"`a\\n${b}`", "a\\n${b}"},
+		{`"${1 + true}"`, "ERROR: type mismatch: INTEGER + BOOLEAN"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			testInspect(t, tt.input, tt.expected)
+		})
+	}
+}
+
+func TestUnicodeStrings(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`len("héllo")`, "5"},
+		{`len("日本語")`, "3"},
+		{`"héllo"[1]`, "é"},
+		{`"日本語"[2]`, "語"},
+		{`"日本語"[3]`, "null"},
+		{`"abc"[-1]`, "null"},
+		{`"héllo"[1:3]`, "él"},
+		{`"héllo"[:2]`, "hé"},
+		{`"héllo"[4:]`, "o"},
+		{`"héllo"[5:]`, ""},
+		{`"日本語"[:]`, "日本語"},
+		{`[1, 2, 3, 4][1:3]`, "[2, 3]"},
+		{`[1, 2, 3][:0]`, "[]"},
+		{`bytes("é")`, "[195, 169]"},
+		{`len(bytes("日本"))`, "6"},
+		{`let café = "ok"; café`, "ok"},
+		{`"héllo"[2:1]`, "ERROR: slice bounds out of range [2:1] with length 5"},
+		{`"abc"[0:4]`, "ERROR: slice bounds out of range [0:4] with length 3"},
+		{`[1][-1:]`, "ERROR: slice bounds out of range [-1:1] with length 1"},
+		{`"abc"["a":]`, "ERROR: slice bounds must be INTEGER, got STRING"},
+		{`5[1:]`, "ERROR: slice operator not supported: INTEGER"},
+		{`bytes(1)`, "ERROR: argument to `bytes` must be STRING, got INTEGER"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			testInspect(t, tt.input, tt.expected)
+		})
+	}
+}
+
 func TestStringConcatenation(t *testing.T) {
 	input := `"Hello" + " " + "World!"`
 	evaluated := testEval(input)
@@ -318,13 +527,13 @@ func TestArrayLiterals(t *testing.T) {
 	if !ok {
 		t.Fatalf("object is not Array. got=%T (%+v)", evaluated, evaluated)
 	}
-	if len(result.Elements) != 3 {
+	if result.Len() != 3 {
 		t.Fatalf("array has wrong num of elements. got=%d",
-			len(result.Elements))
+			result.Len())
 	}
-	testIntegerObject(t, result.Elements[0], 1)
-	testIntegerObject(t, result.Elements[1], 4)
-	testIntegerObject(t, result.Elements[2], 6)
+	testIntegerObject(t, result.Get(0), 1)
+	testIntegerObject(t, result.Get(1), 4)
+	testIntegerObject(t, result.Get(2), 6)
 }
 
 func TestArrayIndexExpressions(t *testing.T) {
@@ -402,19 +611,19 @@ func TestHashLiterals(t *testing.T) {
 	if !ok {
 		t.Fatalf("Eval didn't return Hash. got=%T (%+v)", evaluated, evaluated)
 	}
-	expected := map[HashKey]int64{
-		(&String{Value: "one"}).HashKey():   1,
-		(&String{Value: "two"}).HashKey():   2,
-		(&String{Value: "three"}).HashKey(): 3,
-		(&Integer{Value: 4}).HashKey():      4,
-		TRUE_OBJ.HashKey():                  5,
-		FALSE_OBJ.HashKey():                 6,
+	expected := map[Hashable]int64{
+		&String{Value: "one"}:   1,
+		&String{Value: "two"}:   2,
+		&String{Value: "three"}: 3,
+		&Integer{Value: 4}:      4,
+		TRUE_OBJ:                5,
+		FALSE_OBJ:               6,
 	}
-	if len(result.Pairs) != len(expected) {
-		t.Fatalf("Hash has wrong num of pairs. got=%d", len(result.Pairs))
+	if result.Len() != len(expected) {
+		t.Fatalf("Hash has wrong num of pairs. got=%d", result.Len())
 	}
 	for expectedKey, expectedValue := range expected {
-		pair, ok := result.Pairs[expectedKey]
+		pair, ok := result.Get(expectedKey)
 		if !ok {
 			t.Errorf("no pair for given key in Pairs")
 		}
@@ -468,8 +677,470 @@ func TestHashIndexExpressions(t *testing.T) {
 	}
 }
 
+func TestCollectionBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`first([1, 2, 3])`, "1"},
+		{`first([])`, "null"},
+		{`last([1, 2, 3])`, "3"},
+		{`last([])`, "null"},
+		{`rest([1, 2, 3])`, "[2, 3]"},
+		{`rest([1])`, "[]"},
+		{`rest([])`, "null"},
+		{`map([1, 2, 3], fn(x) { x * 2 })`, "[2, 4, 6]"},
+		{`let k = 3; map([1, 2], fn(x) { x * k })`, "[3, 6]"},
+		{`map([[1], [1, 2]], len)`, "[1, 2]"},
+		{`map([1, 2], fn(x) { map([x, x], fn(y) { x + y }) })`, "[[2, 2], [4, 4]]"},
+		{`map([], fn(x) { x })`, "[]"},
+		{`filter([1, 2, 3, 4], fn(x) { x % 2 == 0 })`, "[2, 4]"},
+		{`reduce([1, 2, 3, 4], fn(acc, x) { acc + x })`, "10"},
+		{`reduce([1, 2, 3], fn(acc, x) { acc + x }, 10)`, "16"},
+		{`reduce([], fn(acc, x) { acc + x }, 0)`, "0"},
+		{`reduce([], fn(acc, x) { acc + x })`, "ERROR: reduce of empty array with no initial value"},
+		{`find([1, 2, 3], fn(x) { x > 1 })`, "2"},
+		{`find([1, 2, 3], fn(x) { x > 5 })`, "null"},
+		{`any([1, 2, 3], fn(x) { x > 2 })`, "true"},
+		{`any([], fn(x) { true })`, "false"},
+		{`all([1, 2, 3], fn(x) { x > 0 })`, "true"},
+		{`all([1, 2, 3], fn(x) { x > 1 })`, "false"},
+		{`zip([1, 2, 3], ["a", "b"])`, "[[1, a], [2, b]]"},
+		{`zip([1, 2], [3, 4], [5, 6])`, "[[1, 3, 5], [2, 4, 6]]"},
+		{`range(4)`, "[0, 1, 2, 3]"},
+		{`range(2, 5)`, "[2, 3, 4]"},
+		{`range(10, 0, -3)`, "[10, 7, 4, 1]"},
+		{`range(5, 2)`, "[]"},
+		{`range(-9223372036854775807 - 1, 9223372036854775807, 4611686018427387904)`, "[-9223372036854775808, -4611686018427387904, 0, 4611686018427387904]"},
+		{`slice([1, 2, 3, 4], 1, 3)`, "[2, 3]"},
+		{`slice([1, 2, 3, 4], 2)`, "[3, 4]"},
+		{`slice("héllo", 1, 3)`, "él"},
+		{`concat([1], [], [2, 3])`, "[1, 2, 3]"},
+		{`reverse([1, 2, 3])`, "[3, 2, 1]"},
+		{`contains([1, "a", [2]], "a")`, "true"},
+		{`contains([1, "a", [2]], [2])`, "true"},
+		{`contains([1, 2], 3)`, "false"},
+		{`contains([1, 2], 2.0)`, "true"},
+		{`index_of([1, 2, 3], 3)`, "2"},
+		{`index_of([1, 2, 3], 4)`, "-1"},
+		{`index_of([{"a": 1}], {"a": 1})`, "0"},
+		{`flatten([1, [2, 3], [], [[4]]])`, "[1, 2, 3, [4]]"},
+		// natively, so long arrays don't need deep recursion
+		{`len(map(range(100000), fn(x) { x * 2 }))`, "100000"},
+		{`reduce(range(1, 101), fn(acc, x) { acc + x })`, "5050"},
+		{`map([1], fn(x) { x + true })`, "ERROR: type mismatch: INTEGER + BOOLEAN"},
+		{`map([1], fn(x, y) { x })`, "ERROR: wrong number of arguments: want=2, got=1"},
+		{`map([1])`, "ERROR: wrong number of arguments. got=1, want=2"},
+		{`map(1, len)`, "ERROR: first argument to `map` must be ARRAY, got INTEGER"},
+		{`filter([1], 1)`, "ERROR: second argument to `filter` must be FUNCTION, got INTEGER"},
+		{`first("abc")`, "ERROR: argument to `first` must be ARRAY, got STRING"},
+		{`zip()`, "ERROR: wrong number of arguments. got=0, want=1 or more"},
+		{`concat([1], 2)`, "ERROR: arguments to `concat` must be ARRAY, got INTEGER"},
+		{`range()`, "ERROR: wrong number of arguments. got=0, want=1 to 3"},
+		{`range("a")`, "ERROR: arguments to `range` must be INTEGER, got STRING"},
+		{`range(0, 10, 0)`, "ERROR: range step must not be zero"},
+		{`range(9223372036854775807)`, "ERROR: range of 9223372036854775807 elements is too large"},
+		{`range(9223372036854775808)`, "ERROR: argument to `range` out of range: 9223372036854775808"},
+		{`slice(1, 2)`, "ERROR: slice operator not supported: INTEGER"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			testInspect(t, tt.input, tt.expected)
+		})
+	}
+}
+
+func TestSortBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`sort([3, 1, 2])`, "[1, 2, 3]"},
+		{`sort([])`, "[]"},
+		{`sort([2.5, 1, 9223372036854775808, -1.5])`, "[-1.5, 1, 2.5, 9223372036854775808]"},
+		{`sort(["b", "a", "é", "B"])`, "[B, a, b, é]"},
+		{`let a = [3, 1, 2]; sort(a); a`, "[3, 1, 2]"},
+		{`sort([3, 1, 2], fn(a, b) { b - a })`, "[3, 2, 1]"},
+		// stable, equal elements keep their order
+		{`sort([[1, "a"], [0, "b"], [1, "c"], [0, "d"]], fn(a, b) { a[0] - b[0] })`, "[[0, b], [0, d], [1, a], [1, c]]"},
+		{`sort_by(["ccc", "a", "bb", "d"], len)`, "[a, d, bb, ccc]"},
+		{`sort_by([{"n": "x", "age": 30}, {"n": "y", "age": 20}], fn(p) { p["age"] })`, "[{n: y, age: 20}, {n: x, age: 30}]"},
+		{`sort([1, "a"])`, "ERROR: cannot compare STRING with INTEGER"},
+		{`sort([true, false])`, "ERROR: cannot compare BOOLEAN with BOOLEAN"},
+		{`sort_by([1, 2], fn(x) { if (x == 1) { "a" } else { 1 } })`, "ERROR: cannot compare INTEGER with STRING"},
+		{`sort([1, 2], fn(a, b) { true })`, "ERROR: comparator passed to `sort` must return INTEGER, got BOOLEAN"},
+		{`sort([1, 2], fn(a, b) { a + true })`, "ERROR: type mismatch: INTEGER + BOOLEAN"},
+		{`sort_by([1, 2], fn(x) { x + true })`, "ERROR: type mismatch: INTEGER + BOOLEAN"},
+		{`sort()`, "ERROR: wrong number of arguments. got=0, want=1 or 2"},
+		{`sort(1)`, "ERROR: first argument to `sort` must be ARRAY, got INTEGER"},
+		{`sort([], 1)`, "ERROR: second argument to `sort` must be FUNCTION, got INTEGER"},
+		{`sort_by([1])`, "ERROR: wrong number of arguments. got=1, want=2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			testInspect(t, tt.input, tt.expected)
+		})
+	}
+}
+
+func TestArrayValues(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		// push leaves its argument alone
+		{"let a = [1, 2]; let b = push(a, 3); [a, b]", "[[1, 2], [1, 2, 3]]"},
+		{"let a = [1]; let b = push(a, 2); let c = push(a, 3); [a, b, c]", "[[1], [1, 2], [1, 3]]"},
+		{"let a = [1, 2, 3]; let b = push(a[0:1], 9); [a, b]", "[[1, 2, 3], [1, 9]]"},
+		{"let a = [1, 2, 3]; let b = rest(a); [a, b, push(b, 4)]", "[[1, 2, 3], [2, 3], [2, 3, 4]]"},
+		{"let build = fn(n) { reduce(range(n), fn(acc, x) { push(acc, x * x) }, []) }; let a = build(2000); [len(a), a[1999]]", "[2000, 3996001]"},
+		{"let m = mutable([1, 2]); append(m, 3, 4); m", "mutable([1, 2, 3, 4])"},
+		{"let m = mutable([1, 2]); let n = m; append(n, 3); len(m)", "3"},
+		{"let a = [1, 2]; let m = mutable(a); append(m, 3); a", "[1, 2]"},
+		{"let m = mutable([1, 2]); [pop(m), m]", "[2, mutable([1])]"},
+		{"pop(mutable([]))", "null"},
+		{"let m = mutable([1, 2]); let a = freeze(m); append(m, 3); [a, m[2], m[5]]", "[[1, 2], 3, null]"},
+		{"let m = mutable([1, 2]); reduce(map([1, 2], fn(x) { append(m, x) }), fn(acc, x) { acc }); m", "mutable([1, 2, 1, 2])"},
+		{"push(mutable([]), 1)", "ERROR: argument to `push` must be ARRAY, got MUTABLE_ARRAY"},
+		{"append([1], 2)", "ERROR: first argument to `append` must be MUTABLE_ARRAY, got ARRAY"},
+		{"freeze([1])", "ERROR: argument to `freeze` must be MUTABLE_ARRAY, got ARRAY"},
+		// builtins reading arrays take a snapshot of mutable ones
+		{"let m = mutable([1]); let n = mutable(m); append(n, 2); [m, n]", "[mutable([1]), mutable([1, 2])]"},
+		{"map(mutable([1, 2]), fn(x) { x * 2 })", "[2, 4]"},
+		{"filter(mutable([1, 2, 3]), fn(x) { x != 2 })", "[1, 3]"},
+		{"reduce(mutable([1, 2, 3]), fn(acc, x) { acc + x }, 0)", "6"},
+		{"sort(mutable([3, 1, 2]))", "[1, 2, 3]"},
+		{`sort_by(mutable(["bb", "a"]), len)`, "[a, bb]"},
+		{`join(mutable(["a", "b"]), "-")`, "a-b"},
+		{"contains(mutable([1, 2]), 2)", "true"},
+		{"index_of(mutable([1, 2]), 2)", "1"},
+		{"zip(mutable([1]), [2])", "[[1, 2]]"},
+		{"flatten([mutable([1, 2]), [3], 4])", "[1, 2, 3, 4]"},
+		{"let m = mutable([2, 1]); let s = sort(m); append(m, 0); [s, m]", "[[1, 2], mutable([2, 1, 0])]"},
+		{"{mutable([1]): 1}", "ERROR: unusable as hash key: MUTABLE_ARRAY"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			testInspect(t, tt.input, tt.expected)
+		})
+	}
+}
+
+func TestStringBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		// compared by value, ordered by code point
+		{`"a" == "a"`, "true"},
+		{`let s = "ab"; s == "a" + "b"`, "true"},
+		{`"a" != "a"`, "false"},
+		{`"a" != "b"`, "true"},
+		{`"abc" < "abd"`, "true"},
+		{`"b" > "abc"`, "true"},
+		{`"Z" < "a"`, "true"},
+		{`"z" < "é"`, "true"},
+		{`"a" <= "a"`, "true"},
+		{`"a" >= "b"`, "false"},
+		{`"a" == 1`, "false"},
+		{`"a" < 1`, "ERROR: type mismatch: STRING < INTEGER"},
+		{`"a" - "b"`, "ERROR: unknown operator: STRING - STRING"},
+		{`"héllo"[1]`, "é"},
+		{`"héllo"[1:3]`, "él"},
+		{`split("a,b,,c", ",")`, "[a, b, , c]"},
+		{`split("  a b	c
+")`, "[a, b, c]"},
+		{`split("añb", "")`, "[a, ñ, b]"},
+		{`join(["a", "b", "c"], ", ")`, "a, b, c"},
+		{`join([1, "a", true])`, "1atrue"},
+		{`join([], "-")`, ""},
+		{`trim("  a b 
+")`, "a b"},
+		{`trim("xxaxx", "x")`, "a"},
+		{`upper("héllo")`, "HÉLLO"},
+		{`lower("HÉLLO")`, "héllo"},
+		{`replace("a-b-c", "-", "+")`, "a+b+c"},
+		{`replace("abc", "", "-")`, "-a-b-c-"},
+		{`starts_with("hello", "he")`, "true"},
+		{`starts_with("hello", "lo")`, "false"},
+		{`ends_with("hello", "lo")`, "true"},
+		{`index_of("héllo", "l")`, "2"},
+		{`index_of("hello", "z")`, "-1"},
+		{`index_of("hello", "")`, "0"},
+		{`repeat("ab", 3)`, "ababab"},
+		{`repeat("ab", 0)`, ""},
+		{`pad_left("5", 3, "0")`, "005"},
+		{`pad_left("é", 3)`, "  é"},
+		{`pad_right("ab", 4, "·")`, "ab··"},
+		{`pad_right("abcdef", 3)`, "abcdef"},
+		{`chars("añb")`, "[a, ñ, b]"},
+		{`chars("")`, "[]"},
+		{`format("%s is %d years", "Ann", 41)`, "Ann is 41 years"},
+		{`format("%5.2f|%-4d|%04d|%x|%q|%t|%v|100%%", 3.14159, 7, 42, 255, "hi", true, [1, "a"])`, " 3.14|7   |0042|ff|\"hi\"|true|[1, a]|100%"},
+		{`format("%d", 9223372036854775808)`, "9223372036854775808"},
+		{`format("%.1f", 2)`, "2.0"},
+		{`format("%d", "a")`, "ERROR: format verb %d needs INTEGER, got STRING"},
+		{`format("%d %d", 1)`, "ERROR: format verb %d has no argument"},
+		{`format("%d", 1, 2)`, "ERROR: format has 1 unused arguments"},
+		{`format("%y", 1)`, "ERROR: unknown format verb %y"},
+		{`format("100%")`, "ERROR: format ends with an incomplete verb \"%\""},
+		{`format("%99999999999999999999d", 1)`, "ERROR: format width too large in \"%99999999999999999999d\""},
+		{`upper(1)`, "ERROR: argument to `upper` must be STRING, got INTEGER"},
+		{`split("a", 1)`, "ERROR: second argument to `split` must be STRING, got INTEGER"},
+		{`index_of(1, 1)`, "ERROR: first argument to `index_of` must be ARRAY or STRING, got INTEGER"},
+		{`repeat("a", -1)`, "ERROR: negative repeat count: -1"},
+		{`repeat("ab", 9223372036854775807)`, "ERROR: result of `repeat` is too large"},
+		{`pad_left("a", 3, "ab")`, "ERROR: pad passed to `pad_left` must be a single character, got \"ab\""},
+		{`replace("a", "b")`, "ERROR: wrong number of arguments. got=2, want=3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			testInspect(t, tt.input, tt.expected)
+		})
+	}
+}
+
+func TestHashBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`len({"a": 1, "b": 2})`, "2"},
+		{`keys({"b": 1, "a": 2})`, "[b, a]"},
+		{`values({"b": 1, "a": 2})`, "[1, 2]"},
+		{`entries({"b": 1, "a": 2})`, "[[b, 1], [a, 2]]"},
+		{`keys({})`, "[]"},
+		{`has({"a": 1}, "a")`, "true"},
+		{`has({"a": 1}, "b")`, "false"},
+		{`get({"a": 1}, "a")`, "1"},
+		{`get({"a": 1}, "b")`, "null"},
+		{`get({"a": 1}, "b", 0)`, "0"},
+		{`let h = {"a": 1, "b": 2, "c": 3}; delete(h, "b")`, "true"},
+		{`let h = {"a": 1, "b": 2, "c": 3}; delete(h, "b"); delete(h, "b")`, "false"},
+		{`let h = {"a": 1, "b": 2, "c": 3}; delete(h, "b"); h`, "{a: 1, c: 3}"},
+		{`let h = {"a": 1, "b": 2, "c": 3}; delete(h, "a"); h["c"]`, "3"},
+		{`merge({"a": 1, "b": 2}, {"b": 3, "c": 4}, {"a": 5})`, "{a: 5, b: 3, c: 4}"},
+		{`let h = {"a": 1}; merge(h, {"a": 2}); h`, "{a: 1}"},
+		{`keys(1)`, "ERROR: argument to `keys` must be HASH, got INTEGER"},
+		{`has([], 1)`, "ERROR: first argument to `has` must be HASH, got ARRAY"},
+		{`has({}, fn(x) { x })`, "ERROR: unusable as hash key: FUNCTION"},
+		{`get({})`, "ERROR: wrong number of arguments. got=1, want=2 or 3"},
+		{`merge({}, 1)`, "ERROR: arguments to `merge` must be HASH, got INTEGER"},
+		{`merge()`, "ERROR: wrong number of arguments. got=0, want=1 or more"},
+		// arrays of hashable values are composite keys
+		{`{[1, "a"]: 1}[[1, "a"]]`, "1"},
+		{`{[1, [2, true]]: 1}[[1, [2, true]]]`, "1"},
+		{`{[1, 2]: 1}[[2, 1]]`, "null"},
+		{`{[]: 1}[[]]`, "1"},
+		{`{[1, 2]: 1, [1, 2]: 2}`, "{[1, 2]: 2}"},
+		{`{[1, fn(x) { x }]: 1}`, "ERROR: unusable as hash key: FUNCTION"},
+		{`{[1.5]: 1}`, "ERROR: unusable as hash key: FLOAT"},
+		// integers past int64 are the same key whatever their size
+		{`{9223372036854775807 + 1: "big"}[9223372036854775808]`, "big"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			testInspect(t, tt.input, tt.expected)
+		})
+	}
+}
+
+func TestHashOrder(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{"b": 1, "a": 2, 3: 3, true: 4}`, "{b: 1, a: 2, 3: 3, true: 4}"},
+		{`{"b": 1, "a": 2, "b": 3}`, "{b: 3, a: 2}"},
+		{`{}`, "{}"},
+		// pairs are evaluated left to right, key before value
+		{`{1 / 0: 1, "a": 1 + true}`, "ERROR: division by zero"},
+		{`{"a": 1 + true, 1 / 0: 1}`, "ERROR: type mismatch: INTEGER + BOOLEAN"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			testInspect(t, tt.input, tt.expected)
+		})
+	}
+}
+
+func TestTryCatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected interface{} // int, string for a string value, *Error for a message
+	}{
+		{"no error", `try { 1 } catch (e) { 2 }`, 1},
+		{"runtime error", `try { 1 + true } catch (e) { error_message(e) }`, "type mismatch: INTEGER + BOOLEAN"},
+		{"error in function", `let f = fn(x) { x + true }; try { f(1); 2 } catch (e) { 3 }`, 3},
+		{"throw string", `try { throw "bad" } catch (e) { error_message(e) }`, "bad"},
+		{"throw value", `try { throw {"code": 4} } catch (e) { error_payload(e)["code"] }`, 4},
+		{"throw error value", `try { throw error("bad", 7) } catch (e) { error_payload(e) }`, 7},
+		{"no payload", `try { throw "bad" } catch (e) { error_payload(e) }`, nil},
+		{"catch without binding", `try { throw "bad" } catch { 5 }`, 5},
+		{"rethrow", `try { try { throw "inner" } catch (e) { throw e } } catch (e) { error_message(e) }`, "inner"},
+		{"error values are plain values", `let e = error("x"); 1`, 1},
+		{"finally keeps the value", `try { 1 } finally { 2 }`, 1},
+		{"finally after catch", `let f = fn() { try { throw "a" } catch { 1 } finally { 2 } }; f()`, 1},
+		{"return through finally", `let f = fn() { try { return 1; 5 } finally { 3 } }; f()`, 1},
+		{"return from finally", `let f = fn() { try { return 1 } finally { return 2 } }; f()`, 2},
+		{"catch scope", `let e = 1; try { throw "a" } catch (e) { 2 }; e`, 1},
+		{"uncaught after finally", `try { throw "a" } finally { 1 }`, &Error{Message: "a"}},
+		{"throw in catch", `try { throw "a" } catch (e) { throw "b" }`, &Error{Message: "b"}},
+		{"thrown value message", `throw [1, 2]`, &Error{Message: "[1, 2]"}},
+		{"bad argument", `error_message(1)`, &Error{Message: "argument to `error_message` must be ERROR_VALUE, got INTEGER"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			evaluated := testEval(tt.input)
+			switch expected := tt.expected.(type) {
+			case int:
+				testIntegerObject(t, evaluated, int64(expected))
+			case string:
+				str, ok := evaluated.(*String)
+				require.True(t, ok, "got=%T (%+v)", evaluated, evaluated)
+				require.Equal(t, expected, str.Value)
+			case *Error:
+				errObj, ok := evaluated.(*Error)
+				require.True(t, ok, "got=%T (%+v)", evaluated, evaluated)
+				require.Equal(t, expected.Message, errObj.Message)
+			default:
+				testNullObject(t, evaluated)
+			}
+		})
+	}
+}
+
+func TestLoops(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let i = 0; while (i < 5) { let i = i + 1 }; i", "5"},
+		{"while (false) { 1 }", "null"},
+		{"let s = 0; for (x in [1, 2, 3]) { let s = s + x }; s", "0"},
+		{"let f = fn(xs) { let n = 0; for (x in xs) { return x * 10 }; n }; f([4, 5])", "40"},
+		{"let f = fn() { for (x in [1, 2, 3]) { if (x < 3) { continue }; return x } }; f()", "3"},
+		{"let f = fn() { let i = 0; while (true) { let i = i + 1; if (i == 3) { break } }; i }; f()", "3"},
+		{"let f = fn() { let i = 0; while (i < 10) { let i = i + 1; if (i % 2 == 0) { continue }; if (i > 6) { return i } } }; f()", "7"},
+		{"let f = fn(s) { for (i, c in s) { if (i == 1) { return c } } }; f(\"ab\")", "b"},
+		{"let f = fn(s) { for (i, c in s) { if (i == 2) { return c } } }; f(\"日本語\")", "語"},
+		{"let f = fn() { for (i, x in [5, 6, 7]) { if (x == 6) { return i } } }; f()", "1"},
+		{"let f = fn(h) { for (k, v in h) { if (v == 2) { return k } } }; f({\"a\": 1, \"b\": 2})", "b"},
+		{"let f = fn(h) { for (k in h) { return k } }; f({\"a\": 1})", "a"},
+		{"let f = fn(h) { for (k in h) { return k } }; f({\"z\": 1, \"y\": 2, \"x\": 3})", "z"},
+		{"for (x in [1, 2]) { for (y in [3, 4]) { break }; }; 1", "1"},
+		{"let f = fn(m) { for (x in m) { append(m, x) }; m }; f(mutable([1, 2]))", "mutable([1, 2, 1, 2])"},
+		// each iteration gets its own environment
+		{"for (x in [1, 2]) { if (x == 2) { y }; let y = x }", "ERROR: identifier not found: y"},
+		{"let f = fn() { for (x in [1, 2, 3]) { try { break } finally { 0 } }; 9 }; f()", "9"},
+		{"let f = fn() { for (x in [1, 2]) { try { throw x } catch (e) { continue } }; 9 }; f()", "9"},
+		{"while (1 / 0) { 1 }", "ERROR: division by zero"},
+		{"for (x in 5) { x }", "ERROR: cannot iterate over INTEGER"},
+		{"for (x in [1]) { x + true }", "ERROR: type mismatch: INTEGER + BOOLEAN"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			testInspect(t, tt.input, tt.expected)
+		})
+	}
+}
+
+func TestAssignments(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x = 1; x = 2; x", "2"},
+		{"let x = 1; x = x + 1", "2"},
+		{"let x = 1; let y = 2; x = y = 5; x + y", "10"},
+		{"let x = 10; x += 5; x -= 3; x *= 2; x /= 4; x", "6"},
+		{`let s = "a"; s += "b"; s`, "ab"},
+		{"let x = 1; let f = fn() { x = 2 }; f(); x", "2"},
+		{"let x = 1; let f = fn() { let x = 5; x = 2 }; f(); x", "1"},
+		{"let counter = fn() { let n = 0; fn() { n += 1 } }; let c = counter(); c(); c(); c()", "3"},
+		// a reassigned key keeps its position, a new one goes last
+		{`let h = {"a": 1, "b": 2}; h["a"] = 3; h["c"] = 4; h`, "{a: 3, b: 2, c: 4}"},
+		// arrays are values, changing k changes what k holds, not the key
+		{`let k = [1, [2]]; let h = {k: 1}; k[0] = 5; k[1][0] = 6; h[[1, [2]]]`, "1"},
+		{`let h = {"a": 1, "b": 2}; let n = 0; for (k in h) { delete(h, "b"); n += 1 }; n`, "2"},
+		{"let i = 0; let s = 0; while (i < 5) { i += 1; s += i }; s", "15"},
+		{"let s = 0; for (x in [1, 2, 3]) { s += x }; s", "6"},
+		{"let a = [1, 2, 3]; a[1] = 5; a", "[1, 5, 3]"},
+		{"let a = [1, 2, 3]; a[2] *= 10; a", "[1, 2, 30]"},
+		{`let h = {"a": 1}; h["b"] = 2; h["a"] += 10; h["a"] + h["b"]`, "13"},
+		{"let a = [[1], [2]]; a[1][0] = 7; a", "[[1], [7]]"},
+		{"let a = [1, 2]; let b = a[:]; b[0] = 9; a", "[1, 2]"},
+		// arrays are values, assigning an element updates the variable only
+		{"let a = [1, 2]; let b = a; b[0] = 9; [a, b]", "[[1, 2], [9, 2]]"},
+		{"let a = [1]; let f = fn() { a[0] = 2 }; f(); a", "[2]"},
+		{"let a = [1]; let f = fn(x) { x[0] = 2; x }; [f(a), a]", "[[2], [1]]"},
+		{`let h = {"a": [1, [2]]}; h["a"][1][0] = 3; h`, "{a: [1, [3]]}"},
+		{"let a = range(100); let b = a; for (i in range(100)) { b[i] = 0 }; [a[99], b[99], len(b)]", "[99, 0, 100]"},
+		{"let a = [[1]]; let b = a[0]; b[0] = 5; a", "[[1]]"},
+		{"const a = [1]; a[0] = 2", "ERROR: assignment to constant: a"},
+		{"let f = fn() { [1] }; f()[0] = 2", "ERROR: cannot assign to an element of f()"},
+		{"let m = mutable([1, 2]); let n = m; n[0] = 9; m", "mutable([9, 2])"},
+		{"let m = mutable([[1]]); m[0][0] = 2; m", "mutable([[2]])"},
+		{"let m = mutable([1]); m[1] = 2", "ERROR: index out of range: 1"},
+		{"let f = 0; f = fn() { 1 }; f()", "1"},
+		{"const c = 1; c", "1"},
+		{"const c = 1; let f = fn() { let c = 2; c }; f()", "2"},
+		{"x = 1", "ERROR: assignment to undeclared variable: x"},
+		{"x += 1", "ERROR: identifier not found: x"},
+		{"const c = 1; c = 2", "ERROR: assignment to constant: c"},
+		{"const c = 1; let f = fn() { c += 1 }; f()", "ERROR: assignment to constant: c"},
+		{"const c = 1; let c = 2", "ERROR: cannot redeclare constant: c"},
+		{"let x = 1; x += true", "ERROR: type mismatch: INTEGER + BOOLEAN"},
+		{"let a = [1]; a[1] = 2", "ERROR: index out of range: 1"},
+		{"let a = [1]; a[-1] = 2", "ERROR: index out of range: -1"},
+		{`let a = [1]; a["0"] = 2`, "ERROR: array index must be INTEGER, got STRING"},
+		{`let h = {}; h[fn() {}] = 1`, "ERROR: unusable as hash key: FUNCTION"},
+		{`let s = "abc"; s[0] = "x"`, "ERROR: index assignment not supported: STRING"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			testInspect(t, tt.input, tt.expected)
+		})
+	}
+}
+
+func TestLoopErrorPosition(t *testing.T) {
+	evaluated := testEval("let a = 1;\nfor (x in a) { x }")
+	errObj, ok := evaluated.(*Error)
+	require.True(t, ok, "got=%T", evaluated)
+	require.Equal(t, "2:11", errObj.Pos.String())
+}
+
+func TestErrorStackTrace(t *testing.T) {
+	input := `let inner = fn(x) { x + true };
+let outer = fn(x) { inner(x) };
+try { outer(1) } catch (e) { error_stack(e) }`
+	evaluated := testEval(input)
+	require.Equal(t, `[inner (1:21), outer (2:21), <main> (3:7)]`, evaluated.Inspect())
+
+	evaluated = testEval(`let f = fn() { throw "up" }; let g = fn() { f() }; g()`)
+	errObj, ok := evaluated.(*Error)
+	require.True(t, ok, "got=%T (%+v)", evaluated, evaluated)
+	require.Equal(t, "\tat f (1:16)\n\tat g (1:45)\n\tat <main> (1:52)\n", errObj.StackTrace())
+}
+
 // helper functions ----------------------------------------------------------
 
+// testInspect evaluates input and requires it to print as expected. Errors
+// print as "ERROR: " and their message, leaving out the position, which
+// differs between the backends.
+func testInspect(t *testing.T, input, expected string) Object {
+	t.Helper()
+	evaluated := testEval(input)
+	got := evaluated.Inspect()
+	if errObj, ok := evaluated.(*Error); ok {
+		got = "ERROR: " + errObj.Message
+	}
+	require.Equal(t, expected, got)
+	return evaluated
+}
+
 func testBooleanObject(t *testing.T, obj Object, expected bool) bool {
 	result, ok := obj.(*BooleanObject)
 	if !ok {
@@ -480,7 +1151,9 @@ func testBooleanObject(t *testing.T, obj Object, expected bool) bool {
 	return true
 }
 
-func testEval(input string) Object {
+// testEval runs input through the backend under test, the tree walking
+// Eval by default. vm_suite_test.go swaps it to run these cases on the VM.
+var testEval = func(input string) Object {
 	l := NewLexer(input)
 	p := NewParser(l)
 	program := p.ParseProgram()
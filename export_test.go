@@ -0,0 +1,51 @@
+package monkey_interpreter
+
+import "testing"
+
+// EvaluatorSuite lists the evaluator tests that don't depend on the tree
+// walking Eval, so vm_suite_test.go can run them on the bytecode VM. Left
+// out are TestLoops, TestLoopErrorPosition, TestAssignments, TestTryCatch
+// and TestErrorStackTrace, which use constructs the compiler doesn't
+// support, and TestErrorPositions and TestFunctionObject, which inspect
+// interpreter internals.
+var EvaluatorSuite = []struct {
+	Name string
+	Test func(*testing.T)
+}{
+	{"IntegerExpression", TestEvalIntegerExpression},
+	{"FloatExpression", TestEvalFloatExpression},
+	{"BigIntegers", TestEvalBigIntegers},
+	{"Operators", TestEvalOperators},
+	{"BooleanExpression", TestEvalBooleanExpression},
+	{"BangOperator", TestBangOperator},
+	{"IfElseExpressions", TestIfElseExpressions},
+	{"ReturnStatements", TestParseReturnStatements},
+	{"ErrorHandling", TestErrorHandling},
+	{"LetStatements", TestEvaluateLetStatements},
+	{"FunctionApplication", TestFunctionApplication},
+	{"FunctionsWithoutValue", TestFunctionsWithoutValue},
+	{"Closures", TestClosures},
+	{"StringLiteral", TestStringLiteral},
+	{"StringConcatenation", TestStringConcatenation},
+	{"StringInterpolation", TestStringInterpolation},
+	{"UnicodeStrings", TestUnicodeStrings},
+	{"BuiltinFunctions", TestBuiltinFunctions},
+	{"ArrayLiterals", TestArrayLiterals},
+	{"ArrayIndexExpressions", TestArrayIndexExpressions},
+	{"HashLiterals", TestHashLiterals},
+	{"HashIndexExpressions", TestHashIndexExpressions},
+	{"HashOrder", TestHashOrder},
+	{"HashBuiltins", TestHashBuiltins},
+	{"CollectionBuiltins", TestCollectionBuiltins},
+	{"SortBuiltins", TestSortBuiltins},
+	{"ArrayValues", TestArrayValues},
+	{"StringBuiltins", TestStringBuiltins},
+}
+
+// SetTestEval makes the evaluator tests run input through eval instead of
+// Eval, until restore is called
+func SetTestEval(eval func(input string) Object) (restore func()) {
+	previous := testEval
+	testEval = eval
+	return func() { testEval = previous }
+}
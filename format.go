@@ -0,0 +1,119 @@
+package monkey_interpreter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxFormatWidth bounds the width and precision of a format verb, so
+// `format` can't be made to build huge strings from a short format
+const maxFormatWidth = 1 << 16
+
+// formatVerbs lists the verbs formatArg knows
+const formatVerbs = "dxXobfegsvqt"
+
+// formatString implements the `format` builtin, printf-style: verbs are
+// %d, %x, %X, %o and %b for integers, %f, %e and %g for numbers, %s and %v
+// for any value as printed by puts, %q for strings, %t for booleans and %%
+// for a percent sign. Flags, width and precision work as in Go.
+func formatString(interp *Interpreter, format string, args []Object) Object {
+	var out strings.Builder
+	next := 0
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			out.WriteByte(format[i])
+			continue
+		}
+		// flags, width and precision, up to the verb
+		start := i
+		i++
+		for i < len(format) && strings.IndexByte("+- #0", format[i]) >= 0 {
+			i++
+		}
+		width, end := formatNumber(format, i)
+		i = end
+		precision := 0
+		if i < len(format) && format[i] == '.' {
+			precision, i = formatNumber(format, i+1)
+		}
+		if i >= len(format) {
+			return newError("format ends with an incomplete verb %q", format[start:])
+		}
+		if width > maxFormatWidth || precision > maxFormatWidth {
+			return newError("format width too large in %q", format[start:i+1])
+		}
+		verb := format[i]
+		if verb == '%' {
+			out.WriteByte('%')
+			continue
+		}
+		if strings.IndexByte(formatVerbs, verb) < 0 {
+			r, _ := utf8.DecodeRuneInString(format[i:])
+			return newError("unknown format verb %%%c", r)
+		}
+		if next >= len(args) {
+			return newError("format verb %%%c has no argument", verb)
+		}
+		arg, err := formatArg(verb, args[next])
+		if err != nil {
+			return err
+		}
+		next++
+		fmt.Fprintf(&out, format[start:i+1], arg)
+		if err := interp.checkStringLen(out.Len()); err != nil {
+			return err
+		}
+	}
+	if next < len(args) {
+		return newError("format has %d unused arguments", len(args)-next)
+	}
+	return &String{Value: out.String()}
+}
+
+// formatNumber reads the decimal number starting at format[i], if any,
+// returning it and the index after it. Overlong numbers read as too large.
+func formatNumber(format string, i int) (int, int) {
+	start := i
+	for i < len(format) && format[i] >= '0' && format[i] <= '9' {
+		i++
+	}
+	n, err := strconv.Atoi(format[start:i])
+	if err != nil && i > start {
+		n = maxFormatWidth + 1
+	}
+	return n, i
+}
+
+// formatArg returns the Go value printed by verb for arg
+func formatArg(verb byte, arg Object) (any, *Error) {
+	switch verb {
+	case 'd', 'x', 'X', 'o', 'b':
+		switch arg := arg.(type) {
+		case *Integer:
+			return arg.Value, nil
+		case *BigInteger:
+			return arg.Value, nil
+		}
+		return nil, newError("format verb %%%c needs INTEGER, got %s", verb, arg.Type())
+	case 'f', 'e', 'g':
+		if !isNumber(arg) {
+			return nil, newError("format verb %%%c needs FLOAT or INTEGER, got %s", verb, arg.Type())
+		}
+		return toFloat(arg), nil
+	case 's', 'v':
+		return arg.Inspect(), nil
+	case 'q':
+		if str, ok := arg.(*String); ok {
+			return str.Value, nil
+		}
+		return nil, newError("format verb %%q needs STRING, got %s", arg.Type())
+	case 't':
+		if b, ok := arg.(*BooleanObject); ok {
+			return b.Value, nil
+		}
+		return nil, newError("format verb %%t needs BOOLEAN, got %s", arg.Type())
+	}
+	return nil, newError("unknown format verb %%%c", verb)
+}
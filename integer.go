@@ -0,0 +1,212 @@
+package monkey_interpreter
+
+import (
+	"hash/fnv"
+	"math"
+	"math/big"
+)
+
+// Integer arithmetic is done on int64 while the result fits and on big.Int
+// once it doesn't. Results are normalized, so an INTEGER is an *Integer
+// whenever its value fits in an int64 and a *BigInteger only when not.
+
+// newInteger returns v as an *Integer if it fits, or a *BigInteger
+func newInteger(v *big.Int) Object {
+	if v.IsInt64() {
+		return &Integer{Value: v.Int64()}
+	}
+	return &BigInteger{Value: v}
+}
+
+// toBig returns the value of an *Integer or *BigInteger. The result must
+// not be modified.
+func toBig(obj Object) *big.Int {
+	if i, ok := obj.(*Integer); ok {
+		return big.NewInt(i.Value)
+	}
+	return obj.(*BigInteger).Value
+}
+
+// addInt64, subInt64 and mulInt64 report false when the result overflows
+func addInt64(a, b int64) (int64, bool) {
+	c := a + b
+	return c, (c > a) == (b > 0)
+}
+
+func subInt64(a, b int64) (int64, bool) {
+	c := a - b
+	return c, (c < a) == (b > 0)
+}
+
+func mulInt64(a, b int64) (int64, bool) {
+	if a == 0 || b == 0 {
+		return 0, true
+	}
+	c := a * b
+	if (a == -1 && b == math.MinInt64) || (b == -1 && a == math.MinInt64) {
+		return c, false
+	}
+	return c, c/b == a
+}
+
+func evalIntegerInfixExpression(operator string, left, right Object) Object {
+	l, lok := left.(*Integer)
+	r, rok := right.(*Integer)
+	if !lok || !rok {
+		return evalBigIntegerInfixExpression(operator, left, right)
+	}
+	leftVal, rightVal := l.Value, r.Value
+	switch operator {
+	case "+":
+		if v, ok := addInt64(leftVal, rightVal); ok {
+			return &Integer{Value: v}
+		}
+	case "-":
+		if v, ok := subInt64(leftVal, rightVal); ok {
+			return &Integer{Value: v}
+		}
+	case "*":
+		if v, ok := mulInt64(leftVal, rightVal); ok {
+			return &Integer{Value: v}
+		}
+	case "/":
+		if rightVal == 0 {
+			return newError("division by zero")
+		}
+		if leftVal != math.MinInt64 || rightVal != -1 {
+			return &Integer{Value: leftVal / rightVal}
+		}
+	case "%":
+		if rightVal == 0 {
+			return newError("division by zero")
+		}
+		return &Integer{Value: leftVal % rightVal}
+	case "&":
+		return &Integer{Value: leftVal & rightVal}
+	case "|":
+		return &Integer{Value: leftVal | rightVal}
+	case "^":
+		return &Integer{Value: leftVal ^ rightVal}
+	case "<<":
+		if err := checkShift(rightVal); err != nil {
+			return err
+		}
+		if rightVal < 63 {
+			if v := leftVal << rightVal; v>>rightVal == leftVal {
+				return &Integer{Value: v}
+			}
+		}
+	case ">>":
+		if err := checkShift(rightVal); err != nil {
+			return err
+		}
+		if rightVal > 63 {
+			rightVal = 63
+		}
+		return &Integer{Value: leftVal >> rightVal}
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+	// the result overflowed an int64
+	return evalBigIntegerInfixExpression(operator, left, right)
+}
+
+func evalBigIntegerInfixExpression(operator string, left, right Object) Object {
+	leftVal := toBig(left)
+	rightVal := toBig(right)
+	switch operator {
+	case "+":
+		return newInteger(new(big.Int).Add(leftVal, rightVal))
+	case "-":
+		return newInteger(new(big.Int).Sub(leftVal, rightVal))
+	case "*":
+		return newInteger(new(big.Int).Mul(leftVal, rightVal))
+	case "/":
+		if rightVal.Sign() == 0 {
+			return newError("division by zero")
+		}
+		// Quo and Rem truncate like Go's int64 operators
+		return newInteger(new(big.Int).Quo(leftVal, rightVal))
+	case "%":
+		if rightVal.Sign() == 0 {
+			return newError("division by zero")
+		}
+		return newInteger(new(big.Int).Rem(leftVal, rightVal))
+	case "&":
+		return newInteger(new(big.Int).And(leftVal, rightVal))
+	case "|":
+		return newInteger(new(big.Int).Or(leftVal, rightVal))
+	case "^":
+		return newInteger(new(big.Int).Xor(leftVal, rightVal))
+	case "<<", ">>":
+		if !rightVal.IsInt64() {
+			return checkShift(int64(rightVal.Sign()) * math.MaxInt64)
+		}
+		n := rightVal.Int64()
+		if err := checkShift(n); err != nil {
+			return err
+		}
+		if operator == "<<" {
+			return newInteger(new(big.Int).Lsh(leftVal, uint(n)))
+		}
+		// Rsh rounds towards negative infinity like Go's >> on int64
+		return newInteger(new(big.Int).Rsh(leftVal, uint(n)))
+	case "<":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) < 0)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) > 0)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) <= 0)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) >= 0)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) == 0)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) != 0)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+// maxShift bounds shift counts, so 1 << n can't exhaust memory
+const maxShift = 1 << 16
+
+func checkShift(n int64) *Error {
+	switch {
+	case n < 0:
+		return newError("negative shift count: %d", n)
+	case n > maxShift:
+		return newError("shift count too large: %d", n)
+	}
+	return nil
+}
+
+func negateInteger(obj Object) Object {
+	if i, ok := obj.(*Integer); ok && i.Value != math.MinInt64 {
+		return &Integer{Value: -i.Value}
+	}
+	return newInteger(new(big.Int).Neg(toBig(obj)))
+}
+
+// bigIntegerHashKey hashes the value's sign and magnitude. Values that fit
+// an int64 never get here, they are always *Integer.
+func bigIntegerHashKey(v *big.Int) HashKey {
+	h := fnv.New64a()
+	if v.Sign() < 0 {
+		_, _ = h.Write([]byte{'-'})
+	}
+	_, _ = h.Write(v.Bytes())
+	return HashKey{Type: INT_OBJ_TYPE, Value: h.Sum64()}
+}
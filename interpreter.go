@@ -0,0 +1,205 @@
+package monkey_interpreter
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Interpreter is an isolated Monkey runtime for embedding in Go programs.
+// It owns its global environment, its builtins and its I/O, so several
+// interpreters in one process don't affect each other. An Interpreter must
+// not be used by several goroutines at once.
+type Interpreter struct {
+	// Stdin, Stdout and Stderr are used by the gets, puts and eputs
+	// builtins. nil means the process' standard streams.
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+
+	// MaxSteps bounds the number of nodes evaluated by each call to Eval,
+	// EvalProgram or Call, zero means no limit
+	MaxSteps int64
+	// MaxCallDepth bounds nested function calls, zero means
+	// DefaultMaxCallDepth
+	MaxCallDepth int
+	// MaxArrayLen and MaxStringLen bound the number of elements of arrays
+	// and bytes of strings built by scripts, zero means no limit, except
+	// for `range` which then stops at DefaultMaxRangeLen
+	MaxArrayLen  int
+	MaxStringLen int
+	// ParseCache, if not nil, caches the programs parsed by EvalFile
+	ParseCache *ParseCache
+
+	env      *Environment
+	builtins map[string]*Builtin
+	run      run
+
+	// stdinReader buffers Stdin across calls to gets
+	stdinReader *bufio.Reader
+	stdinSource io.Reader
+}
+
+func NewInterpreter() *Interpreter {
+	i := &Interpreter{}
+	i.builtins = newBuiltins(i)
+	i.env = NewEnvironment()
+	i.env.interp = i
+	return i
+}
+
+// ParseError is returned for source with syntax errors
+type ParseError struct {
+	Diagnostics []Diagnostic
+}
+
+func (e *ParseError) Error() string {
+	messages := make([]string, len(e.Diagnostics))
+	for i, d := range e.Diagnostics {
+		messages[i] = d.String()
+	}
+	return strings.Join(messages, "\n")
+}
+
+// Env returns the global environment
+func (i *Interpreter) Env() *Environment { return i.env }
+
+// RegisterBuiltin makes fn callable from scripts as name. Bindings made by
+// scripts shadow builtins.
+func (i *Interpreter) RegisterBuiltin(name string, fn BuiltinFunction) {
+	i.builtins[name] = &Builtin{Fn: fn}
+}
+
+func (i *Interpreter) SetGlobal(name string, val Object) {
+	i.env.Set(name, val)
+}
+
+func (i *Interpreter) GetGlobal(name string) (Object, bool) {
+	return i.env.Get(name)
+}
+
+// Eval runs source in the global environment. Syntax errors are returned
+// as a *ParseError, runtime errors as an *Error.
+func (i *Interpreter) Eval(source string) (Object, error) {
+	return i.EvalContext(context.Background(), source)
+}
+
+// EvalContext is like Eval, but aborts once ctx is done. The returned
+// *Error then wraps ctx.Err().
+func (i *Interpreter) EvalContext(ctx context.Context, source string) (Object, error) {
+	return i.evalSource(ctx, nil, "<eval>", source)
+}
+
+// EvalFile is like Eval, filename is used in error positions. The program
+// is parsed through ParseCache if it is set.
+func (i *Interpreter) EvalFile(filename, source string) (Object, error) {
+	return i.evalSource(context.Background(), i.ParseCache, filename, source)
+}
+
+func (i *Interpreter) evalSource(ctx context.Context, cache *ParseCache, filename, source string) (Object, error) {
+	program, diagnostics := parseSource(cache, filename, source)
+	if len(diagnostics) != 0 {
+		return nil, &ParseError{Diagnostics: diagnostics}
+	}
+	return i.EvalProgramContext(ctx, program)
+}
+
+// EvalProgram runs an already parsed program in the global environment
+func (i *Interpreter) EvalProgram(program *Program) (Object, error) {
+	return i.EvalProgramContext(context.Background(), program)
+}
+
+// EvalProgramContext is like EvalProgram, but aborts once ctx is done
+func (i *Interpreter) EvalProgramContext(ctx context.Context, program *Program) (Object, error) {
+	return result(i.evalProgram(ctx, program))
+}
+
+// evalProgram runs program and returns its value as Eval does, nil if it
+// ends in a statement without a value
+func (i *Interpreter) evalProgram(ctx context.Context, program *Program) Object {
+	defer i.begin(ctx)()
+	return Eval(program, i.env)
+}
+
+// Call invokes a *Function, *Builtin or Callable, typically a callback
+// handed to the host by a script, with args. Runtime errors are returned as
+// an *Error.
+func Call(fn Object, args ...Object) (Object, error) {
+	return CallContext(context.Background(), fn, args...)
+}
+
+// CallContext is like Call, but aborts once ctx is done
+func CallContext(ctx context.Context, fn Object, args ...Object) (Object, error) {
+	if fn, ok := fn.(*Function); ok {
+		defer fn.Env.interp.begin(ctx)()
+	}
+	return result(applyFunction(fn, args))
+}
+
+// result converts the outcome of an evaluation to Go conventions
+func result(obj Object) (Object, error) {
+	if errObj, ok := obj.(*Error); ok {
+		return nil, errObj
+	}
+	if obj == nil {
+		return NULL_OBJ, nil
+	}
+	return obj, nil
+}
+
+// Builtin looks up a builtin by name, including those added with
+// RegisterBuiltin. Bare interpreters use the shared default table.
+func (i *Interpreter) Builtin(name string) (*Builtin, bool) {
+	if i == nil || i.builtins == nil {
+		b, ok := builtins[name]
+		return b, ok
+	}
+	b, ok := i.builtins[name]
+	return b, ok
+}
+
+// BuiltinNames returns the names Builtin knows, sorted
+func (i *Interpreter) BuiltinNames() []string {
+	table := builtins
+	if i != nil && i.builtins != nil {
+		table = i.builtins
+	}
+	names := make([]string, 0, len(table))
+	for name := range table {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (i *Interpreter) stdout() io.Writer {
+	if i == nil || i.Stdout == nil {
+		return os.Stdout
+	}
+	return i.Stdout
+}
+
+func (i *Interpreter) stderr() io.Writer {
+	if i == nil || i.Stderr == nil {
+		return os.Stderr
+	}
+	return i.Stderr
+}
+
+// processStdin is shared by everything reading the process' stdin, so no
+// buffered input is lost between readers
+var processStdin = bufio.NewReader(os.Stdin)
+
+func (i *Interpreter) stdin() *bufio.Reader {
+	if i == nil || i.Stdin == nil {
+		return processStdin
+	}
+	if i.stdinReader == nil || i.stdinSource != i.Stdin {
+		i.stdinReader = bufio.NewReader(i.Stdin)
+		i.stdinSource = i.Stdin
+	}
+	return i.stdinReader
+}
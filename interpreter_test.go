@@ -0,0 +1,160 @@
+package monkey_interpreter
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInterpreterEval(t *testing.T) {
+	interp := NewInterpreter()
+	result, err := interp.Eval("let a = 5; a * 2")
+	require.NoError(t, err)
+	testIntegerObject(t, result, 10)
+
+	// globals persist between calls
+	result, err = interp.Eval("a + 1")
+	require.NoError(t, err)
+	testIntegerObject(t, result, 6)
+
+	result, err = interp.Eval("let b = 1;")
+	require.NoError(t, err)
+	testNullObject(t, result)
+}
+
+func TestInterpreterErrors(t *testing.T) {
+	interp := NewInterpreter()
+	_, err := interp.Eval("let = 1;")
+	parseErr, ok := err.(*ParseError)
+	require.True(t, ok, "expected *ParseError, got %T", err)
+	require.Len(t, parseErr.Diagnostics, 1)
+	require.Equal(t, "<eval>:1:5: error: expected next token to be IDENT, got = instead", err.Error())
+
+	_, err = interp.EvalFile("main.mk", "\n  missing")
+	runtimeErr, ok := err.(*Error)
+	require.True(t, ok, "expected *Error, got %T", err)
+	require.Equal(t, "identifier not found: missing", runtimeErr.Message)
+	require.Equal(t, "main.mk:2:3: identifier not found: missing", err.Error())
+}
+
+func TestInterpreterParseCache(t *testing.T) {
+	dir := t.TempDir()
+	interp := NewInterpreter()
+	interp.ParseCache = NewParseCache(dir)
+	result, err := interp.EvalFile("main.mk", "let a = 20; a * 2")
+	require.NoError(t, err)
+	testIntegerObject(t, result, 40)
+	entries, err := filepath.Glob(filepath.Join(dir, "*.mkast"))
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+
+	// swap the cached program, a hit runs it instead of parsing the source
+	data, err := EncodeProgram(NewParser(NewLexer("a + 1")).ParseProgram())
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(entries[0], data, 0o644))
+	result, err = interp.EvalFile("main.mk", "let a = 20; a * 2")
+	require.NoError(t, err)
+	testIntegerObject(t, result, 21)
+
+	// Eval is not cached
+	_, err = interp.Eval("a")
+	require.NoError(t, err)
+	entries, err = filepath.Glob(filepath.Join(dir, "*.mkast"))
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+}
+
+func TestInterpreterGlobals(t *testing.T) {
+	interp := NewInterpreter()
+	interp.SetGlobal("limit", &Integer{Value: 3})
+	_, err := interp.Eval(`let doubled = limit * 2;`)
+	require.NoError(t, err)
+	doubled, ok := interp.GetGlobal("doubled")
+	require.True(t, ok)
+	testIntegerObject(t, doubled, 6)
+	_, ok = interp.GetGlobal("nope")
+	require.False(t, ok)
+}
+
+func TestInterpretersAreIsolated(t *testing.T) {
+	var out1, out2, errOut bytes.Buffer
+	first := NewInterpreter()
+	first.Stdout = &out1
+	first.Stderr = &errOut
+	first.Stdin = strings.NewReader("line one\nline two")
+	second := NewInterpreter()
+	second.Stdout = &out2
+
+	first.RegisterBuiltin("answer", func(args ...Object) Object {
+		return &Integer{Value: 42}
+	})
+
+	_, err := first.Eval(`puts(answer()); eputs("oops"); puts(gets()); puts(gets()); puts(gets())`)
+	require.NoError(t, err)
+	require.Equal(t, "42\nline one\nline two\nnull\n", out1.String())
+	require.Equal(t, "oops\n", errOut.String())
+
+	_, err = second.Eval(`puts("hi"); answer()`)
+	require.EqualError(t, err, "<eval>:1:13: identifier not found: answer")
+	require.Equal(t, "hi\n", out2.String())
+
+	// functions without a value print as null
+	out1.Reset()
+	_, err = first.Eval(`let f = fn() {}; puts(f())`)
+	require.NoError(t, err)
+	require.Equal(t, "null\n", out1.String())
+
+	// scripts can shadow builtins
+	result, err := first.Eval(`let answer = 1; answer`)
+	require.NoError(t, err)
+	testIntegerObject(t, result, 1)
+}
+
+func TestCall(t *testing.T) {
+	interp := NewInterpreter()
+	var out bytes.Buffer
+	interp.Stdout = &out
+	_, err := interp.Eval(`
+	let total = 0;
+	let add = fn(a, b) { if (a > b) { return a - b; } a + b };
+	let log = fn(msg) { puts(msg) };
+	let fail = fn() { 1 + true };
+	`)
+	require.NoError(t, err)
+	get := func(name string) Object {
+		obj, ok := interp.GetGlobal(name)
+		require.True(t, ok, name)
+		return obj
+	}
+
+	result, err := Call(get("add"), &Integer{Value: 2}, &Integer{Value: 3})
+	require.NoError(t, err)
+	testIntegerObject(t, result, 5)
+
+	// return values are unwrapped
+	result, err = Call(get("add"), &Integer{Value: 5}, &Integer{Value: 3})
+	require.NoError(t, err)
+	testIntegerObject(t, result, 2)
+
+	// callbacks see the interpreter's builtins and I/O
+	result, err = Call(get("log"), &String{Value: "called back"})
+	require.NoError(t, err)
+	testNullObject(t, result)
+	require.Equal(t, "called back\n", out.String())
+
+	lenFn, _ := interp.Builtin("len")
+	result, err = Call(lenFn, &String{Value: "four"})
+	require.NoError(t, err)
+	testIntegerObject(t, result, 4)
+
+	_, err = Call(get("fail"))
+	require.EqualError(t, err, "<eval>:5:20: type mismatch: INTEGER + BOOLEAN")
+	_, err = Call(get("add"), &Integer{Value: 1})
+	require.EqualError(t, err, "wrong number of arguments: want=2, got=1")
+	_, err = Call(get("total"))
+	require.EqualError(t, err, "not a function: INTEGER")
+}
@@ -1,48 +1,226 @@
 package monkey_interpreter
 
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
 type Lexer struct {
+	filename     string
 	input        string
 	position     int  // current position in input (points to current char)
 	readPosition int  // current reading position in input (after current char)
-	ch           byte // current char under examination
+	ch           rune // current char under examination
+	line         int  // line of the current char
+	column       int  // column of the current char
+	// interpolations holds the number of unclosed braces in each
+	// interpolation, ${...}, being read, innermost last
+	interpolations []int
+	// keepComments attaches comments to the token following them
+	keepComments bool
 }
 
 func NewLexer(input string) *Lexer {
-	l := Lexer{input: input}
+	return NewFileLexer("", input)
+}
+
+// NewFileLexer is like NewLexer, but stamps every token position with filename
+func NewFileLexer(filename, input string) *Lexer {
+	l := Lexer{filename: filename, input: input, line: 1}
 	l.readChar()
 	return &l
 }
 
-// readChar sets char to current read position and advances lexer cursor
+// KeepComments makes the lexer attach the comments preceding each token to
+// it, as Token.Comments, for tools such as formatters. By default comments
+// are dropped. It must be called before the first token is read.
+func (l *Lexer) KeepComments() { l.keepComments = true }
+
+// readChar decodes the rune at the read position and advances the lexer
+// cursor past it. Columns count runes, offsets count bytes.
 func (l *Lexer) readChar() {
+	if l.ch == '\n' {
+		l.line++
+		l.column = 1
+	} else {
+		l.column++
+	}
+	size := 1
 	if l.readPosition >= len(l.input) {
 		l.ch = 0
 	} else {
-		l.ch = l.input[l.readPosition]
+		l.ch, size = utf8.DecodeRuneInString(l.input[l.readPosition:])
 	}
 	l.position = l.readPosition
-	l.readPosition += 1
+	l.readPosition += size
+}
+
+// invalidUTF8 reports whether the current char is a byte that isn't valid
+// UTF-8, rather than a decoded U+FFFD
+func (l *Lexer) invalidUTF8() bool {
+	return l.ch == utf8.RuneError && l.readPosition-l.position == 1
 }
 
-// readString reads in a string
-func (l *Lexer) readString() string {
-	position := l.position + 1
+// readString reads a double quoted string, starting at the opening quote
+// or at the '}' ending an interpolation. It stops at the closing quote, or
+// at the '{' of the next interpolation. Strings without interpolations are
+// a STRING, the others a TEMPLATE_HEAD, TEMPLATE_MIDDLE... and a
+// TEMPLATE_TAIL, with the interpolated expressions in between.
+func (l *Lexer) readString() Token {
+	head := l.ch == '"'
+	var (
+		out strings.Builder
+		err string
+	)
 	for {
-		// read characters until the end
 		l.readChar()
-		if l.ch == '"' || l.ch == 0 {
-			break
+		switch {
+		case l.ch == 0:
+			return Token{Type: ILLEGAL, Literal: out.String(), Error: "unterminated string literal"}
+		case l.ch == '"':
+			tok := Token{Type: TEMPLATE_TAIL, Literal: out.String()}
+			if head {
+				tok.Type = STRING
+			}
+			return l.checkString(tok, err)
+		case l.ch == '$' && l.peekChar() == '{':
+			l.readChar()
+			l.interpolations = append(l.interpolations, 0)
+			tok := Token{Type: TEMPLATE_MIDDLE, Literal: out.String()}
+			if head {
+				tok.Type = TEMPLATE_HEAD
+			}
+			return l.checkString(tok, err)
+		case l.ch == '\\':
+			if e := l.readEscape(&out); e != "" && err == "" {
+				err = e
+			}
+		default:
+			// copied as is, so invalid UTF-8 survives
+			out.WriteString(l.input[l.position:l.readPosition])
+		}
+	}
+}
+
+// checkString turns tok into an ILLEGAL token if it contains an invalid
+// escape sequence
+func (l *Lexer) checkString(tok Token, err string) Token {
+	if err != "" {
+		tok.Type = ILLEGAL
+		tok.Error = err
+	}
+	return tok
+}
+
+var escapes = map[rune]rune{
+	'n':  '\n',
+	't':  '\t',
+	'r':  '\r',
+	'0':  0,
+	'\\': '\\',
+	'"':  '"',
+	'$':  '$',
+}
+
+// readEscape decodes the escape sequence starting at the current char, a
+// backslash, into out. It reports what is wrong with invalid ones.
+func (l *Lexer) readEscape(out *strings.Builder) string {
+	if l.peekChar() == 0 {
+		// leave the EOF for readString to report
+		return ""
+	}
+	l.readChar()
+	if ch, ok := escapes[l.ch]; ok {
+		out.WriteRune(ch)
+		return ""
+	}
+	if l.ch != 'u' {
+		return fmt.Sprintf("invalid escape sequence %q in string literal", "\\"+string(l.ch))
+	}
+	// \u is followed by four hex digits, or by any number of them in braces
+	var digits string
+	if l.peekChar() == '{' {
+		l.readChar()
+		start := l.readPosition
+		for isHexDigit(l.peekChar()) {
+			l.readChar()
+		}
+		digits = l.input[start:l.readPosition]
+		if l.peekChar() != '}' || digits == "" || len(digits) > 6 {
+			return fmt.Sprintf("invalid escape sequence %q in string literal", "\\u{"+digits)
+		}
+		l.readChar()
+	} else {
+		start := l.readPosition
+		for i := 0; i < 4 && isHexDigit(l.peekChar()); i++ {
+			l.readChar()
+		}
+		digits = l.input[start:l.readPosition]
+		if len(digits) != 4 {
+			return fmt.Sprintf("invalid escape sequence %q in string literal", "\\u"+digits)
+		}
+	}
+	var r rune
+	for _, d := range digits {
+		r = r<<4 | hexValue(d)
+	}
+	if !utf8.ValidRune(r) {
+		return fmt.Sprintf("invalid Unicode code point U+%X in string literal", r)
+	}
+	out.WriteRune(r)
+	return ""
+}
+
+func isHexDigit(ch rune) bool {
+	return isDigit(ch) || 'a' <= ch && ch <= 'f' || 'A' <= ch && ch <= 'F'
+}
+
+func hexValue(ch rune) rune {
+	switch {
+	case isDigit(ch):
+		return ch - '0'
+	case 'a' <= ch && ch <= 'f':
+		return ch - 'a' + 10
+	}
+	return ch - 'A' + 10
+}
+
+// readRawString reads a backquoted string, which can span lines and has
+// neither escapes nor interpolations
+func (l *Lexer) readRawString() Token {
+	start := l.position + 1
+	for {
+		l.readChar()
+		switch l.ch {
+		case 0:
+			return Token{Type: ILLEGAL, Literal: l.input[start:l.position], Error: "unterminated raw string literal"}
+		case '`':
+			return Token{Type: STRING, Literal: l.input[start:l.position]}
 		}
 	}
-	return l.input[position:l.position]
 }
 
 // peekChar is like readChar, but doesnt increment reader
-func (l *Lexer) peekChar() byte {
-	if l.readPosition >= len(l.input) {
-		return 0
+func (l *Lexer) peekChar() rune {
+	return l.peekCharAt(0)
+}
+
+// peekCharAt returns the char offset places after the next one
+func (l *Lexer) peekCharAt(offset int) rune {
+	position := l.readPosition
+	for ; offset >= 0; offset-- {
+		if position >= len(l.input) {
+			return 0
+		}
+		ch, size := utf8.DecodeRuneInString(l.input[position:])
+		if offset == 0 {
+			return ch
+		}
+		position += size
 	}
-	return l.input[l.readPosition]
+	return 0
 }
 
 func (l *Lexer) readIdentifier() string {
@@ -59,29 +237,116 @@ func (l *Lexer) skipWhitespace() {
 	}
 }
 
-func isLetter(ch byte) bool {
-	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_'
+// isLetter accepts any Unicode letter, so identifiers needn't be English
+func isLetter(ch rune) bool {
+	return unicode.IsLetter(ch) || ch == '_'
 }
 
-func (l *Lexer) readNumber() string {
+// readNumber reads an INT, or a FLOAT if it has a fraction or an exponent,
+// e.g. 1.5, 2e10 or 1.5E-3
+func (l *Lexer) readNumber() (string, TokenType) {
 	startPos := l.position
+	tokType := TokenType(INT)
+	l.readDigits()
+	if l.ch == '.' && isDigit(l.peekCharAt(0)) {
+		tokType = FLOAT
+		l.readChar()
+		l.readDigits()
+	}
+	if l.ch == 'e' || l.ch == 'E' {
+		// only an exponent if digits follow, otherwise 'e' starts an identifier
+		next := l.peekCharAt(0)
+		if isDigit(next) || (next == '+' || next == '-') && isDigit(l.peekCharAt(1)) {
+			tokType = FLOAT
+			l.readChar()
+			if l.ch == '+' || l.ch == '-' {
+				l.readChar()
+			}
+			l.readDigits()
+		}
+	}
+	return l.input[startPos:l.position], tokType
+}
+
+func (l *Lexer) readDigits() {
 	for isDigit(l.ch) {
 		l.readChar()
 	}
-	return l.input[startPos:l.position]
 }
 
-func isDigit(ch byte) bool {
+func isDigit(ch rune) bool {
 	return '0' <= ch && ch <= '9'
 }
 
+// pos returns the position of the current char
+func (l *Lexer) pos() Position {
+	return Position{Filename: l.filename, Offset: l.position, Line: l.line, Column: l.column}
+}
+
 func (l *Lexer) NextToken() Token {
+	var comments []Comment
+	for {
+		l.skipWhitespace()
+		if l.ch != '/' || l.peekChar() != '/' && l.peekChar() != '*' {
+			break
+		}
+		start := l.pos()
+		text, ok := l.readComment()
+		span := Span{Start: start, End: l.pos()}
+		if !ok {
+			return Token{Type: ILLEGAL, Literal: text, Span: span, Error: "unterminated block comment"}
+		}
+		if l.keepComments {
+			comments = append(comments, Comment{Text: text, Span: span})
+		}
+	}
+	start := l.pos()
+	tok := l.readToken()
+	tok.Span = Span{Start: start, End: l.pos()}
+	tok.Comments = comments
+	return tok
+}
+
+// readComment reads a // comment up to the end of the line, or a /* */
+// comment, which may span lines. It reports false if the latter is
+// unterminated.
+func (l *Lexer) readComment() (string, bool) {
+	start := l.position
+	if l.peekChar() == '/' {
+		for l.ch != '\n' && l.ch != 0 {
+			l.readChar()
+		}
+		return l.input[start:l.position], true
+	}
+	// skip the opening /*, so /*/ isn't a whole comment
+	l.readChar()
+	l.readChar()
+	for l.ch != 0 {
+		if l.ch == '*' && l.peekChar() == '/' {
+			l.readChar()
+			l.readChar()
+			return l.input[start:l.position], true
+		}
+		l.readChar()
+	}
+	return l.input[start:l.position], false
+}
+
+// readTwoCharToken reads a token made of the current and the next char
+func (l *Lexer) readTwoCharToken(tokenType TokenType) Token {
+	ch := l.ch
+	l.readChar()
+	return Token{Type: tokenType, Literal: string(ch) + string(l.ch)}
+}
+
+// readToken reads the token starting at the current char
+func (l *Lexer) readToken() Token {
 	var tok Token
-	l.skipWhitespace()
 	switch l.ch {
 	case '"':
-		tok.Type = STRING
-		tok.Literal = l.readString()
+		tok = l.readString()
+	case '`':
+		tok = l.readRawString()
 	case ';':
 		tok = newToken(SEMICOLON, l.ch)
 	case ':':
@@ -97,13 +362,34 @@ func (l *Lexer) NextToken() Token {
 	case ',':
 		tok = newToken(COMMA, l.ch)
 	case '+':
-		tok = newToken(PLUS, l.ch)
+		if l.peekChar() == '=' {
+			tok = l.readTwoCharToken(PLUS_ASSIGN)
+		} else {
+			tok = newToken(PLUS, l.ch)
+		}
 	case '{':
+		if n := len(l.interpolations); n > 0 {
+			l.interpolations[n-1]++
+		}
 		tok = newToken(LBRACE, l.ch)
 	case '}':
+		n := len(l.interpolations)
+		if n > 0 && l.interpolations[n-1] == 0 {
+			// the end of an interpolation, the string goes on
+			l.interpolations = l.interpolations[:n-1]
+			tok = l.readString()
+			break
+		}
+		if n > 0 {
+			l.interpolations[n-1]--
+		}
 		tok = newToken(RBRACE, l.ch)
 	case '-':
-		tok = newToken(MINUS, l.ch)
+		if l.peekChar() == '=' {
+			tok = l.readTwoCharToken(MINUS_ASSIGN)
+		} else {
+			tok = newToken(MINUS, l.ch)
+		}
 	case '!':
 		// could be != or !
 		if l.peekChar() == '=' {
@@ -115,13 +401,52 @@ func (l *Lexer) NextToken() Token {
 			tok = newToken(BANG, l.ch)
 		}
 	case '*':
-		tok = newToken(ASTERISK, l.ch)
+		if l.peekChar() == '=' {
+			tok = l.readTwoCharToken(ASTERISK_ASSIGN)
+		} else {
+			tok = newToken(ASTERISK, l.ch)
+		}
 	case '/':
-		tok = newToken(SLASH, l.ch)
+		if l.peekChar() == '=' {
+			tok = l.readTwoCharToken(SLASH_ASSIGN)
+		} else {
+			tok = newToken(SLASH, l.ch)
+		}
+	case '%':
+		tok = newToken(PERCENT, l.ch)
+	case '^':
+		tok = newToken(BIT_XOR, l.ch)
 	case '<':
-		tok = newToken(LT, l.ch)
+		// could be <=, << or <
+		switch l.peekChar() {
+		case '=':
+			tok = l.readTwoCharToken(LT_EQ)
+		case '<':
+			tok = l.readTwoCharToken(SHL)
+		default:
+			tok = newToken(LT, l.ch)
+		}
 	case '>':
-		tok = newToken(GT, l.ch)
+		switch l.peekChar() {
+		case '=':
+			tok = l.readTwoCharToken(GT_EQ)
+		case '>':
+			tok = l.readTwoCharToken(SHR)
+		default:
+			tok = newToken(GT, l.ch)
+		}
+	case '&':
+		if l.peekChar() == '&' {
+			tok = l.readTwoCharToken(AND)
+		} else {
+			tok = newToken(BIT_AND, l.ch)
+		}
+	case '|':
+		if l.peekChar() == '|' {
+			tok = l.readTwoCharToken(OR)
+		} else {
+			tok = newToken(BIT_OR, l.ch)
+		}
 	case '=':
 		// could be == or =
 		if l.peekChar() == '=' {
@@ -148,11 +473,14 @@ func (l *Lexer) NextToken() Token {
 
 		// TODO: how does integer overflow work?
 		if isDigit(l.ch) {
-			tok.Type = INT
-			tok.Literal = l.readNumber()
+			tok.Literal, tok.Type = l.readNumber()
 			return tok
 		}
 		tok = newToken(ILLEGAL, l.ch)
+		if l.invalidUTF8() {
+			tok.Literal = l.input[l.position:l.readPosition]
+			tok.Error = "invalid UTF-8 encoding"
+		}
 	}
 	// after token read is finished, advance cursor
 	l.readChar()
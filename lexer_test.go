@@ -14,7 +14,7 @@ func TestNextToken(t *testing.T) {
 		x + y;
 	};
 	let result = add(five, ten);
-	!-/*5;
+	!-/ *5;
 	5 < 10 > 5;
 	if (5 < 10) {
 	   return true;
@@ -133,3 +133,247 @@ func TestNextToken(t *testing.T) {
 		require.Equal(t, tt.expectedLiteral, tok.Literal)
 	}
 }
+
+func TestNextTokenPositions(t *testing.T) {
+	input := "let x = 5;\n  x == \"ab\";"
+	expected := []struct {
+		expectedType TokenType
+		start        Position
+		end          Position
+	}{
+		{LET, Position{"a.mk", 0, 1, 1}, Position{"a.mk", 3, 1, 4}},
+		{IDENT, Position{"a.mk", 4, 1, 5}, Position{"a.mk", 5, 1, 6}},
+		{ASSIGN, Position{"a.mk", 6, 1, 7}, Position{"a.mk", 7, 1, 8}},
+		{INT, Position{"a.mk", 8, 1, 9}, Position{"a.mk", 9, 1, 10}},
+		{SEMICOLON, Position{"a.mk", 9, 1, 10}, Position{"a.mk", 10, 1, 11}},
+		{IDENT, Position{"a.mk", 13, 2, 3}, Position{"a.mk", 14, 2, 4}},
+		{EQ, Position{"a.mk", 15, 2, 5}, Position{"a.mk", 17, 2, 7}},
+		{STRING, Position{"a.mk", 18, 2, 8}, Position{"a.mk", 22, 2, 12}},
+		{SEMICOLON, Position{"a.mk", 22, 2, 12}, Position{"a.mk", 23, 2, 13}},
+	}
+	l := NewFileLexer("a.mk", input)
+	for _, tt := range expected {
+		tok := l.NextToken()
+		require.Equal(t, tt.expectedType, tok.Type)
+		require.Equal(t, tt.start, tok.Span.Start)
+		require.Equal(t, tt.end, tok.Span.End)
+	}
+	require.Equal(t, "a.mk:2:8", Position{"a.mk", 18, 2, 8}.String())
+}
+
+func TestOperatorTokens(t *testing.T) {
+	input := "% <= >= < > && || & | ^ << >>"
+	expected := []Token{
+		{Type: PERCENT, Literal: "%"},
+		{Type: LT_EQ, Literal: "<="},
+		{Type: GT_EQ, Literal: ">="},
+		{Type: LT, Literal: "<"},
+		{Type: GT, Literal: ">"},
+		{Type: AND, Literal: "&&"},
+		{Type: OR, Literal: "||"},
+		{Type: BIT_AND, Literal: "&"},
+		{Type: BIT_OR, Literal: "|"},
+		{Type: BIT_XOR, Literal: "^"},
+		{Type: SHL, Literal: "<<"},
+		{Type: SHR, Literal: ">>"},
+		{Type: EOF, Literal: ""},
+	}
+	l := NewLexer(input)
+	for _, tt := range expected {
+		tok := l.NextToken()
+		require.Equal(t, tt.Type, tok.Type)
+		require.Equal(t, tt.Literal, tok.Literal)
+	}
+}
+
+func TestStringTokens(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []Token
+	}{
+		{`"a\tb\n"`, []Token{{Type: STRING, Literal: "a\tb\n"}}},
+		{`"say \"hi\" \\ \$x"`, []Token{{Type: STRING, Literal: `say "hi" \ $x`}}},
+		{`"caf\u00e9 \u{1F600}"`, []Token{{Type: STRING, Literal: "café 😀"}}},
+		{"`raw \\n\n${x}`", []Token{{Type: STRING, Literal: "raw \\n\n${x}"}}},
+		{`"a ${b} c"`, []Token{
+			{Type: TEMPLATE_HEAD, Literal: "a "},
+			{Type: IDENT, Literal: "b"},
+			{Type: TEMPLATE_TAIL, Literal: " c"},
+		}},
+		{`"${ {"k": 1}["k"] }-${"${n}"}"`, []Token{
+			{Type: TEMPLATE_HEAD, Literal: ""},
+			{Type: LBRACE, Literal: "{"},
+			{Type: STRING, Literal: "k"},
+			{Type: COLON, Literal: ":"},
+			{Type: INT, Literal: "1"},
+			{Type: RBRACE, Literal: "}"},
+			{Type: LBRACKET, Literal: "["},
+			{Type: STRING, Literal: "k"},
+			{Type: RBRACKET, Literal: "]"},
+			{Type: TEMPLATE_MIDDLE, Literal: "-"},
+			{Type: TEMPLATE_HEAD, Literal: ""},
+			{Type: IDENT, Literal: "n"},
+			{Type: TEMPLATE_TAIL, Literal: ""},
+			{Type: TEMPLATE_TAIL, Literal: ""},
+		}},
+		{`"$5 {x}"`, []Token{{Type: STRING, Literal: "$5 {x}"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			l := NewLexer(tt.input)
+			for _, expected := range tt.expected {
+				tok := l.NextToken()
+				require.Equal(t, expected.Type, tok.Type)
+				require.Equal(t, expected.Literal, tok.Literal)
+			}
+			require.Equal(t, TokenType(EOF), l.NextToken().Type)
+		})
+	}
+}
+
+func TestStringTokenErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"open`, "unterminated string literal"},
+		{"`open", "unterminated raw string literal"},
+		{`"a ${b}`, "unterminated string literal"},
+		{`"bad \q escape"`, `invalid escape sequence "\\q" in string literal`},
+		{`"\u12"`, `invalid escape sequence "\\u12" in string literal`},
+		{`"\u{}"`, `invalid escape sequence "\\u{" in string literal`},
+		{`"\u{110000}"`, "invalid Unicode code point U+110000 in string literal"},
+		{`"\ud800"`, "invalid Unicode code point U+D800 in string literal"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			l := NewLexer(tt.input)
+			var tok Token
+			for tok = l.NextToken(); tok.Type != ILLEGAL && tok.Type != EOF; tok = l.NextToken() {
+			}
+			require.Equal(t, TokenType(ILLEGAL), tok.Type)
+			require.Equal(t, tt.expected, tok.Error)
+		})
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := `// leading
+let x = 1; // trailing
+/* block
+   comment */ x /**/ / 2 /=/* no space */3
+// at EOF`
+	expected := []Token{
+		{Type: LET, Literal: "let"},
+		{Type: IDENT, Literal: "x"},
+		{Type: ASSIGN, Literal: "="},
+		{Type: INT, Literal: "1"},
+		{Type: SEMICOLON, Literal: ";"},
+		{Type: IDENT, Literal: "x"},
+		{Type: SLASH, Literal: "/"},
+		{Type: INT, Literal: "2"},
+		{Type: SLASH_ASSIGN, Literal: "/="},
+		{Type: INT, Literal: "3"},
+		{Type: EOF, Literal: ""},
+	}
+	l := NewLexer(input)
+	for _, tt := range expected {
+		tok := l.NextToken()
+		require.Equal(t, tt.Type, tok.Type)
+		require.Equal(t, tt.Literal, tok.Literal)
+		require.Nil(t, tok.Comments)
+	}
+}
+
+func TestKeepComments(t *testing.T) {
+	l := NewLexer("// a\n/* b */ x // c\n")
+	l.KeepComments()
+
+	tok := l.NextToken()
+	require.Equal(t, TokenType(IDENT), tok.Type)
+	require.Len(t, tok.Comments, 2)
+	require.Equal(t, "// a", tok.Comments[0].Text)
+	require.Equal(t, "/* b */", tok.Comments[1].Text)
+	require.Equal(t, "2:1", tok.Comments[1].Span.Start.String())
+	require.Equal(t, "2:8", tok.Comments[1].Span.End.String())
+
+	tok = l.NextToken()
+	require.Equal(t, TokenType(EOF), tok.Type)
+	require.Len(t, tok.Comments, 1)
+	require.Equal(t, "// c", tok.Comments[0].Text)
+}
+
+func TestUnterminatedBlockComment(t *testing.T) {
+	l := NewLexer("x /* never\nclosed")
+	require.Equal(t, TokenType(IDENT), l.NextToken().Type)
+	tok := l.NextToken()
+	require.Equal(t, TokenType(ILLEGAL), tok.Type)
+	require.Equal(t, "unterminated block comment", tok.Error)
+	require.Equal(t, "1:3", tok.Span.Start.String())
+	require.Equal(t, TokenType(EOF), l.NextToken().Type)
+
+	p := NewParser(NewLexer("let a = 1; /* oops"))
+	p.ParseProgram()
+	require.Len(t, p.Errors(), 1)
+	require.Equal(t, "1:12: error: unterminated block comment", p.Errors()[0].String())
+}
+
+func TestUnicodeTokens(t *testing.T) {
+	l := NewLexer("let café = \"héllo, 世界\"; π\n¿")
+	expected := []struct {
+		tokenType TokenType
+		literal   string
+		column    int
+	}{
+		{LET, "let", 1},
+		{IDENT, "café", 5},
+		{ASSIGN, "=", 10},
+		{STRING, "héllo, 世界", 12},
+		{SEMICOLON, ";", 23},
+		{IDENT, "π", 25},
+		{ILLEGAL, "¿", 1},
+		{EOF, "", 2},
+	}
+	for _, tt := range expected {
+		tok := l.NextToken()
+		require.Equal(t, tt.tokenType, tok.Type)
+		require.Equal(t, tt.literal, tok.Literal)
+		require.Equal(t, tt.column, tok.Span.Start.Column, tt.literal)
+	}
+
+	tok := NewLexer("a \xff").NextToken()
+	require.Equal(t, TokenType(IDENT), tok.Type)
+	l = NewLexer("\xff")
+	tok = l.NextToken()
+	require.Equal(t, TokenType(ILLEGAL), tok.Type)
+	require.Equal(t, "\xff", tok.Literal)
+	require.Equal(t, "invalid UTF-8 encoding", tok.Error)
+}
+
+func TestNumberTokens(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []Token
+	}{
+		{"42", []Token{{Type: INT, Literal: "42"}}},
+		{"1.5", []Token{{Type: FLOAT, Literal: "1.5"}}},
+		{"1e-3", []Token{{Type: FLOAT, Literal: "1e-3"}}},
+		{"2E10", []Token{{Type: FLOAT, Literal: "2E10"}}},
+		{"3.25e+2", []Token{{Type: FLOAT, Literal: "3.25e+2"}}},
+		// without digits after it, 'e' isn't an exponent
+		{"1e", []Token{{Type: INT, Literal: "1"}, {Type: IDENT, Literal: "e"}}},
+		{"1e+", []Token{{Type: INT, Literal: "1"}, {Type: IDENT, Literal: "e"}, {Type: PLUS, Literal: "+"}}},
+		{"1.x", []Token{{Type: INT, Literal: "1"}, {Type: ILLEGAL, Literal: "."}, {Type: IDENT, Literal: "x"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			l := NewLexer(tt.input)
+			for _, expected := range tt.expected {
+				tok := l.NextToken()
+				require.Equal(t, expected.Type, tok.Type)
+				require.Equal(t, expected.Literal, tok.Literal)
+			}
+			require.Equal(t, TokenType(EOF), l.NextToken().Type)
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package monkey_interpreter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// DefaultMaxCallDepth bounds nested function calls when an Interpreter
+// doesn't set MaxCallDepth, well before the Go stack would overflow
+const DefaultMaxCallDepth = 10000
+
+// DefaultMaxRangeLen bounds the arrays built by `range` when an Interpreter
+// doesn't set MaxArrayLen, so a huge range fails instead of exhausting
+// memory
+const DefaultMaxRangeLen = 1 << 24
+
+// ctxCheckInterval is the number of evaluation steps between checks of the
+// context, which is cheap but not free
+const ctxCheckInterval = 256
+
+// causes of the errors raised when an evaluation hits a limit, test for
+// them with errors.Is. Cancellation wraps the context's error instead.
+var (
+	ErrStepLimit = errors.New("step limit exceeded")
+	ErrCallDepth = errors.New("maximum call depth exceeded")
+	ErrSizeLimit = errors.New("size limit exceeded")
+)
+
+// maxStackTrace bounds the frames recorded in an error's stack trace
+const maxStackTrace = 64
+
+// run holds the state of the evaluation in progress
+type run struct {
+	ctx    context.Context
+	steps  int64
+	frames []callFrame
+	// nesting counts evaluations started from builtins while one is running
+	nesting int
+}
+
+// begin starts an evaluation under ctx and returns the function ending it.
+// Evaluations nested in a running one share its step count and call stack.
+func (i *Interpreter) begin(ctx context.Context) func() {
+	outer := i.run.ctx
+	if i.run.nesting == 0 {
+		i.run = run{frames: i.run.frames[:0]}
+	}
+	i.run.ctx = ctx
+	i.run.nesting++
+	return func() {
+		i.run.nesting--
+		i.run.ctx = outer
+	}
+}
+
+// step counts one evaluation step, reporting a cancelled context or an
+// exhausted step budget
+func (i *Interpreter) step() *Error {
+	if i == nil {
+		return nil
+	}
+	i.run.steps++
+	if i.MaxSteps > 0 && i.run.steps > i.MaxSteps {
+		return &Error{Message: fmt.Sprintf("%s: %d", ErrStepLimit, i.MaxSteps), Err: ErrStepLimit}
+	}
+	if i.run.ctx != nil && (i.run.steps-1)%ctxCheckInterval == 0 {
+		if err := i.run.ctx.Err(); err != nil {
+			return &Error{Message: "evaluation aborted: " + err.Error(), Err: err}
+		}
+	}
+	return nil
+}
+
+// callFrame is a function call in progress
+type callFrame struct {
+	function string
+	call     Position // invalid for calls made by the host or builtins
+}
+
+// enter records a call of function at call, failing when calls nest too
+// deeply. Every successful enter must be paired with a leave.
+func (i *Interpreter) enter(function string, call Position) *Error {
+	if i == nil {
+		return nil
+	}
+	limit := i.MaxCallDepth
+	if limit <= 0 {
+		limit = DefaultMaxCallDepth
+	}
+	if len(i.run.frames) >= limit {
+		return &Error{Message: fmt.Sprintf("%s: %d", ErrCallDepth, limit), Err: ErrCallDepth}
+	}
+	i.run.frames = append(i.run.frames, callFrame{function: function, call: call})
+	return nil
+}
+
+func (i *Interpreter) leave() {
+	if i != nil {
+		i.run.frames = i.run.frames[:len(i.run.frames)-1]
+	}
+}
+
+// stackTrace returns the calls in progress, innermost first, given the
+// position reached in the innermost one
+func (i *Interpreter) stackTrace(pos Position) []StackFrame {
+	if i == nil {
+		return nil
+	}
+	var trace []StackFrame
+	for k := len(i.run.frames) - 1; k >= 0 && len(trace) < maxStackTrace; k-- {
+		frame := i.run.frames[k]
+		trace = append(trace, StackFrame{Function: frame.function, Pos: pos})
+		pos = frame.call
+	}
+	if pos.IsValid() && len(trace) < maxStackTrace {
+		trace = append(trace, StackFrame{Function: "<main>", Pos: pos})
+	}
+	return trace
+}
+
+// checkSize returns obj, or an error if it is an array or string larger
+// than allowed
+func (i *Interpreter) checkSize(obj Object) Object {
+	switch obj := obj.(type) {
+	case *Array:
+		if err := i.checkArrayLen(obj.Len()); err != nil {
+			return err
+		}
+	case *String:
+		if err := i.checkStringLen(len(obj.Value)); err != nil {
+			return err
+		}
+	}
+	return obj
+}
+
+// checkArrayLen reports whether an array of n elements may be built, so
+// builtins can fail before allocating it
+func (i *Interpreter) checkArrayLen(n int) *Error {
+	if i == nil {
+		return nil
+	}
+	return checkLen(n, i.MaxArrayLen)
+}
+
+// checkRangeLen is checkArrayLen for the arrays built by `range`, which
+// DefaultMaxRangeLen bounds when there is no MaxArrayLen
+func (i *Interpreter) checkRangeLen(n int) *Error {
+	limit := DefaultMaxRangeLen
+	if i != nil && i.MaxArrayLen > 0 {
+		limit = i.MaxArrayLen
+	}
+	return checkLen(n, limit)
+}
+
+// checkLen reports an array of n elements longer than limit, zero means
+// no limit
+func checkLen(n, limit int) *Error {
+	if limit <= 0 || n <= limit {
+		return nil
+	}
+	return &Error{
+		Message: fmt.Sprintf("%s: array of %d elements, limit is %d", ErrSizeLimit, n, limit),
+		Err:     ErrSizeLimit,
+	}
+}
+
+// checkStringLen is checkArrayLen for strings of n bytes
+func (i *Interpreter) checkStringLen(n int) *Error {
+	if i == nil || i.MaxStringLen <= 0 || n <= i.MaxStringLen {
+		return nil
+	}
+	return &Error{
+		Message: fmt.Sprintf("%s: string of %d bytes, limit is %d", ErrSizeLimit, n, i.MaxStringLen),
+		Err:     ErrSizeLimit,
+	}
+}
@@ -0,0 +1,160 @@
+package monkey_interpreter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const countdown = `let count = fn(n) { if (n == 0) { 0 } else { count(n - 1) } };`
+
+func TestDefaultCallDepth(t *testing.T) {
+	evaluated := testEval(`let f = fn(x) { f(x) }; f(1)`)
+	errObj, ok := evaluated.(*Error)
+	require.True(t, ok, "expected *Error, got %T", evaluated)
+	require.Equal(t, "maximum call depth exceeded: 10000", errObj.Message)
+	require.True(t, errors.Is(errObj, ErrCallDepth))
+}
+
+func TestInterpreterLimits(t *testing.T) {
+	tests := []struct {
+		name     string
+		setup    func(i *Interpreter)
+		input    string
+		expected string
+		cause    error
+	}{
+		{
+			"call depth",
+			func(i *Interpreter) { i.MaxCallDepth = 50 },
+			countdown + "count(50)",
+			"<eval>:1:46: maximum call depth exceeded: 50",
+			ErrCallDepth,
+		},
+		{
+			"steps",
+			func(i *Interpreter) { i.MaxSteps = 100 },
+			countdown + "count(100)",
+			"step limit exceeded: 100",
+			ErrStepLimit,
+		},
+		{
+			"array literal",
+			func(i *Interpreter) { i.MaxArrayLen = 3 },
+			"[1, 2, 3, 4]",
+			"<eval>:1:1: size limit exceeded: array of 4 elements, limit is 3",
+			ErrSizeLimit,
+		},
+		{
+			"push",
+			func(i *Interpreter) { i.MaxArrayLen = 3 },
+			"push(push([1, 2], 3), 4)",
+			"<eval>:1:1: size limit exceeded: array of 4 elements, limit is 3",
+			ErrSizeLimit,
+		},
+		{
+			"range",
+			func(i *Interpreter) { i.MaxArrayLen = 3 },
+			"range(1000000000)",
+			"<eval>:1:1: size limit exceeded: array of 1000000000 elements, limit is 3",
+			ErrSizeLimit,
+		},
+		{
+			"range without limit",
+			func(i *Interpreter) {},
+			"range(2147483647)",
+			"<eval>:1:1: size limit exceeded: array of 2147483647 elements, limit is 16777216",
+			ErrSizeLimit,
+		},
+		{
+			"callback steps",
+			func(i *Interpreter) { i.MaxSteps = 100 },
+			"map(range(1000), fn(x) { x })",
+			"step limit exceeded: 100",
+			ErrStepLimit,
+		},
+		{
+			"repeat",
+			func(i *Interpreter) { i.MaxStringLen = 5 },
+			`repeat("ab", 3)`,
+			"<eval>:1:1: size limit exceeded: string of 6 bytes, limit is 5",
+			ErrSizeLimit,
+		},
+		{
+			"string concatenation",
+			func(i *Interpreter) { i.MaxStringLen = 5 },
+			`let s = "abc"; s + s`,
+			"<eval>:1:16: size limit exceeded: string of 6 bytes, limit is 5",
+			ErrSizeLimit,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			interp := NewInterpreter()
+			tt.setup(interp)
+			_, err := interp.Eval(tt.input)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tt.expected)
+			require.True(t, errors.Is(err, tt.cause), "expected %v, got %v", tt.cause, err)
+		})
+	}
+}
+
+func TestLimitsCannotBeCaught(t *testing.T) {
+	interp := NewInterpreter()
+	interp.MaxSteps = 500
+	var out bytes.Buffer
+	interp.Stdout = &out
+	_, err := interp.Eval(countdown + `try { count(100) } catch (e) { 0 } finally { puts("finally") }`)
+	require.True(t, errors.Is(err, ErrStepLimit), "got %v", err)
+	require.Empty(t, out.String())
+}
+
+func TestLimitsResetBetweenEvaluations(t *testing.T) {
+	interp := NewInterpreter()
+	interp.MaxSteps = 3000
+	interp.MaxCallDepth = 100
+	_, err := interp.Eval(countdown)
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		result, err := interp.Eval("count(50)")
+		require.NoError(t, err)
+		testIntegerObject(t, result, 0)
+	}
+	_, err = interp.Eval("count(200)")
+	require.True(t, errors.Is(err, ErrCallDepth))
+	result, err := interp.Eval("count(50)")
+	require.NoError(t, err)
+	testIntegerObject(t, result, 0)
+}
+
+func TestEvalContext(t *testing.T) {
+	interp := NewInterpreter()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	interp.RegisterBuiltin("cancel", func(args ...Object) Object {
+		cancel()
+		return NULL_OBJ
+	})
+	_, err := interp.EvalContext(ctx, countdown+"cancel(); count(5000)")
+	require.True(t, errors.Is(err, context.Canceled), "got %v", err)
+	require.Contains(t, err.Error(), "evaluation aborted: context canceled")
+
+	expired, cancelExpired := context.WithTimeout(context.Background(), -time.Second)
+	defer cancelExpired()
+	_, err = interp.EvalContext(expired, "1")
+	require.True(t, errors.Is(err, context.DeadlineExceeded), "got %v", err)
+
+	// the context only applies to the evaluation it was passed to
+	result, err := interp.Eval("count(10)")
+	require.NoError(t, err)
+	testIntegerObject(t, result, 0)
+
+	count, _ := interp.GetGlobal("count")
+	_, err = CallContext(expired, count, &Integer{Value: 10})
+	require.True(t, errors.Is(err, context.DeadlineExceeded), "got %v", err)
+}
@@ -2,8 +2,11 @@ package monkey_interpreter
 
 import (
 	"bytes"
+	"encoding/binary"
 	"fmt"
 	"hash/fnv"
+	"math/big"
+	"strconv"
 	"strings"
 )
 
@@ -11,15 +14,22 @@ type ObjectType string
 
 const (
 	INT_OBJ_TYPE          = "INTEGER"
+	FLOAT_OBJ_TYPE        = "FLOAT"
 	STRING_OBJ_TYPE       = "STRING"
 	BOOL_OBJ_TYPE         = "BOOLEAN"
 	NULL_OBJ_TYPE         = "NULL"
 	RETURN_VALUE_OBJ_TYPE = "RETURN_VALUE"
+	BREAK_OBJ_TYPE        = "BREAK"
+	CONTINUE_OBJ_TYPE     = "CONTINUE"
 	ERROR_OBJ_TYPE        = "ERROR"
+	ERROR_VALUE_OBJ_TYPE  = "ERROR_VALUE"
 	FUNCTION_OBJ_TYPE     = "FUNCTION"
 	BULTIN_OBJ_TYPE       = "BUILTIN"
 	ARRAY_OBJ_TYPE        = "ARRAY"
 	HASH_OBJ_TYPE         = "HASH"
+
+	MUTABLE_ARRAY_OBJ_TYPE     = "MUTABLE_ARRAY"
+	COMPILED_FUNCTION_OBJ_TYPE = "COMPILED_FUNCTION"
 )
 
 type Object interface {
@@ -34,6 +44,28 @@ type Integer struct {
 func (i *Integer) Inspect() string  { return fmt.Sprintf("%d", i.Value) }
 func (i *Integer) Type() ObjectType { return INT_OBJ_TYPE }
 
+// BigInteger is an INTEGER too large for an int64, see integer.go
+type BigInteger struct {
+	Value *big.Int
+}
+
+func (bi *BigInteger) Inspect() string  { return bi.Value.String() }
+func (bi *BigInteger) Type() ObjectType { return INT_OBJ_TYPE }
+
+type Float struct {
+	Value float64
+}
+
+// Inspect always shows a float as one, e.g. 2.0 rather than 2
+func (f *Float) Inspect() string {
+	s := strconv.FormatFloat(f.Value, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".eIN") {
+		s += ".0"
+	}
+	return s
+}
+func (f *Float) Type() ObjectType { return FLOAT_OBJ_TYPE }
+
 type String struct {
 	Value string
 }
@@ -60,17 +92,82 @@ type ReturnValue struct {
 func (rv *ReturnValue) Type() ObjectType { return RETURN_VALUE_OBJ_TYPE }
 func (rv *ReturnValue) Inspect() string  { return rv.Value.Inspect() }
 
+// Break and Continue signal a break or continue statement on its way out
+// to the enclosing loop, like ReturnValue does for return statements
+type Break struct{}
+
+func (b *Break) Type() ObjectType { return BREAK_OBJ_TYPE }
+func (b *Break) Inspect() string  { return "break" }
+
+type Continue struct{}
+
+func (c *Continue) Type() ObjectType { return CONTINUE_OBJ_TYPE }
+func (c *Continue) Inspect() string  { return "continue" }
+
 type Error struct {
 	Message string
+	Pos     Position // where the error was raised, if known
+	// Payload is the value thrown by the script, if it wasn't a string
+	Payload Object
+	// Stack lists the active calls when the error was raised, innermost
+	// first
+	Stack []StackFrame
+	// Err is the cause of errors raised by the host rather than the
+	// script, such as cancellation or an exceeded limit. Scripts can't
+	// catch those.
+	Err error
 }
 
 func (e *Error) Type() ObjectType { return ERROR_OBJ_TYPE }
-func (e *Error) Inspect() string  { return "ERROR: " + e.Message }
+func (e *Error) Inspect() string {
+	if e.Pos.IsValid() {
+		return "ERROR: " + e.Pos.String() + ": " + e.Message
+	}
+	return "ERROR: " + e.Message
+}
+
+// Error lets an *Error be returned as a Go error
+func (e *Error) Error() string {
+	if e.Pos.IsValid() {
+		return e.Pos.String() + ": " + e.Message
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error { return e.Err }
+
+// StackTrace formats Stack one frame per line
+func (e *Error) StackTrace() string {
+	var out strings.Builder
+	for _, frame := range e.Stack {
+		out.WriteString("\tat " + frame.String() + "\n")
+	}
+	return out.String()
+}
+
+// StackFrame is a call in progress: Pos is the position reached in Function
+type StackFrame struct {
+	Function string
+	Pos      Position
+}
+
+func (f StackFrame) String() string { return f.Function + " (" + f.Pos.String() + ")" }
+
+// ErrorValue is an error caught by a catch clause or made by the error
+// builtin. Unlike an *Error it is an ordinary value, throwing it raises
+// the error again.
+type ErrorValue struct {
+	Error *Error
+}
+
+func (ev *ErrorValue) Type() ObjectType { return ERROR_VALUE_OBJ_TYPE }
+func (ev *ErrorValue) Inspect() string  { return ev.Error.Inspect() }
 
 type Function struct {
 	Parameters []*Identifier
 	Body       *BlockStatement
 	Env        *Environment
+	Name       string // name of the let binding, if any
 }
 
 func (f *Function) Type() ObjectType { return FUNCTION_OBJ_TYPE }
@@ -91,6 +188,13 @@ func (f *Function) Inspect() string {
 	return out.String()
 }
 
+// Callable is a function value implemented outside this package, like a
+// closure of the bytecode VM. Builtins and Call invoke it like a *Function.
+type Callable interface {
+	Object
+	Call(args ...Object) Object
+}
+
 type BuiltinFunction func(args ...Object) Object
 
 type Builtin struct {
@@ -102,8 +206,57 @@ func (b *Builtin) Inspect() string {
 	return "builtin function"
 }
 
+// Array is an immutable sequence of values, a window onto a persistent
+// vector. Push and Set share structure with the original array and take
+// O(log n) time, Slice takes O(1) and keeps the whole vector alive. The
+// zero value is an empty array.
 type Array struct {
-	Elements []Object
+	vec        *vector
+	start, end int // the window of vec holding the elements
+}
+
+// NewArray returns an array of elements, which it copies
+func NewArray(elements []Object) *Array {
+	return &Array{vec: newVector(elements), end: len(elements)}
+}
+
+func (ao *Array) Len() int { return ao.end - ao.start }
+
+// Get returns the element at i, which must be in range
+func (ao *Array) Get(i int) Object { return ao.vec.get(ao.start + i) }
+
+// Elements returns a copy of the elements
+func (ao *Array) Elements() []Object {
+	elements := make([]Object, ao.Len())
+	if ao.vec != nil {
+		ao.vec.copyTo(elements, ao.start)
+	}
+	return elements
+}
+
+// Push returns an array with val appended
+func (ao *Array) Push(val Object) *Array {
+	switch {
+	case ao.vec == nil:
+		return NewArray([]Object{val})
+	case ao.end == ao.vec.count:
+		return &Array{vec: ao.vec.push(val), start: ao.start, end: ao.end + 1}
+	default:
+		// a slice, the element after the window is free to replace
+		return &Array{vec: ao.vec.set(ao.end, val), start: ao.start, end: ao.end + 1}
+	}
+}
+
+// Set returns an array with the element at i, which must be in range,
+// replaced by val
+func (ao *Array) Set(i int, val Object) *Array {
+	return &Array{vec: ao.vec.set(ao.start+i, val), start: ao.start, end: ao.end}
+}
+
+// Slice returns the elements from low up to high, which must satisfy
+// 0 <= low <= high <= Len()
+func (ao *Array) Slice(low, high int) *Array {
+	return &Array{vec: ao.vec, start: ao.start + low, end: ao.start + high}
 }
 
 func (ao *Array) Type() ObjectType { return ARRAY_OBJ_TYPE }
@@ -111,7 +264,7 @@ func (ao *Array) Type() ObjectType { return ARRAY_OBJ_TYPE }
 func (ao *Array) Inspect() string {
 	var out bytes.Buffer
 	elements := []string{}
-	for _, e := range ao.Elements {
+	for _, e := range ao.Elements() {
 		elements = append(elements, e.Inspect())
 	}
 	out.WriteString("[")
@@ -120,6 +273,23 @@ func (ao *Array) Inspect() string {
 	return out.String()
 }
 
+// MutableArray is an array updated in place, every reference to it sees
+// the changes. mutable(arr) makes one from an array, freeze(m) takes a
+// snapshot of it as an array.
+type MutableArray struct {
+	Elements []Object
+}
+
+func (ma *MutableArray) Type() ObjectType { return MUTABLE_ARRAY_OBJ_TYPE }
+
+func (ma *MutableArray) Inspect() string {
+	elements := make([]string, len(ma.Elements))
+	for i, e := range ma.Elements {
+		elements[i] = e.Inspect()
+	}
+	return "mutable([" + strings.Join(elements, ", ") + "])"
+}
+
 type HashKey struct {
 	Type  ObjectType
 	Value uint64
@@ -137,18 +307,151 @@ func (b *BooleanObject) HashKey() HashKey {
 func (i *Integer) HashKey() HashKey {
 	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
 }
+func (bi *BigInteger) HashKey() HashKey {
+	return bigIntegerHashKey(bi.Value)
+}
 func (s *String) HashKey() HashKey {
 	h := fnv.New64a()
 	_, _ = h.Write([]byte(s.Value))
 	return HashKey{Type: s.Type(), Value: h.Sum64()}
 }
 
+// HashKey combines the elements' keys, see hashableKey for which arrays
+// are usable as keys
+func (ao *Array) HashKey() HashKey {
+	h := fnv.New64a()
+	var buf [8]byte
+	for _, el := range ao.Elements() {
+		_, _ = h.Write([]byte(el.Type()))
+		if el, ok := el.(Hashable); ok {
+			binary.LittleEndian.PutUint64(buf[:], el.HashKey().Value)
+			_, _ = h.Write(buf[:])
+		}
+	}
+	return HashKey{Type: ao.Type(), Value: h.Sum64()}
+}
+
 type HashPair struct {
 	Key   Object
 	Value Object
 }
+
+// Hash keeps its pairs in insertion order, which is the order they are
+// iterated and printed in, and indexes them by HashKey for O(1) lookups.
+// Keys whose HashKey collide share a bucket and are told apart by value.
+// The zero value is an empty hash.
 type Hash struct {
-	Pairs map[HashKey]HashPair
+	pairs []HashPair        // deleted pairs are left in place with a nil Key
+	index map[HashKey][]int // positions in pairs of the keys in each bucket
+	// deleted counts the nil pairs, they are compacted away once they make
+	// up half of pairs, or when Pairs is called
+	deleted int
+	// shared is set once Pairs hands out pairs, Delete then copies them
+	// rather than leave a nil pair in the caller's slice
+	shared bool
+}
+
+// NewHash returns an empty hash with room for size pairs
+func NewHash(size int) *Hash {
+	return &Hash{pairs: make([]HashPair, 0, size), index: make(map[HashKey][]int, size)}
+}
+
+// find returns the position of key in pairs, or -1
+func (h *Hash) find(key Hashable) int {
+	for _, i := range h.index[key.HashKey()] {
+		if hashKeysEqual(h.pairs[i].Key, key) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (h *Hash) Get(key Hashable) (HashPair, bool) {
+	i := h.find(key)
+	if i < 0 {
+		return HashPair{}, false
+	}
+	return h.pairs[i], true
+}
+
+// Set stores value under key. A new key goes last, an existing one keeps
+// its position and its original key object.
+func (h *Hash) Set(key Hashable, value Object) {
+	if i := h.find(key); i >= 0 {
+		h.pairs[i].Value = value
+		return
+	}
+	if h.index == nil {
+		h.index = map[HashKey][]int{}
+	}
+	hashKey := key.HashKey()
+	h.index[hashKey] = append(h.index[hashKey], len(h.pairs))
+	h.pairs = append(h.pairs, HashPair{Key: key, Value: value})
+}
+
+// Delete removes key, reporting whether it was present
+func (h *Hash) Delete(key Hashable) bool {
+	i := h.find(key)
+	if i < 0 {
+		return false
+	}
+	hashKey := key.HashKey()
+	bucket := h.index[hashKey]
+	for j, pos := range bucket {
+		if pos == i {
+			bucket = append(bucket[:j:j], bucket[j+1:]...)
+			break
+		}
+	}
+	if len(bucket) == 0 {
+		delete(h.index, hashKey)
+	} else {
+		h.index[hashKey] = bucket
+	}
+	if h.shared {
+		// a slice returned by Pairs, as used by a running for-in loop, is
+		// left untouched
+		h.pairs = append([]HashPair(nil), h.pairs...)
+		h.shared = false
+	}
+	// the positions of the other pairs stay valid, so deleting doesn't
+	// renumber the index until enough pairs are deleted to compact
+	h.pairs[i] = HashPair{}
+	h.deleted++
+	if h.deleted > len(h.pairs)/2 {
+		h.compact()
+	}
+	return true
+}
+
+// compact drops the deleted pairs and renumbers the index to match
+func (h *Hash) compact() {
+	pairs := make([]HashPair, 0, len(h.pairs)-h.deleted)
+	positions := make([]int, len(h.pairs))
+	for i, pair := range h.pairs {
+		if pair.Key != nil {
+			positions[i] = len(pairs)
+			pairs = append(pairs, pair)
+		}
+	}
+	for _, bucket := range h.index {
+		for j, pos := range bucket {
+			bucket[j] = positions[pos]
+		}
+	}
+	h.pairs, h.deleted, h.shared = pairs, 0, false
+}
+
+func (h *Hash) Len() int { return len(h.pairs) - h.deleted }
+
+// Pairs returns the pairs in insertion order, the slice must not be
+// modified
+func (h *Hash) Pairs() []HashPair {
+	if h.deleted > 0 {
+		h.compact()
+	}
+	h.shared = true
+	return h.pairs
 }
 
 func (h *Hash) Type() ObjectType { return HASH_OBJ_TYPE }
@@ -156,7 +459,10 @@ func (h *Hash) Type() ObjectType { return HASH_OBJ_TYPE }
 func (h *Hash) Inspect() string {
 	var out bytes.Buffer
 	var pairs []string
-	for _, pair := range h.Pairs {
+	for _, pair := range h.pairs {
+		if pair.Key == nil {
+			continue
+		}
 		pairs = append(pairs, fmt.Sprintf("%s: %s",
 			pair.Key.Inspect(), pair.Value.Inspect()))
 	}
@@ -167,5 +473,54 @@ func (h *Hash) Inspect() string {
 }
 
 type Hashable interface {
+	Object
 	HashKey() HashKey
 }
+
+// hashableKey returns obj as a hash key. Arrays are usable as keys when
+// all their elements are.
+func hashableKey(obj Object) (Hashable, *Error) {
+	key, ok := obj.(Hashable)
+	if !ok {
+		return nil, newError("unusable as hash key: %s", obj.Type())
+	}
+	if arr, ok := obj.(*Array); ok {
+		for _, el := range arr.Elements() {
+			if _, err := hashableKey(el); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return key, nil
+}
+
+// hashKeysEqual reports whether two keys with the same HashKey are the
+// same key
+func hashKeysEqual(a, b Object) bool {
+	switch a := a.(type) {
+	case *String:
+		b, ok := b.(*String)
+		return ok && a.Value == b.Value
+	case *BooleanObject:
+		b, ok := b.(*BooleanObject)
+		return ok && a.Value == b.Value
+	case *Integer, *BigInteger:
+		switch b.(type) {
+		case *Integer, *BigInteger:
+			return toBig(a).Cmp(toBig(b)) == 0
+		}
+		return false
+	case *Array:
+		b, ok := b.(*Array)
+		if !ok || a.Len() != b.Len() {
+			return false
+		}
+		for i := 0; i < a.Len(); i++ {
+			if !hashKeysEqual(a.Get(i), b.Get(i)) {
+				return false
+			}
+		}
+		return true
+	}
+	return a == b
+}
@@ -1,6 +1,12 @@
 package monkey_interpreter
 
-import "testing"
+import (
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
 
 func TestStringHashKey(t *testing.T) {
 
@@ -19,3 +25,106 @@ func TestStringHashKey(t *testing.T) {
 		t.Errorf("strings with different content have same hash keys")
 	}
 }
+
+func TestHashKeysEqual(t *testing.T) {
+	tests := []struct {
+		a, b     Object
+		expected bool
+	}{
+		{&String{Value: "a"}, &String{Value: "a"}, true},
+		{&String{Value: "a"}, &String{Value: "b"}, false},
+		{&String{Value: "1"}, &Integer{Value: 1}, false},
+		{&Integer{Value: 1}, &BigInteger{Value: big.NewInt(1)}, true},
+		{TRUE_OBJ, &BooleanObject{Value: true}, true},
+		{TRUE_OBJ, FALSE_OBJ, false},
+		{NewArray([]Object{&String{Value: "a"}}), NewArray([]Object{&String{Value: "a"}}), true},
+		{NewArray([]Object{&String{Value: "a"}}), NewArray([]Object{&String{Value: "a"}, TRUE_OBJ}), false},
+	}
+	for _, tt := range tests {
+		require.Equal(t, tt.expected, hashKeysEqual(tt.a, tt.b), "%s == %s", tt.a.Inspect(), tt.b.Inspect())
+	}
+}
+
+func TestHashCollisions(t *testing.T) {
+	// an integer past int64 is keyed by a hash of its bytes, the int64 with
+	// the same bits as that hash lands in the same bucket
+	huge := func() *BigInteger { return &BigInteger{Value: new(big.Int).Lsh(big.NewInt(1), 64)} }
+	colliding := int64(huge().HashKey().Value)
+	small := func() *Integer { return &Integer{Value: colliding} }
+	require.Equal(t, huge().HashKey(), small().HashKey())
+
+	hash := NewHash(0)
+	hash.Set(&String{Value: "first"}, &Integer{Value: 0})
+	hash.Set(small(), &Integer{Value: 1})
+	hash.Set(huge(), &Integer{Value: 2})
+	require.Equal(t, 3, hash.Len())
+
+	// lookups go by value, not by the key objects stored
+	pair, ok := hash.Get(small())
+	require.True(t, ok)
+	require.Equal(t, "1", pair.Value.Inspect())
+	pair, ok = hash.Get(huge())
+	require.True(t, ok)
+	require.Equal(t, "2", pair.Value.Inspect())
+
+	// deleting keeps the pairs after the deleted one reachable
+	require.True(t, hash.Delete(&String{Value: "first"}))
+	pair, ok = hash.Get(huge())
+	require.True(t, ok)
+	require.Equal(t, "2", pair.Value.Inspect())
+
+	require.True(t, hash.Delete(small()))
+	require.False(t, hash.Delete(small()))
+	_, ok = hash.Get(small())
+	require.False(t, ok)
+	pair, ok = hash.Get(huge())
+	require.True(t, ok)
+	require.Equal(t, "2", pair.Value.Inspect())
+	require.Equal(t, 1, hash.Len())
+
+	hash.Set(small(), &Integer{Value: 3})
+	require.Equal(t, "{18446744073709551616: 2, "+small().Inspect()+": 3}", hash.Inspect())
+}
+
+func TestArrayHashKey(t *testing.T) {
+	one := NewArray([]Object{&Integer{Value: 1}, &String{Value: "x"}})
+	other := NewArray([]Object{&Integer{Value: 1}, &String{Value: "x"}})
+	diff := NewArray([]Object{&String{Value: "x"}, &Integer{Value: 1}})
+	require.Equal(t, one.HashKey(), other.HashKey())
+	require.NotEqual(t, one.HashKey(), diff.HashKey())
+
+	_, err := hashableKey(NewArray([]Object{NewArray([]Object{&Float{Value: 1}})}))
+	require.Equal(t, "unusable as hash key: FLOAT", err.Message)
+}
+
+func TestHashDelete(t *testing.T) {
+	hash := NewHash(0)
+	for i := 0; i < 10; i++ {
+		hash.Set(&Integer{Value: int64(i)}, &Integer{Value: int64(i * i)})
+	}
+	pairs := hash.Pairs()
+
+	// deletes leave a slice returned by Pairs untouched
+	for i := 0; i < 10; i += 2 {
+		require.True(t, hash.Delete(&Integer{Value: int64(i)}))
+	}
+	require.Len(t, pairs, 10)
+	for i, pair := range pairs {
+		require.Equal(t, fmt.Sprint(i), pair.Key.Inspect())
+	}
+
+	require.Equal(t, 5, hash.Len())
+	require.Equal(t, "{1: 1, 3: 9, 5: 25, 7: 49, 9: 81}", hash.Inspect())
+	hash.Set(&Integer{Value: 0}, &Integer{Value: 0})
+	require.True(t, hash.Delete(&Integer{Value: 3}))
+	require.True(t, hash.Delete(&Integer{Value: 7}))
+	require.Equal(t, "{1: 1, 5: 25, 9: 81, 0: 0}", hash.Inspect())
+	for _, key := range []int64{0, 1, 5, 9} {
+		pair, ok := hash.Get(&Integer{Value: key})
+		require.True(t, ok)
+		require.Equal(t, fmt.Sprint(key*key), pair.Value.Inspect())
+	}
+	_, ok := hash.Get(&Integer{Value: 7})
+	require.False(t, ok)
+	require.Len(t, hash.Pairs(), 4)
+}
@@ -0,0 +1,33 @@
+package monkey_interpreter
+
+// The operations below are Eval's semantics for operators, indexing and
+// truthiness, exported for other backends like the bytecode VM so that
+// both compute the same values and report the same errors. Failures are
+// returned as *Error objects, as Eval does.
+
+// InfixOperation applies a binary operator such as "+" or "<=". The
+// logical operators && and || short-circuit and are left to the caller.
+func InfixOperation(operator string, left, right Object) Object {
+	return evalInfixExpression(operator, left, right)
+}
+
+// PrefixOperation applies the unary operator "!" or "-"
+func PrefixOperation(operator string, right Object) Object {
+	return evalPrefixExpression(operator, right)
+}
+
+// IndexOperation evaluates left[index]
+func IndexOperation(left, index Object) Object {
+	return evalIndexExpression(left, index)
+}
+
+// SliceOperation evaluates left[low:high], a NULL bound is left open
+func SliceOperation(left, low, high Object) Object {
+	return sliceObject(left, low, high)
+}
+
+// IsTruthy reports whether obj counts as true in a condition
+func IsTruthy(obj Object) bool { return isTruthy(obj) }
+
+// HashableKey returns obj as a hash key, or an error if it can't be one
+func HashableKey(obj Object) (Hashable, *Error) { return hashableKey(obj) }
@@ -0,0 +1,104 @@
+package monkey_interpreter
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+)
+
+// ParseCache stores encoded programs on disk, keyed by a hash of the file
+// name and source, so unchanged scripts skip lexing and parsing. The cache
+// is best effort: unreadable, corrupted or stale entries are re-parsed and
+// overwritten, and failures to write are ignored.
+type ParseCache struct {
+	Dir string
+}
+
+func NewParseCache(dir string) *ParseCache {
+	return &ParseCache{Dir: dir}
+}
+
+// DefaultParseCacheDir returns the per user cache directory for parsed
+// programs, or "" if the platform has none
+func DefaultParseCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "monkey", "ast")
+}
+
+// Parse returns the program for source, decoding it from the cache when
+// possible. Programs with syntax errors are never cached.
+func (c *ParseCache) Parse(filename, source string) (*Program, []Diagnostic) {
+	path := c.path(filename, source)
+	if data, err := os.ReadFile(path); err == nil {
+		if program, err := DecodeProgram(data); err == nil {
+			return program, nil
+		}
+	}
+
+	p := NewParser(NewFileLexer(filename, source))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return program, p.Errors()
+	}
+	if data, err := EncodeProgram(program); err == nil {
+		_ = c.write(path, data)
+	}
+	return program, nil
+}
+
+// path returns the cache file for source. The encoding version is part of
+// the key as well as the header, so versions can share a directory.
+func (c *ParseCache) path(filename, source string) string {
+	h := sha256.New()
+	h.Write([]byte{byte(ASTVersion >> 8), byte(ASTVersion)})
+	h.Write([]byte(filename))
+	h.Write([]byte{0})
+	h.Write([]byte(source))
+	return filepath.Join(c.Dir, hex.EncodeToString(h.Sum(nil))+".mkast")
+}
+
+// write stores data atomically, so concurrent runs never see partial files
+func (c *ParseCache) write(path string, data []byte) error {
+	if err := os.MkdirAll(c.Dir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(c.Dir, "tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// ParseFile reads and parses the script at path, going through cache if it
+// is not nil
+func ParseFile(path string, cache *ParseCache) (*Program, []Diagnostic, error) {
+	source, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	program, diagnostics := parseSource(cache, path, string(source))
+	return program, diagnostics, nil
+}
+
+// parseSource parses source, going through cache if it is not nil
+func parseSource(cache *ParseCache, filename, source string) (*Program, []Diagnostic) {
+	if cache != nil {
+		return cache.Parse(filename, source)
+	}
+	p := NewParser(NewFileLexer(filename, source))
+	program := p.ParseProgram()
+	return program, p.Errors()
+}
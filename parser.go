@@ -1,7 +1,9 @@
 package monkey_interpreter
 
 import (
+	"errors"
 	"fmt"
+	"math/big"
 	"strconv"
 )
 
@@ -10,27 +12,48 @@ type (
 	infixParseFn  func(Expression) Expression
 )
 
+// precedence levels follow Go, so bitwise operators bind tighter than
+// comparisons
 const (
 	_ int = iota
 	LOWEST
+	ASSIGN_PREC  // = += -= *= /=
+	LOGICAL_OR   // ||
+	LOGICAL_AND  // &&
 	EQUALS       // ==
 	LESS_GREATER // > or <
-	SUM          // +
-	PRODUCT      // *
+	SUM          // + - | ^
+	PRODUCT      // * / % << >> &
 	PREFIX       // -X or !X
 	CALL         // myFunction(X)
 	INDEX        // array[index]
 )
 
 var precedences = map[TokenType]int{
+	ASSIGN:          ASSIGN_PREC,
+	PLUS_ASSIGN:     ASSIGN_PREC,
+	MINUS_ASSIGN:    ASSIGN_PREC,
+	ASTERISK_ASSIGN: ASSIGN_PREC,
+	SLASH_ASSIGN:    ASSIGN_PREC,
+
+	OR:       LOGICAL_OR,
+	AND:      LOGICAL_AND,
 	EQ:       EQUALS,
 	NOT_EQ:   EQUALS,
 	LT:       LESS_GREATER,
 	GT:       LESS_GREATER,
+	LT_EQ:    LESS_GREATER,
+	GT_EQ:    LESS_GREATER,
 	PLUS:     SUM,
 	MINUS:    SUM,
+	BIT_OR:   SUM,
+	BIT_XOR:  SUM,
 	SLASH:    PRODUCT,
 	ASTERISK: PRODUCT,
+	PERCENT:  PRODUCT,
+	SHL:      PRODUCT,
+	SHR:      PRODUCT,
+	BIT_AND:  PRODUCT,
 	LPAREN:   CALL,
 	LBRACKET: INDEX,
 }
@@ -39,7 +62,19 @@ type Parser struct {
 	l         *Lexer
 	curToken  Token
 	peekToken Token
-	errors    []string
+	errors    []Diagnostic
+	// panicking is set after an error is reported and cleared once the parser
+	// resynchronises at a statement boundary. While set, further errors are
+	// dropped since they are most likely caused by the first one.
+	panicking bool
+	// loopDepth counts the loop bodies enclosing curToken within the
+	// current function, break and continue are only valid inside one
+	loopDepth int
+	// braceDepth counts the '{' opened up to curToken and not yet closed,
+	// blocks holds the braceDepth at the '{' of each enclosing block, so
+	// recovery knows which '}' ends the block it is in
+	braceDepth int
+	blocks     []int
 
 	prefixParseFns map[TokenType]prefixParseFn
 	infixParseFns  map[TokenType]infixParseFn
@@ -53,13 +88,16 @@ func NewParser(l *Lexer) *Parser {
 	p.prefixParseFns = make(map[TokenType]prefixParseFn)
 	p.registerPrefix(IDENT, p.parseIdentifier)
 	p.registerPrefix(INT, p.parseIntegerLiteral)
+	p.registerPrefix(FLOAT, p.parseFloatLiteral)
 	p.registerPrefix(STRING, p.parseStringLiteral)
+	p.registerPrefix(TEMPLATE_HEAD, p.parseInterpolatedString)
 	p.registerPrefix(BANG, p.parsePrefixExpression)
 	p.registerPrefix(MINUS, p.parsePrefixExpression)
 	p.registerPrefix(TRUE, p.parseBoolean)
 	p.registerPrefix(FALSE, p.parseBoolean)
 	p.registerPrefix(LPAREN, p.parseGroupedExpression)
 	p.registerPrefix(IF, p.parseIfExpression)
+	p.registerPrefix(TRY, p.parseTryExpression)
 	p.registerPrefix(FUNCTION, p.parseFunctionLiteral)
 	p.registerPrefix(LBRACKET, p.parseArrayLiteral)
 	p.registerPrefix(LBRACE, p.parseHashLiteral)
@@ -73,6 +111,12 @@ func NewParser(l *Lexer) *Parser {
 	p.registerInfix(NOT_EQ, p.parseInfixExpression)
 	p.registerInfix(LT, p.parseInfixExpression)
 	p.registerInfix(GT, p.parseInfixExpression)
+	for _, tokenType := range []TokenType{PERCENT, LT_EQ, GT_EQ, AND, OR, BIT_AND, BIT_OR, BIT_XOR, SHL, SHR} {
+		p.registerInfix(tokenType, p.parseInfixExpression)
+	}
+	for _, tokenType := range []TokenType{ASSIGN, PLUS_ASSIGN, MINUS_ASSIGN, ASTERISK_ASSIGN, SLASH_ASSIGN} {
+		p.registerInfix(tokenType, p.parseAssignExpression)
+	}
 	p.registerInfix(LPAREN, p.parseCallExpression)
 	p.registerInfix(LBRACKET, p.parseIndexExpression)
 
@@ -91,12 +135,23 @@ func (p *Parser) registerInfix(tokenType TokenType, fn infixParseFn) { p.infixPa
 func (p *Parser) nextToken() {
 	p.curToken = p.peekToken
 	p.peekToken = p.l.NextToken()
+	switch {
+	case p.curToken.Type == LBRACE:
+		p.braceDepth++
+	case p.curToken.Type == RBRACE && p.braceDepth > 0:
+		p.braceDepth--
+	}
+}
+
+// Errors returns the diagnostics reported while parsing
+func (p *Parser) Errors() []Diagnostic {
+	return p.errors
 }
+
 func (p *Parser) ParseProgram() *Program {
 	program := NewProgram()
 	for p.curToken.Type != EOF {
-		stmt := p.parseStatement()
-		if stmt != nil {
+		if stmt, _ := p.parseStatementRecovering(); stmt != nil {
 			program.Statements = append(program.Statements, stmt)
 		}
 		p.nextToken()
@@ -104,15 +159,93 @@ func (p *Parser) ParseProgram() *Program {
 	return program
 }
 
+// parseStatementRecovering parses a statement, and if that fails, skips to
+// the end of it so the next one can be parsed cleanly. A broken statement is
+// dropped and returned as nil. synced reports whether the parser had to skip
+// ahead, in which case curToken may be a '}' closing the enclosing block.
+func (p *Parser) parseStatementRecovering() (stmt Statement, synced bool) {
+	errCount := len(p.errors)
+	stmt = p.parseStatement()
+	switch {
+	case p.panicking:
+		p.synchronize()
+		return nil, true
+	case len(p.errors) > errCount:
+		// a nested block already recovered and the statement was parsed to
+		// its end, skipping again would eat the tokens after it
+		return nil, false
+	}
+	return stmt, false
+}
+
+// synchronize advances until curToken is the last token of the broken
+// statement: a ';', the '}' closing the enclosing block, or the token before
+// the next 'let', 'return', 'throw', 'while', 'for', that '}' or EOF.
+// Braces opened within the statement are skipped along with it, and so are
+// unmatched '}' at the top level.
+func (p *Parser) synchronize() {
+	p.panicking = false
+	level := 0 // braceDepth inside the enclosing block
+	if len(p.blocks) > 0 {
+		level = p.blocks[len(p.blocks)-1]
+	}
+	for {
+		switch {
+		case p.curTokenIs(EOF):
+			return
+		case p.curTokenIs(SEMICOLON) && p.braceDepth == level:
+			return
+		case p.curTokenIs(RBRACE) && p.braceDepth == level-1:
+			return
+		}
+		if p.braceDepth == level {
+			switch p.peekToken.Type {
+			case LET, RETURN, THROW, WHILE, FOR, EOF:
+				return
+			case RBRACE:
+				if level > 0 {
+					return
+				}
+			}
+		}
+		p.nextToken()
+	}
+}
+
 func (p *Parser) parseStatement() Statement {
+	// NOTE: the typed parse funcs return nil pointers on failure, which must
+	// not leak out as non-nil Statement interfaces
 	switch p.curToken.Type {
-	case LET:
-		return p.parseLetStatement()
+	case LET, CONST:
+		if stmt := p.parseLetStatement(); stmt != nil {
+			return stmt
+		}
 	case RETURN:
-		return p.parseReturnStatement()
+		if stmt := p.parseReturnStatement(); stmt != nil {
+			return stmt
+		}
+	case THROW:
+		if stmt := p.parseThrowStatement(); stmt != nil {
+			return stmt
+		}
+	case WHILE:
+		if stmt := p.parseWhileStatement(); stmt != nil {
+			return stmt
+		}
+	case FOR:
+		if stmt := p.parseForStatement(); stmt != nil {
+			return stmt
+		}
+	case BREAK, CONTINUE:
+		if stmt := p.parseLoopControlStatement(); stmt != nil {
+			return stmt
+		}
 	default:
-		return p.parseExpressionStatement()
+		if stmt := p.parseExpressionStatement(); stmt != nil {
+			return stmt
+		}
 	}
+	return nil
 }
 
 func (p *Parser) parseLetStatement() *LetStatement {
@@ -130,9 +263,7 @@ func (p *Parser) parseLetStatement() *LetStatement {
 	p.nextToken()
 	stmt.Value = p.parseExpression(LOWEST)
 
-	for !p.curTokenIs(SEMICOLON) {
-		p.nextToken()
-	}
+	p.skipSemicolon()
 	return stmt
 }
 
@@ -142,24 +273,131 @@ func (p *Parser) parseReturnStatement() *ReturnStatement {
 
 	stmt.ReturnValue = p.parseExpression(LOWEST)
 
-	for !p.curTokenIs(SEMICOLON) {
+	p.skipSemicolon()
+	return stmt
+}
+
+func (p *Parser) parseThrowStatement() *ThrowStatement {
+	stmt := &ThrowStatement{Token: p.curToken}
+	p.nextToken()
+
+	stmt.Value = p.parseExpression(LOWEST)
+
+	p.skipSemicolon()
+	return stmt
+}
+
+func (p *Parser) parseWhileStatement() *WhileStatement {
+	stmt := &WhileStatement{Token: p.curToken}
+	if !p.expectPeek(LPAREN) {
+		return nil
+	}
+	p.nextToken()
+	stmt.Condition = p.parseExpression(LOWEST)
+	if !p.expectPeek(RPAREN) {
+		return nil
+	}
+	if !p.expectPeek(LBRACE) {
+		return nil
+	}
+	stmt.Body = p.parseLoopBody()
+	p.skipSemicolon()
+	return stmt
+}
+
+// parseForStatement parses for (x in xs) { } and for (k, v in xs) { }
+func (p *Parser) parseForStatement() *ForStatement {
+	stmt := &ForStatement{Token: p.curToken}
+	if !p.expectPeek(LPAREN) || !p.expectPeek(IDENT) {
+		return nil
+	}
+	stmt.Value = &Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	if p.peekTokenIs(COMMA) {
 		p.nextToken()
+		if !p.expectPeek(IDENT) {
+			return nil
+		}
+		stmt.Key = stmt.Value
+		stmt.Value = &Identifier{Token: p.curToken, Value: p.curToken.Literal}
 	}
+	if !p.expectPeek(IN) {
+		return nil
+	}
+	p.nextToken()
+	stmt.Iterable = p.parseExpression(LOWEST)
+	if !p.expectPeek(RPAREN) {
+		return nil
+	}
+	if !p.expectPeek(LBRACE) {
+		return nil
+	}
+	stmt.Body = p.parseLoopBody()
+	p.skipSemicolon()
 	return stmt
 }
 
+func (p *Parser) parseLoopBody() *BlockStatement {
+	p.loopDepth++
+	defer func() { p.loopDepth-- }()
+	return p.parseBlockStatement()
+}
+
+func (p *Parser) parseLoopControlStatement() Statement {
+	tok := p.curToken
+	if p.loopDepth == 0 {
+		p.errorf(tok, "%s outside of a loop", tok.Literal)
+		return nil
+	}
+	p.skipSemicolon()
+	if tok.Type == BREAK {
+		return &BreakStatement{Token: tok}
+	}
+	return &ContinueStatement{Token: tok}
+}
+
 func (p *Parser) parseExpressionStatement() *ExpressionStatement {
 	stmt := &ExpressionStatement{Token: p.curToken}
 	stmt.Expression = p.parseExpression(LOWEST)
-	if p.peekTokenIs(SEMICOLON) {
-		p.nextToken()
-	}
+	p.skipSemicolon()
 	return stmt
 }
 
+// report records d unless the parser is still recovering from a previous error
+func (p *Parser) report(d Diagnostic) {
+	if p.panicking {
+		return
+	}
+	p.panicking = true
+	p.errors = append(p.errors, d)
+}
+
+// errorf reports an error spanning tok
+func (p *Parser) errorf(tok Token, format string, a ...interface{}) {
+	p.report(Diagnostic{
+		Severity: SeverityError,
+		Message:  fmt.Sprintf(format, a...),
+		Span:     tok.Span,
+		Actual:   tok.Type,
+	})
+}
+
 func (p *Parser) noPrefixParseFnError(t TokenType) {
-	msg := fmt.Sprintf("no prefix parse function for %s found", t)
-	p.errors = append(p.errors, msg)
+	d := Diagnostic{
+		Severity: SeverityError,
+		Message:  fmt.Sprintf("no prefix parse function for %s found", t),
+		Span:     p.curToken.Span,
+		Actual:   t,
+	}
+	switch t {
+	case ILLEGAL:
+		d.Message = p.curToken.Error
+		if d.Message == "" {
+			d.Message = fmt.Sprintf("illegal character %q", p.curToken.Literal)
+		}
+	case RPAREN, RBRACE, RBRACKET, SEMICOLON, EOF:
+		d.Hint = "an expression is missing or a delimiter is unbalanced"
+	}
+	p.report(d)
 }
 
 func (p *Parser) parseExpression(precedence int) Expression {
@@ -175,7 +413,7 @@ func (p *Parser) parseExpression(precedence int) Expression {
 
 	// NOTE: SEMICOLON check is unnecessary, but helps to show that the statement is complete
 	//for !p.peekTokenIs(SEMICOLON) && precedence < p.peekPrecedence() {
-	for precedence < p.peekPrecedence() {
+	for !p.panicking && precedence < p.peekPrecedence() {
 		infix := p.infixParseFns[p.peekToken.Type]
 		if infix == nil {
 			// TODO: should this be an error
@@ -195,18 +433,61 @@ func (p *Parser) parseIdentifier() Expression {
 func (p *Parser) parseIntegerLiteral() Expression {
 	lit := &IntegerLiteral{Token: p.curToken}
 	value, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
+	if errors.Is(err, strconv.ErrRange) {
+		if value, ok := new(big.Int).SetString(p.curToken.Literal, 0); ok {
+			return &BigIntegerLiteral{Token: p.curToken, Value: value}
+		}
+	}
+	if err != nil {
+		p.errorf(p.curToken, "could not parse %q as integer", p.curToken.Literal)
+		return nil
+	}
+	lit.Value = value
+	return lit
+}
+
+func (p *Parser) parseFloatLiteral() Expression {
+	lit := &FloatLiteral{Token: p.curToken}
+	value, err := strconv.ParseFloat(p.curToken.Literal, 64)
 	if err != nil {
-		msg := fmt.Sprintf("could not parse %q as integer", p.curToken.Literal)
-		p.errors = append(p.errors, msg)
+		p.errorf(p.curToken, "could not parse %q as float", p.curToken.Literal)
 		return nil
 	}
 	lit.Value = value
 	return lit
 }
+
 func (p *Parser) parseStringLiteral() Expression {
 	return &StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
 }
 
+func (p *Parser) parseInterpolatedString() Expression {
+	expression := &InterpolatedString{Token: p.curToken, Strings: []string{p.curToken.Literal}}
+	for {
+		p.nextToken()
+		exp := p.parseExpression(LOWEST)
+		if p.panicking {
+			return nil
+		}
+		expression.Expressions = append(expression.Expressions, exp)
+		if !p.peekTokenIs(TEMPLATE_MIDDLE) && !p.peekTokenIs(TEMPLATE_TAIL) {
+			p.report(Diagnostic{
+				Severity: SeverityError,
+				Message:  fmt.Sprintf("expected '}' closing the interpolation, got %s instead", p.peekToken.Type),
+				Span:     p.peekToken.Span,
+				Actual:   p.peekToken.Type,
+			})
+			return nil
+		}
+		p.nextToken()
+		expression.Strings = append(expression.Strings, p.curToken.Literal)
+		if p.curTokenIs(TEMPLATE_TAIL) {
+			expression.Close = p.curToken
+			return expression
+		}
+	}
+}
+
 func (p *Parser) parseBoolean() Expression {
 	return &BooleanLiteral{Token: p.curToken, Value: p.curTokenIs(TRUE)}
 }
@@ -246,17 +527,74 @@ func (p *Parser) parseIfExpression() Expression {
 	return expression
 }
 
+func (p *Parser) parseTryExpression() Expression {
+	expression := &TryExpression{Token: p.curToken}
+	if !p.expectPeek(LBRACE) {
+		return nil
+	}
+	expression.Block = p.parseBlockStatement()
+
+	if p.peekTokenIs(CATCH) {
+		p.nextToken()
+		if p.peekTokenIs(LPAREN) {
+			p.nextToken()
+			if !p.expectPeek(IDENT) {
+				return nil
+			}
+			expression.CatchParam = &Identifier{Token: p.curToken, Value: p.curToken.Literal}
+			if !p.expectPeek(RPAREN) {
+				return nil
+			}
+		}
+		if !p.expectPeek(LBRACE) {
+			return nil
+		}
+		expression.Catch = p.parseBlockStatement()
+	}
+
+	if p.peekTokenIs(FINALLY) {
+		p.nextToken()
+		if !p.expectPeek(LBRACE) {
+			return nil
+		}
+		expression.Finally = p.parseBlockStatement()
+	}
+
+	if expression.Catch == nil && expression.Finally == nil {
+		// step onto the offending token, so recovery doesn't mistake the
+		// end of the try block for the end of an enclosing one
+		p.nextToken()
+		p.report(Diagnostic{
+			Severity: SeverityError,
+			Message:  fmt.Sprintf("expected catch or finally after try block, got %s instead", p.curToken.Type),
+			Span:     p.curToken.Span,
+			Actual:   p.curToken.Type,
+			Hint:     "add a 'catch (e) { ... }' or 'finally { ... }' block",
+		})
+		return nil
+	}
+	return expression
+}
+
 func (p *Parser) parseBlockStatement() *BlockStatement {
 	block := &BlockStatement{Token: p.curToken}
+	p.blocks = append(p.blocks, p.braceDepth)
+	defer func() { p.blocks = p.blocks[:len(p.blocks)-1] }()
 	block.Statements = []Statement{}
 	p.nextToken()
 	for !p.curTokenIs(RBRACE) && !p.curTokenIs(EOF) {
-		stmt := p.parseStatement()
+		stmt, synced := p.parseStatementRecovering()
 		if stmt != nil {
 			block.Statements = append(block.Statements, stmt)
+		} else if synced && p.curTokenIs(RBRACE) {
+			// recovery stopped at the end of this block
+			break
 		}
 		p.nextToken()
 	}
+	if p.curTokenIs(RBRACE) {
+		block.Close = p.curToken
+	}
 	return block
 }
 
@@ -269,7 +607,11 @@ func (p *Parser) parseFunctionLiteral() Expression {
 	if !p.expectPeek(LBRACE) {
 		return nil
 	}
+	// loops around the function can't be left from inside it
+	loopDepth := p.loopDepth
+	p.loopDepth = 0
 	lit.Body = p.parseBlockStatement()
+	p.loopDepth = loopDepth
 	return lit
 }
 
@@ -278,12 +620,14 @@ func (p *Parser) parseArrayLiteral() Expression {
 		Token: p.curToken,
 	}
 	array.Elements = p.parseExpressionList(RBRACKET)
+	if p.curTokenIs(RBRACKET) {
+		array.Close = p.curToken
+	}
 	return &array
 }
 
 func (p *Parser) parseHashLiteral() Expression {
 	hash := &HashLiteral{Token: p.curToken}
-	hash.Pairs = make(map[Expression]Expression)
 	for !p.peekTokenIs(RBRACE) {
 		p.nextToken()
 		key := p.parseExpression(LOWEST)
@@ -292,7 +636,7 @@ func (p *Parser) parseHashLiteral() Expression {
 		}
 		p.nextToken()
 		value := p.parseExpression(LOWEST)
-		hash.Pairs[key] = value
+		hash.Pairs = append(hash.Pairs, HashLiteralPair{Key: key, Value: value})
 		if !p.peekTokenIs(RBRACE) && !p.expectPeek(COMMA) {
 			return nil
 		}
@@ -300,6 +644,7 @@ func (p *Parser) parseHashLiteral() Expression {
 	if !p.expectPeek(RBRACE) {
 		return nil
 	}
+	hash.Close = p.curToken
 	return hash
 }
 
@@ -346,6 +691,9 @@ func (p *Parser) parseFunctionParameters() []*Identifier {
 func (p *Parser) parseCallExpression(function Expression) Expression {
 	exp := &CallExpression{Token: p.curToken, Function: function}
 	exp.Arguments = p.parseExpressionList(RPAREN)
+	if p.curTokenIs(RPAREN) {
+		exp.Close = p.curToken
+	}
 	return exp
 }
 
@@ -371,18 +719,82 @@ func (p *Parser) parseInfixExpression(left Expression) Expression {
 	return expression
 }
 
+// compoundOperators maps compound assignments to the operator they apply
+var compoundOperators = map[TokenType]string{
+	PLUS_ASSIGN:     "+",
+	MINUS_ASSIGN:    "-",
+	ASTERISK_ASSIGN: "*",
+	SLASH_ASSIGN:    "/",
+}
+
+// parseAssignExpression parses assignments, which are right associative:
+// a = b = c assigns c to both
+func (p *Parser) parseAssignExpression(target Expression) Expression {
+	expression := &AssignExpression{
+		Token:    p.curToken,
+		Target:   target,
+		Operator: compoundOperators[p.curToken.Type],
+	}
+	switch target.(type) {
+	case *Identifier, *IndexExpression:
+	case nil:
+		return nil
+	default:
+		p.report(Diagnostic{
+			Severity: SeverityError,
+			Message:  fmt.Sprintf("cannot assign to %s", target.String()),
+			Span:     target.Span(),
+			Actual:   p.curToken.Type,
+		})
+		return nil
+	}
+	p.nextToken()
+	expression.Value = p.parseExpression(LOWEST)
+	return expression
+}
+
+// parseIndexExpression parses left[index], or the slice left[low:high]
+// where both bounds are optional
 func (p *Parser) parseIndexExpression(left Expression) Expression {
 	exp := &IndexExpression{Token: p.curToken, Left: left}
+	if !p.peekTokenIs(COLON) {
+		p.nextToken()
+		exp.Index = p.parseExpression(LOWEST)
+	}
+	if p.peekTokenIs(COLON) {
+		return p.parseSliceExpression(exp)
+	}
+	if !p.expectPeek(RBRACKET) {
+		return nil
+	}
+	exp.Close = p.curToken
+	return exp
+}
+
+func (p *Parser) parseSliceExpression(index *IndexExpression) Expression {
+	exp := &SliceExpression{Token: index.Token, Left: index.Left, Low: index.Index}
 	p.nextToken()
-	exp.Index = p.parseExpression(LOWEST)
+	if !p.peekTokenIs(RBRACKET) {
+		p.nextToken()
+		exp.High = p.parseExpression(LOWEST)
+	}
 	if !p.expectPeek(RBRACKET) {
 		return nil
 	}
+	exp.Close = p.curToken
 	return exp
 }
 
 // utility funcs ------------------------------
 
+// skipSemicolon consumes the optional ';' ending a statement. Broken
+// statements leave it for synchronize, so curToken stays on the error.
+func (p *Parser) skipSemicolon() {
+	if !p.panicking && p.peekTokenIs(SEMICOLON) {
+		p.nextToken()
+	}
+}
+
 func (p *Parser) curTokenIs(t TokenType) bool {
 	return p.curToken.Type == t
 }
@@ -400,8 +812,18 @@ func (p *Parser) expectPeek(t TokenType) bool {
 }
 
 func (p *Parser) peekError(t TokenType) {
-	msg := fmt.Sprintf("expected next token to be %s, got %s instead", t, p.peekToken.Type)
-	p.errors = append(p.errors, msg)
+	d := Diagnostic{
+		Severity: SeverityError,
+		Message:  fmt.Sprintf("expected next token to be %s, got %s instead", t, p.peekToken.Type),
+		Span:     p.peekToken.Span,
+		Expected: t,
+		Actual:   p.peekToken.Type,
+	}
+	switch t {
+	case RPAREN, RBRACE, RBRACKET:
+		d.Hint = fmt.Sprintf("missing closing '%s'?", t)
+	}
+	p.report(d)
 }
 
 // peekPrecedence returns precedence value for the peekToken, returns LOWEST if no match
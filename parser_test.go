@@ -134,6 +134,43 @@ func TestIntegerLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestFloatLiteralExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"1.5;", 1.5},
+		{"0.25", 0.25},
+		{"1e-3", 0.001},
+		{"2E10", 2e10},
+		{"3.5e+2", 350},
+	}
+	for _, tt := range tests {
+		p := NewParser(NewLexer(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		require.Len(t, program.Statements, 1)
+		stmt, ok := program.Statements[0].(*ExpressionStatement)
+		require.True(t, ok, "got=%T", program.Statements[0])
+		literal, ok := stmt.Expression.(*FloatLiteral)
+		require.True(t, ok, "got=%T", stmt.Expression)
+		require.Equal(t, tt.expected, literal.Value)
+	}
+
+	p := NewParser(NewLexer("123456789012345678901234567890"))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	stmt := program.Statements[0].(*ExpressionStatement)
+	bigLiteral, ok := stmt.Expression.(*BigIntegerLiteral)
+	require.True(t, ok, "got=%T", stmt.Expression)
+	require.Equal(t, "123456789012345678901234567890", bigLiteral.Value.String())
+
+	p = NewParser(NewLexer("1e999"))
+	p.ParseProgram()
+	require.Len(t, p.Errors(), 1)
+	require.Equal(t, `1:1: error: could not parse "1e999" as float`, p.Errors()[0].String())
+}
+
 func TestParsingPrefixExpressions(t *testing.T) {
 	prefixTests := []struct {
 		input    string
@@ -325,6 +362,22 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"add(a * b[2], b[1], 2 * [1, 2][1])",
 			"add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))",
 		},
+		{
+			"a || b && c == d",
+			"(a || (b && (c == d)))",
+		},
+		{
+			"a <= b + c % d",
+			"(a <= (b + (c % d)))",
+		},
+		{
+			"a & b == c | d",
+			"((a & b) == (c | d))",
+		},
+		{
+			"1 << 2 + 3 >> 1 ^ 4",
+			"(((1 << 2) + (3 >> 1)) ^ 4)",
+		},
 	}
 	for i, tt := range tests {
 		t.Run(fmt.Sprintf("test case %d", i), func(t *testing.T) {
@@ -410,6 +463,153 @@ func TestIfElseExpression(t *testing.T) {
 	}
 }
 
+func TestTryExpressionParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		param    string
+		catch    bool
+		finally  bool
+		expected string
+	}{
+		{`try { x } catch (e) { e }`, "e", true, false, "try x catch(e) e"},
+		{`try { x } catch { y }`, "", true, false, "try x catch y"},
+		{`try { x } finally { y }`, "", false, true, "try x finally y"},
+		{`try { x } catch (err) { y } finally { z }`, "err", true, true, "try x catch(err) y finally z"},
+	}
+	for _, tt := range tests {
+		p := NewParser(NewLexer(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		require.Len(t, program.Statements, 1)
+		stmt, ok := program.Statements[0].(*ExpressionStatement)
+		require.True(t, ok, "got=%T", program.Statements[0])
+		exp, ok := stmt.Expression.(*TryExpression)
+		require.True(t, ok, "got=%T", stmt.Expression)
+		require.Len(t, exp.Block.Statements, 1)
+		if tt.param == "" {
+			require.Nil(t, exp.CatchParam)
+		} else {
+			testIdentifier(t, exp.CatchParam, tt.param)
+		}
+		require.Equal(t, tt.catch, exp.Catch != nil)
+		require.Equal(t, tt.finally, exp.Finally != nil)
+		require.Equal(t, tt.expected, program.String())
+	}
+}
+
+func TestThrowStatement(t *testing.T) {
+	p := NewParser(NewLexer(`throw bad; throw error("x", 1)`))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	require.Len(t, program.Statements, 2)
+	stmt, ok := program.Statements[0].(*ThrowStatement)
+	require.True(t, ok, "got=%T", program.Statements[0])
+	testLiteralExpression(t, stmt.Value, "bad")
+	require.Equal(t, `throw bad;throw error(x, 1);`, program.String())
+}
+
+func TestAssignExpressionParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"x = 5", "x = 5"},
+		{"x = y = 1 + 2", "x = y = (1 + 2)"},
+		{"x += a * b", "x += (a * b)"},
+		{"x -= 1; x *= 2; x /= 3", "x -= 1x *= 2x /= 3"},
+		{"a[i + 1] = b || c", "(a[(i + 1)]) = (b || c)"},
+		{`h["k"] += 1`, "(h[k]) += 1"},
+		{"const c = 1;", "const c = 1;"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			p := NewParser(NewLexer(tt.input))
+			program := p.ParseProgram()
+			checkParserErrors(t, p)
+			require.Equal(t, tt.expected, program.String())
+		})
+	}
+
+	p := NewParser(NewLexer("x += 2"))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	assign, ok := program.Statements[0].(*ExpressionStatement).Expression.(*AssignExpression)
+	require.True(t, ok)
+	require.Equal(t, "+", assign.Operator)
+	testLiteralExpression(t, assign.Target, "x")
+	testLiteralExpression(t, assign.Value, 2)
+}
+
+func TestInterpolatedStringParsing(t *testing.T) {
+	p := NewParser(NewLexer(`"x=${x + 1}, y=${f("a ${y}")}!"`))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	stmt := program.Statements[0].(*ExpressionStatement)
+	str, ok := stmt.Expression.(*InterpolatedString)
+	require.True(t, ok, "got=%T", stmt.Expression)
+	require.Equal(t, []string{"x=", ", y=", "!"}, str.Strings)
+	require.Len(t, str.Expressions, 2)
+	testInfixExpression(t, str.Expressions[0], "x", "+", 1)
+	require.Equal(t, "x=${(x + 1)}, y=${f(a ${y})}!", str.String())
+	require.Equal(t, 32, str.Span().End.Column)
+}
+
+func TestSliceExpressionParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"a[1:2]", "(a[1:2])"},
+		{"a[:n - 1]", "(a[:(n - 1)])"},
+		{"a[i:]", "(a[i:])"},
+		{"a[:]", "(a[:])"},
+		{"a[1:][0]", "((a[1:])[0])"},
+		{"f()[{1: 2}[1]:]", "(f()[({1:2}[1]):])"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			p := NewParser(NewLexer(tt.input))
+			program := p.ParseProgram()
+			checkParserErrors(t, p)
+			require.Equal(t, tt.expected, program.String())
+		})
+	}
+}
+
+func TestLoopStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"while (x < 10) { x }", "while ((x < 10)) x"},
+		{"while (true) { break; continue; }", "while (true) break;continue;"},
+		{"for (x in [1, 2]) { x; }", "for (x in [1, 2]) x"},
+		{"for (k, v in h) { puts(k, v) }", "for (k, v in h) puts(k, v)"},
+		{"for (x in xs) { while (x) { break } }", "for (x in xs) while (x) break;"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			p := NewParser(NewLexer(tt.input))
+			program := p.ParseProgram()
+			checkParserErrors(t, p)
+			require.Len(t, program.Statements, 1)
+			require.Equal(t, tt.expected, program.String())
+		})
+	}
+}
+
+func TestForStatement(t *testing.T) {
+	p := NewParser(NewLexer("for (k, v in h) { v }"))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	stmt, ok := program.Statements[0].(*ForStatement)
+	require.True(t, ok, "got=%T", program.Statements[0])
+	testLiteralExpression(t, stmt.Key, "k")
+	testLiteralExpression(t, stmt.Value, "v")
+	testLiteralExpression(t, stmt.Iterable, "h")
+	require.Len(t, stmt.Body.Statements, 1)
+}
+
 func TestFunctionLiteralParsing(t *testing.T) {
 	input := `fn(x, y) { x + y; }`
 	l := NewLexer(input)
@@ -558,18 +758,24 @@ func TestParsingHashLiteralsStringKeys(t *testing.T) {
 		t.Errorf("hash.Pairs has wrong length. got=%d", len(hash.Pairs))
 	}
 
-	expected := map[string]int64{
-		"one":   1,
-		"two":   2,
-		"three": 3,
+	expected := []struct {
+		key   string
+		value int64
+	}{
+		{"one", 1},
+		{"two", 2},
+		{"three", 3},
 	}
-	for key, value := range hash.Pairs {
-		literal, ok := key.(*StringLiteral)
+	for i, pair := range hash.Pairs {
+		literal, ok := pair.Key.(*StringLiteral)
 		if !ok {
-			t.Errorf("key is not ast.StringLiteral. got=%T", key)
+			t.Errorf("key is not ast.StringLiteral. got=%T", pair.Key)
+			continue
+		}
+		if literal.String() != expected[i].key {
+			t.Errorf("pair %d has wrong key. want=%q, got=%q", i, expected[i].key, literal.String())
 		}
-		expectedValue := expected[literal.String()]
-		testIntegerLiteral(t, value, expectedValue)
+		testIntegerLiteral(t, pair.Value, expected[i].value)
 	}
 }
 
@@ -616,10 +822,10 @@ func TestParsingHashLiteralsWithExpressions(t *testing.T) {
 			testInfixExpression(t, e, 15, "/", 5)
 		},
 	}
-	for key, value := range hash.Pairs {
-		literal, ok := key.(*StringLiteral)
+	for _, pair := range hash.Pairs {
+		literal, ok := pair.Key.(*StringLiteral)
 		if !ok {
-			t.Errorf("key is not ast.StringLiteral. got=%T", key)
+			t.Errorf("key is not ast.StringLiteral. got=%T", pair.Key)
 			continue
 		}
 		testFunc, ok := tests[literal.String()]
@@ -627,7 +833,7 @@ func TestParsingHashLiteralsWithExpressions(t *testing.T) {
 			t.Errorf("No test function for key %q found", literal.String())
 			continue
 		}
-		testFunc(value)
+		testFunc(pair.Value)
 	}
 }
 
@@ -671,14 +877,153 @@ func testLetStatement(t *testing.T, s Statement, name string) bool {
 	return true
 }
 
+func TestParserErrorRecovery(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		expected   []string
+		statements int
+	}{
+		{
+			"missing identifier",
+			"let = 5; let y = 10;",
+			[]string{"1:5: error: expected next token to be IDENT, got = instead"},
+			1,
+		},
+		{
+			"one error per broken statement",
+			"let x 5; let = 10; let z = 15;",
+			[]string{
+				"1:7: error: expected next token to be =, got INT instead",
+				"1:14: error: expected next token to be IDENT, got = instead",
+			},
+			1,
+		},
+		{
+			"error inside function body",
+			"let f = fn(x) { x + }; let y = 2; y;",
+			[]string{"1:21: error: no prefix parse function for } found (hint: an expression is missing or a delimiter is unbalanced)"},
+			2,
+		},
+		{
+			"error inside nested block",
+			"let f = fn(x) { if (x) { x + } }; let y = 2; y",
+			[]string{"1:30: error: no prefix parse function for } found (hint: an expression is missing or a delimiter is unbalanced)"},
+			2,
+		},
+		{
+			"unclosed condition",
+			"if (x { 1 } else { 2 }\nlet a = 1;",
+			[]string{"1:7: error: expected next token to be ), got { instead (hint: missing closing ')'?)"},
+			1,
+		},
+		{
+			"missing colon in hash literal",
+			`let h = {"a" 1};` + "\nlet a = 1;",
+			[]string{"1:14: error: expected next token to be :, got INT instead"},
+			1,
+		},
+		{
+			"missing colon in hash literal inside block",
+			`let f = fn() { let h = {"a" 1}; 2 }; f()`,
+			[]string{"1:29: error: expected next token to be :, got INT instead"},
+			1,
+		},
+		{
+			"unmatched closing brace",
+			"} else { 1 }; let a = 1;",
+			[]string{"1:1: error: no prefix parse function for } found (hint: an expression is missing or a delimiter is unbalanced)"},
+			1,
+		},
+		{
+			"unclosed call",
+			"add(1, 2;\nlet a = 1;",
+			[]string{"1:9: error: expected next token to be ), got ; instead (hint: missing closing ')'?)"},
+			1,
+		},
+		{
+			"illegal character",
+			"let a = 1 @ 2;\nlet b = 2;",
+			[]string{"1:11: error: illegal character \"@\""},
+			2,
+		},
+		{
+			"try without catch",
+			"let a = try { 1 };\nlet b = 2;",
+			[]string{"1:18: error: expected catch or finally after try block, got ; instead (hint: add a 'catch (e) { ... }' or 'finally { ... }' block)"},
+			1,
+		},
+		{
+			"invalid assignment target",
+			"1 + 2 = 3;\nlet a = 1;",
+			[]string{"1:1: error: cannot assign to (1 + 2)"},
+			1,
+		},
+		{
+			"invalid escape",
+			"let s = \"a\\qb\";\nlet t = 1;",
+			[]string{`1:9: error: invalid escape sequence "\\q" in string literal`},
+			1,
+		},
+		{
+			"unclosed interpolation",
+			`let s = "a ${b c}";` + "\nlet t = 1;",
+			[]string{"1:16: error: expected '}' closing the interpolation, got IDENT instead"},
+			1,
+		},
+		{
+			"break outside loop",
+			"break;\nlet a = 1;",
+			[]string{"1:1: error: break outside of a loop"},
+			1,
+		},
+		{
+			"continue inside function inside loop",
+			"while (true) { let f = fn() { continue; }; break; }",
+			[]string{"1:31: error: continue outside of a loop"},
+			0,
+		},
+		{
+			"break inside nested function inside loop",
+			"while (true) { let f = fn() { if (true) { break; } }; f(); }\nlet a = 1;",
+			[]string{"1:43: error: break outside of a loop"},
+			1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewParser(NewLexer(tt.input))
+			program := p.ParseProgram()
+			var got []string
+			for _, d := range p.Errors() {
+				got = append(got, d.String())
+			}
+			require.Equal(t, tt.expected, got)
+			require.Len(t, program.Statements, tt.statements)
+		})
+	}
+}
+
+func TestDiagnosticFields(t *testing.T) {
+	p := NewParser(NewFileLexer("a.mk", "let x 5;"))
+	p.ParseProgram()
+	require.Len(t, p.Errors(), 1)
+	d := p.Errors()[0]
+	require.Equal(t, SeverityError, d.Severity)
+	require.Equal(t, TokenType(ASSIGN), d.Expected)
+	require.Equal(t, TokenType(INT), d.Actual)
+	require.Equal(t, "a.mk:1:7", d.Span.Start.String())
+	require.Equal(t, "a.mk:1:8", d.Span.End.String())
+}
+
 func checkParserErrors(t *testing.T, p *Parser) {
-	errors := p.errors
+	errors := p.Errors()
 	if len(errors) == 0 {
 		return
 	}
 	t.Errorf("parser has %d errors", len(errors))
-	for _, msg := range errors {
-		t.Errorf("parser error: %q", msg)
+	for _, d := range errors {
+		t.Errorf("parser error: %q", d.String())
 	}
 	t.FailNow()
 }
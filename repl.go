@@ -2,50 +2,326 @@ package monkey_interpreter
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 )
 
-const PROMPT = ">> "
+const (
+	PROMPT              = ">> "
+	CONTINUATION_PROMPT = ".. "
+
+	// maxHistory is the number of entries kept in the history file
+	maxHistory = 1000
+)
+
+const replHelp = `Meta-commands:
+  :env            list the bindings of the current environment
+  :type EXPR      print the type of EXPR; EXPR is evaluated, so
+                  assignments and calls in it take effect
+  :ast EXPR       print the syntax tree of EXPR
+  :load FILE      run FILE in the current environment
+  :reset          start over with an empty environment
+  :history [N]    list the last N entries (default 20)
+  :redo [N]       run history entry N again (default the last one)
+  :help           show this message
+  :quit           leave the REPL
+Input with unclosed brackets or strings continues on the next line.
+`
+
+// REPL reads programs from In, evaluates them and prints the results to Out.
+// Input spanning several lines is collected until it is complete.
+type REPL struct {
+	In  io.Reader
+	Out io.Writer
+	// HistoryFile persists entries between sessions, "" disables it
+	HistoryFile string
+	// ParseCache caches the programs run by :load, nil disables it
+	ParseCache *ParseCache
+
+	interp  *Interpreter
+	history []string
+	quit    bool
+}
+
+func NewREPL(in io.Reader, out io.Writer) *REPL {
+	r := &REPL{In: in, Out: out}
+	r.reset()
+	return r
+}
+
+// reset starts over with a fresh interpreter, printing to Out
+func (r *REPL) reset() {
+	r.interp = NewInterpreter()
+	r.interp.Stdout = r.Out
+}
+
+// DefaultHistoryFile returns ~/.monkey_history, or "" without a home directory
+func DefaultHistoryFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".monkey_history")
+}
 
 func Start(in io.Reader, out io.Writer) {
-	scanner := bufio.NewScanner(in)
-	env := NewEnvironment()
-	for {
-		_, err := fmt.Fprintf(out, PROMPT)
-		if err != nil {
-			return
-		}
-		scanned := scanner.Scan()
-		if !scanned {
+	r := NewREPL(in, out)
+	r.HistoryFile = DefaultHistoryFile()
+	if dir := DefaultParseCacheDir(); dir != "" {
+		r.ParseCache = NewParseCache(dir)
+	}
+	r.Run()
+}
+
+func (r *REPL) Run() {
+	r.loadHistory()
+	scanner := bufio.NewScanner(r.In)
+	for !r.quit {
+		input, ok := r.readInput(scanner)
+		if !ok {
 			return
 		}
-		line := scanner.Text()
-		l := NewLexer(line)
-		p := NewParser(l)
-		program := p.ParseProgram()
-		if len(p.errors) != 0 {
-			printParserErrors(out, p.errors)
+		if strings.TrimSpace(input) == "" {
 			continue
 		}
-		evaluated := Eval(program, env)
-		if evaluated != nil {
-			_, err := io.WriteString(out, evaluated.Inspect())
-			if err != nil {
-				return
-			}
-			_, err = io.WriteString(out, "\n")
-			if err != nil {
-				return
+		if !strings.HasPrefix(strings.TrimSpace(input), ":redo") {
+			r.addHistory(input)
+		}
+		r.handle(input)
+	}
+}
+
+// readInput reads lines until they form a complete entry. ok is false once
+// the input is exhausted and nothing was read.
+func (r *REPL) readInput(scanner *bufio.Scanner) (string, bool) {
+	var lines []string
+	prompt := PROMPT
+	for {
+		if _, err := io.WriteString(r.Out, prompt); err != nil {
+			return "", false
+		}
+		if !scanner.Scan() {
+			// evaluate what we have, the parser will report what's missing
+			return strings.Join(lines, "\n"), len(lines) > 0
+		}
+		lines = append(lines, scanner.Text())
+		input := strings.Join(lines, "\n")
+		if strings.HasPrefix(strings.TrimSpace(input), ":") || !isIncomplete(input) {
+			return input, true
+		}
+		prompt = CONTINUATION_PROMPT
+	}
+}
+
+// isIncomplete reports whether source ends inside an unclosed bracket or
+// string, so more input is needed before it can be parsed
+func isIncomplete(source string) bool {
+	depth := 0
+	l := NewLexer(source)
+	for tok := l.NextToken(); tok.Type != EOF; tok = l.NextToken() {
+		switch tok.Type {
+		case LPAREN, LBRACE, LBRACKET, TEMPLATE_HEAD:
+			depth++
+		case RPAREN, RBRACE, RBRACKET, TEMPLATE_TAIL:
+			depth--
+		case ILLEGAL:
+			if strings.HasPrefix(tok.Error, "unterminated") {
+				return true
 			}
 		}
 	}
+	return depth > 0
+}
+
+func (r *REPL) handle(input string) {
+	trimmed := strings.TrimSpace(input)
+	if !strings.HasPrefix(trimmed, ":") {
+		r.eval(input)
+		return
+	}
+	command, arg, _ := strings.Cut(trimmed, " ")
+	arg = strings.TrimSpace(arg)
+	switch command {
+	case ":quit", ":q", ":exit":
+		r.quit = true
+	case ":help":
+		r.print(replHelp)
+	case ":env":
+		env := r.interp.Env()
+		for _, name := range env.Names() {
+			val, _ := env.Get(name)
+			r.printf("%s = %s\n", name, val.Inspect())
+		}
+	case ":type":
+		if result := r.evalQuiet(arg); result != nil {
+			r.printf("%s\n", result.Type())
+		}
+	case ":ast":
+		if program, ok := r.parse("<repl>", arg); ok {
+			r.print(DumpTree(program))
+		}
+	case ":load":
+		program, diagnostics, err := ParseFile(arg, r.ParseCache)
+		switch {
+		case err != nil:
+			r.printf("\t%s\n", err)
+		case len(diagnostics) != 0:
+			printParserErrors(r.Out, diagnostics)
+		default:
+			r.printResult(r.interp.evalProgram(context.Background(), program))
+		}
+	case ":reset":
+		r.reset()
+	case ":history":
+		r.listHistory(arg)
+	case ":redo":
+		r.redo(arg)
+	default:
+		r.printf("\tunknown command %s, try :help\n", command)
+	}
 }
-func printParserErrors(out io.Writer, errors []string) {
-	for _, msg := range errors {
-		_, err := io.WriteString(out, "\t"+msg+"\n")
+
+func (r *REPL) eval(input string) {
+	if program, ok := r.parse("<repl>", input); ok {
+		r.printResult(r.interp.evalProgram(context.Background(), program))
+	}
+}
+
+// evalQuiet evaluates input without printing the result, errors are printed
+func (r *REPL) evalQuiet(input string) Object {
+	program, ok := r.parse("<repl>", input)
+	if !ok {
+		return nil
+	}
+	result := r.interp.evalProgram(context.Background(), program)
+	if isError(result) {
+		r.printResult(result)
+		return nil
+	}
+	return result
+}
+
+func (r *REPL) parse(filename, source string) (*Program, bool) {
+	p := NewParser(NewFileLexer(filename, source))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		printParserErrors(r.Out, p.Errors())
+		return nil, false
+	}
+	return program, true
+}
+
+func (r *REPL) printResult(result Object) {
+	if result != nil {
+		r.print(result.Inspect() + "\n")
+	}
+}
+
+func (r *REPL) print(s string) {
+	_, _ = io.WriteString(r.Out, s)
+}
+
+func (r *REPL) printf(format string, a ...interface{}) {
+	_, _ = fmt.Fprintf(r.Out, format, a...)
+}
+
+func printParserErrors(out io.Writer, diagnostics []Diagnostic) {
+	for _, d := range diagnostics {
+		_, err := io.WriteString(out, "\t"+d.String()+"\n")
 		if err != nil {
 			return
 		}
 	}
 }
+
+// history -------------------------------------------------------------------
+
+// loadHistory reads the history file, which holds one quoted entry per
+// line so that multi-line entries survive the round trip
+func (r *REPL) loadHistory() {
+	if r.HistoryFile == "" {
+		return
+	}
+	data, err := os.ReadFile(r.HistoryFile)
+	if err != nil {
+		return
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if entry, err := strconv.Unquote(line); err == nil {
+			r.history = append(r.history, entry)
+		}
+	}
+	if len(r.history) > maxHistory {
+		r.history = r.history[len(r.history)-maxHistory:]
+	}
+}
+
+func (r *REPL) addHistory(entry string) {
+	r.history = append(r.history, entry)
+	if r.HistoryFile == "" {
+		return
+	}
+	if len(r.history) > maxHistory {
+		r.history = r.history[len(r.history)-maxHistory:]
+		r.saveHistory()
+		return
+	}
+	f, err := os.OpenFile(r.HistoryFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	_, _ = f.WriteString(strconv.Quote(entry) + "\n")
+}
+
+// saveHistory rewrites the history file, dropping entries past maxHistory
+func (r *REPL) saveHistory() {
+	var out strings.Builder
+	for _, entry := range r.history {
+		out.WriteString(strconv.Quote(entry) + "\n")
+	}
+	_ = os.WriteFile(r.HistoryFile, []byte(out.String()), 0o600)
+}
+
+func (r *REPL) listHistory(arg string) {
+	n := 20
+	if arg != "" {
+		var err error
+		if n, err = strconv.Atoi(arg); err != nil || n < 0 {
+			r.printf("\tinvalid count %q\n", arg)
+			return
+		}
+	}
+	start := len(r.history) - n
+	if start < 0 {
+		start = 0
+	}
+	for i := start; i < len(r.history); i++ {
+		entry := strings.ReplaceAll(r.history[i], "\n", "\n      ")
+		r.printf("%5d %s\n", i+1, entry)
+	}
+}
+
+func (r *REPL) redo(arg string) {
+	if len(r.history) == 0 {
+		r.print("\thistory is empty\n")
+		return
+	}
+	n := len(r.history)
+	if arg != "" {
+		var err error
+		if n, err = strconv.Atoi(arg); err != nil || n < 1 || n > len(r.history) {
+			r.printf("\tno history entry %q\n", arg)
+			return
+		}
+	}
+	entry := r.history[n-1]
+	r.print(entry + "\n")
+	r.addHistory(entry)
+	r.handle(entry)
+}
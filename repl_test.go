@@ -0,0 +1,142 @@
+package monkey_interpreter
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func runREPL(t *testing.T, historyFile, input string) string {
+	var out bytes.Buffer
+	r := NewREPL(strings.NewReader(input), &out)
+	r.HistoryFile = historyFile
+	r.Run()
+	return out.String()
+}
+
+func TestREPLMultiLineInput(t *testing.T) {
+	input := "let add = fn(a, b) {\n  a + b\n};\nadd(1,\n 2)\nlet s = \"multi\nline\"; len(s)\n"
+	expected := ">> .. .. >> .. 3\n>> .. 10\n>> "
+	require.Equal(t, expected, runREPL(t, "", input))
+}
+
+func TestIsIncomplete(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"1 + 2", false},
+		{"fn(x) {", true},
+		{"fn(x) { x }", false},
+		{"[1, 2", true},
+		{"add(1, [2]", true},
+		{`"unterminated`, true},
+		{`"done"`, false},
+		{"`raw\nstring", true},
+		{`"a ${b`, true},
+		{`"a ${b}"`, false},
+		{"1 /* open", true},
+		{"1 // comment (", false},
+		{"}", false},
+	}
+	for _, tt := range tests {
+		require.Equal(t, tt.expected, isIncomplete(tt.input), tt.input)
+	}
+}
+
+func TestREPLMetaCommands(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "lib.mk")
+	require.NoError(t, os.WriteFile(script, []byte("let double = fn(x) { x * 2 };"), 0o644))
+
+	input := strings.Join([]string{
+		"let a = 1;",
+		"let b = \"two\";",
+		":env",
+		":type b",
+		":type [1]",
+		":ast 1 + 2",
+		":load " + script,
+		"double(a)",
+		":reset",
+		"a",
+		":bogus",
+		":quit",
+		"1 + 1",
+	}, "\n")
+	expected := strings.Join([]string{
+		">> >> >> a = 1",
+		"b = two",
+		">> STRING",
+		">> ARRAY",
+		">> Program 1:1-1:6",
+		"  Statements[0]: ExpressionStatement 1:1-1:6",
+		"    Expression: InfixExpression \"+\" 1:1-1:6",
+		"      Left: IntegerLiteral 1 1:1-1:2",
+		"      Right: IntegerLiteral 2 1:5-1:6",
+		">> >> 2",
+		">> >> ERROR: <repl>:1:1: identifier not found: a",
+		">> \tunknown command :bogus, try :help",
+		">> ",
+	}, "\n")
+	require.Equal(t, expected, runREPL(t, "", input))
+}
+
+func TestREPLLimitsApplyPerInput(t *testing.T) {
+	var out bytes.Buffer
+	input := "let f = fn(n) { if (n > 0) { f(n - 1) } else { 0 } };\nf(30)\nf(30)\nf(30)\n:load missing.mk\n:type f(300)\n"
+	r := NewREPL(strings.NewReader(input), &out)
+	r.interp.MaxSteps = 500
+	r.Run()
+	expected := strings.Join([]string{
+		">> >> 0",
+		">> 0",
+		">> 0",
+		">> \topen missing.mk: no such file or directory",
+		">> ERROR: <repl>:1:17: step limit exceeded: 500",
+		">> ",
+	}, "\n")
+	require.Equal(t, expected, out.String())
+}
+
+func TestREPLLoadParseCache(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "lib.mk")
+	require.NoError(t, os.WriteFile(script, []byte("let x = 2;"), 0o644))
+	cacheDir := filepath.Join(dir, "cache")
+
+	var out bytes.Buffer
+	r := NewREPL(strings.NewReader(":load "+script+"\nx * 3\n"), &out)
+	r.ParseCache = NewParseCache(cacheDir)
+	r.Run()
+	require.Equal(t, ">> >> 6\n>> ", out.String())
+	entries, err := filepath.Glob(filepath.Join(cacheDir, "*.mkast"))
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+}
+
+func TestREPLHistory(t *testing.T) {
+	historyFile := filepath.Join(t.TempDir(), "history")
+	runREPL(t, historyFile, "let x = 40;\nfn(y) {\n  y + 2\n}(x)\n")
+
+	out := runREPL(t, historyFile, ":history\n:redo 1\n:redo\n")
+	expected := strings.Join([]string{
+		">>     1 let x = 40;",
+		"    2 fn(y) {",
+		"        y + 2",
+		"      }(x)",
+		"    3 :history",
+		">> let x = 40;",
+		">> let x = 40;",
+		">> ",
+	}, "\n")
+	require.Equal(t, expected, out)
+
+	data, err := os.ReadFile(historyFile)
+	require.NoError(t, err)
+	require.Equal(t, "\"let x = 40;\"\n\"fn(y) {\\n  y + 2\\n}(x)\"\n\":history\"\n\"let x = 40;\"\n\"let x = 40;\"\n", string(data))
+}
@@ -1,13 +1,60 @@
 package monkey_interpreter
 
+import "fmt"
+
 type TokenType string
 
+// Position describes a location in the source. Line and Column are 1-based,
+// Offset is the 0-based byte offset into the input.
+type Position struct {
+	Filename string
+	Offset   int
+	Line     int
+	Column   int
+}
+
+// IsValid reports whether the position was set by the lexer.
+func (p Position) IsValid() bool { return p.Line > 0 }
+
+// String formats the position as file:line:column, leaving out the file
+// name when it is unknown.
+func (p Position) String() string {
+	if !p.IsValid() {
+		if p.Filename != "" {
+			return p.Filename
+		}
+		return "-"
+	}
+	if p.Filename == "" {
+		return fmt.Sprintf("%d:%d", p.Line, p.Column)
+	}
+	return fmt.Sprintf("%s:%d:%d", p.Filename, p.Line, p.Column)
+}
+
+// Span is a range of source text. End points just past the last character.
+type Span struct {
+	Start Position
+	End   Position
+}
+
 type Token struct {
 	Type    TokenType
 	Literal string
+	Span    Span
+	// Error says what is wrong with an ILLEGAL token, when it is more
+	// than an unexpected character
+	Error string
+	// Comments precede the token, only set if the lexer keeps them
+	Comments []Comment
 }
 
-func newToken(tokenType TokenType, ch byte) Token {
+// Comment is a // or /* */ comment, Text includes the delimiters
+type Comment struct {
+	Text string
+	Span Span
+}
+
+func newToken(tokenType TokenType, ch rune) Token {
 	return Token{Type: tokenType, Literal: string(ch)}
 }
 
@@ -17,7 +64,13 @@ const (
 	// Identifiers + literals
 	IDENT  = "IDENT" // add, foobar, x, y, ...
 	INT    = "INT"
+	FLOAT  = "FLOAT" // 1.5, 1e-3
 	STRING = "STRING"
+	// an interpolated string is lexed as a TEMPLATE_HEAD, expression,
+	// TEMPLATE_MIDDLE, expression, ..., TEMPLATE_TAIL
+	TEMPLATE_HEAD   = "TEMPLATE_HEAD"
+	TEMPLATE_MIDDLE = "TEMPLATE_MIDDLE"
+	TEMPLATE_TAIL   = "TEMPLATE_TAIL"
 	// Operators
 	ASSIGN   = "="
 	PLUS     = "+"
@@ -25,10 +78,27 @@ const (
 	BANG     = "!"
 	ASTERISK = "*"
 	SLASH    = "/"
+	PERCENT  = "%"
 	LT       = "<"
 	GT       = ">"
+	LT_EQ    = "<="
+	GT_EQ    = ">="
 	EQ       = "=="
 	NOT_EQ   = "!="
+	AND      = "&&"
+	OR       = "||"
+	BIT_AND  = "&"
+	BIT_OR   = "|"
+	BIT_XOR  = "^"
+	SHL      = "<<"
+	SHR      = ">>"
+
+	// compound assignments
+	PLUS_ASSIGN     = "+="
+	MINUS_ASSIGN    = "-="
+	ASTERISK_ASSIGN = "*="
+	SLASH_ASSIGN    = "/="
+
 	// Delimiters
 	COMMA     = ","
 	SEMICOLON = ";"
@@ -43,21 +113,41 @@ const (
 	// 1343456
 	FUNCTION = "FUNCTION"
 	LET      = "LET"
+	CONST    = "CONST"
 	TRUE     = "TRUE"
 	FALSE    = "FALSE"
 	IF       = "IF"
 	ELSE     = "ELSE"
 	RETURN   = "RETURN"
+	TRY      = "TRY"
+	CATCH    = "CATCH"
+	FINALLY  = "FINALLY"
+	THROW    = "THROW"
+	WHILE    = "WHILE"
+	FOR      = "FOR"
+	IN       = "IN"
+	BREAK    = "BREAK"
+	CONTINUE = "CONTINUE"
 )
 
 var keywords = map[string]TokenType{
-	"fn":     FUNCTION,
-	"let":    LET,
-	"true":   TRUE,
-	"false":  FALSE,
-	"if":     IF,
-	"else":   ELSE,
-	"return": RETURN,
+	"fn":       FUNCTION,
+	"let":      LET,
+	"const":    CONST,
+	"true":     TRUE,
+	"false":    FALSE,
+	"if":       IF,
+	"else":     ELSE,
+	"return":   RETURN,
+	"try":      TRY,
+	"catch":    CATCH,
+	"finally":  FINALLY,
+	"throw":    THROW,
+	"while":    WHILE,
+	"for":      FOR,
+	"in":       IN,
+	"break":    BREAK,
+	"continue": CONTINUE,
 }
 
 func LookupIdent(ident string) TokenType {
@@ -0,0 +1,152 @@
+package monkey_interpreter
+
+// vector is a persistent vector, a trie of 32-way nodes holding all but the
+// last few elements, which are kept in a tail for cheap appends. Updates
+// copy the path to the changed leaf and share everything else, so they
+// take O(log n) time and leave the original untouched.
+type vector struct {
+	count int
+	shift uint // bits of the index consumed by the root level
+	root  *vectorNode
+	tail  []Object
+}
+
+const (
+	vectorBits  = 5
+	vectorWidth = 1 << vectorBits
+	vectorMask  = vectorWidth - 1
+)
+
+// vectorNode is an interior node with children, or a leaf with values
+type vectorNode struct {
+	children []*vectorNode
+	values   []Object
+}
+
+var emptyVector = &vector{shift: vectorBits, root: &vectorNode{}}
+
+// newVector returns a vector of elements, which it copies
+func newVector(elements []Object) *vector {
+	v := emptyVector
+	tailStart := tailOffset(len(elements))
+	for i := 0; i < tailStart; i += vectorWidth {
+		leaf := &vectorNode{values: append([]Object(nil), elements[i:i+vectorWidth]...)}
+		v = v.withLeaf(leaf, i+vectorWidth)
+	}
+	return &vector{
+		count: len(elements),
+		shift: v.shift,
+		root:  v.root,
+		tail:  append([]Object(nil), elements[tailStart:]...),
+	}
+}
+
+// tailOffset returns the index of the first element in the tail of a
+// vector of count elements
+func tailOffset(count int) int {
+	if count < vectorWidth {
+		return 0
+	}
+	return ((count - 1) >> vectorBits) << vectorBits
+}
+
+func (v *vector) get(i int) Object {
+	return v.leaf(i)[i&vectorMask]
+}
+
+// leaf returns the values of the leaf, or the tail, holding element i
+func (v *vector) leaf(i int) []Object {
+	if i >= tailOffset(v.count) {
+		return v.tail
+	}
+	node := v.root
+	for level := v.shift; level > 0; level -= vectorBits {
+		node = node.children[(i>>level)&vectorMask]
+	}
+	return node.values
+}
+
+// copyTo copies the elements from start on into dst, a leaf at a time
+func (v *vector) copyTo(dst []Object, start int) {
+	for n := 0; n < len(dst); {
+		i := start + n
+		n += copy(dst[n:], v.leaf(i)[i&vectorMask:])
+	}
+}
+
+// push returns a vector with val appended
+func (v *vector) push(val Object) *vector {
+	if v.count-tailOffset(v.count) < vectorWidth {
+		tail := make([]Object, len(v.tail)+1)
+		copy(tail, v.tail)
+		tail[len(v.tail)] = val
+		return &vector{count: v.count + 1, shift: v.shift, root: v.root, tail: tail}
+	}
+	// the tail is full, it moves into the trie
+	full := v.withLeaf(&vectorNode{values: v.tail}, v.count)
+	return &vector{count: v.count + 1, shift: full.shift, root: full.root, tail: []Object{val}}
+}
+
+// withLeaf returns the trie with leaf added as the last leaf, which holds
+// the elements up to count. Only root and shift of the result are set.
+func (v *vector) withLeaf(leaf *vectorNode, count int) *vector {
+	if (count-1)>>vectorBits >= 1<<v.shift {
+		// the root is full, grow a level
+		root := &vectorNode{children: make([]*vectorNode, vectorWidth)}
+		root.children[0] = v.root
+		root.children[1] = newPath(v.shift, leaf)
+		return &vector{shift: v.shift + vectorBits, root: root}
+	}
+	return &vector{shift: v.shift, root: pushLeaf(v.shift, v.root, leaf, count)}
+}
+
+func pushLeaf(level uint, parent, leaf *vectorNode, count int) *vectorNode {
+	node := &vectorNode{children: make([]*vectorNode, vectorWidth)}
+	copy(node.children, parent.children)
+	i := ((count - 1) >> level) & vectorMask
+	switch {
+	case level == vectorBits:
+		node.children[i] = leaf
+	case parent.children[i] != nil:
+		node.children[i] = pushLeaf(level-vectorBits, parent.children[i], leaf, count)
+	default:
+		node.children[i] = newPath(level-vectorBits, leaf)
+	}
+	return node
+}
+
+// newPath returns leaf under level levels of single child nodes
+func newPath(level uint, leaf *vectorNode) *vectorNode {
+	if level == 0 {
+		return leaf
+	}
+	node := &vectorNode{children: make([]*vectorNode, vectorWidth)}
+	node.children[0] = newPath(level-vectorBits, leaf)
+	return node
+}
+
+// set returns a vector with the element at i, which must exist, replaced
+// by val
+func (v *vector) set(i int, val Object) *vector {
+	if i >= tailOffset(v.count) {
+		tail := make([]Object, len(v.tail))
+		copy(tail, v.tail)
+		tail[i&vectorMask] = val
+		return &vector{count: v.count, shift: v.shift, root: v.root, tail: tail}
+	}
+	return &vector{count: v.count, shift: v.shift, root: setLeafValue(v.shift, v.root, i, val), tail: v.tail}
+}
+
+func setLeafValue(level uint, node *vectorNode, i int, val Object) *vectorNode {
+	if level == 0 {
+		values := make([]Object, len(node.values))
+		copy(values, node.values)
+		values[i&vectorMask] = val
+		return &vectorNode{values: values}
+	}
+	children := make([]*vectorNode, vectorWidth)
+	copy(children, node.children)
+	sub := (i >> level) & vectorMask
+	children[sub] = setLeafValue(level-vectorBits, children[sub], i, val)
+	return &vectorNode{children: children}
+}
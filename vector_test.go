@@ -0,0 +1,77 @@
+package monkey_interpreter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func ints(n int) []Object {
+	elements := make([]Object, n)
+	for i := range elements {
+		elements[i] = &Integer{Value: int64(i)}
+	}
+	return elements
+}
+
+func requireElements(t *testing.T, want []Object, arr *Array) {
+	t.Helper()
+	require.Equal(t, len(want), arr.Len())
+	for i, el := range want {
+		require.Same(t, el, arr.Get(i), "element %d", i)
+	}
+	require.Equal(t, want, arr.Elements())
+}
+
+func TestArrayPush(t *testing.T) {
+	// enough elements for a trie three levels deep
+	elements := ints(vectorWidth*vectorWidth + 2*vectorWidth + 3)
+	arr := &Array{}
+	versions := []*Array{arr}
+	for _, el := range elements {
+		arr = arr.Push(el)
+		versions = append(versions, arr)
+	}
+	requireElements(t, elements, arr)
+	// every version is untouched by the pushes after it
+	for n, version := range versions {
+		require.Equal(t, n, version.Len())
+		if n > 0 {
+			require.Same(t, elements[n-1], version.Get(n-1))
+		}
+	}
+	for _, n := range []int{0, 1, vectorWidth, vectorWidth + 1, len(elements)} {
+		requireElements(t, elements[:n], NewArray(elements[:n]))
+	}
+}
+
+func TestArraySet(t *testing.T) {
+	elements := ints(vectorWidth*vectorWidth + 5)
+	arr := NewArray(elements)
+	for _, i := range []int{0, 31, 32, 500, len(elements) - 1} {
+		updated := arr.Set(i, TRUE_OBJ)
+		want := append([]Object(nil), elements...)
+		want[i] = TRUE_OBJ
+		requireElements(t, want, updated)
+	}
+	requireElements(t, elements, arr)
+}
+
+func TestArraySlice(t *testing.T) {
+	elements := ints(100)
+	arr := NewArray(elements)
+	slice := arr.Slice(10, 40)
+	requireElements(t, elements[10:40], slice)
+	requireElements(t, elements[10:40], slice.Slice(0, 30))
+	requireElements(t, elements[15:20], slice.Slice(5, 10))
+
+	// pushing onto a slice reuses the slot after it without changing arr
+	pushed := slice.Push(TRUE_OBJ)
+	want := append(append([]Object(nil), elements[10:40]...), TRUE_OBJ)
+	requireElements(t, want, pushed)
+	requireElements(t, elements, arr)
+	requireElements(t, elements[10:40], slice)
+
+	requireElements(t, []Object{}, arr.Slice(100, 100))
+	requireElements(t, []Object{FALSE_OBJ}, arr.Slice(100, 100).Push(FALSE_OBJ))
+}
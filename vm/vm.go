@@ -0,0 +1,450 @@
+// Package vm runs the bytecode of package compiler. It shares the
+// semantics and error messages of the tree-walking interpreter, and the
+// builtins and globals of the Interpreter it runs for.
+package vm
+
+import (
+	"fmt"
+	"strings"
+
+	monkey "monkey-interpreter"
+	"monkey-interpreter/compiler"
+)
+
+const (
+	StackSize   = 2048
+	GlobalsSize = 65536
+	MaxFrames   = 1024
+)
+
+// Run compiles program and runs it on a VM, like interp.EvalProgram does
+// with the interpreter. The program sees the builtins and globals of
+// interp, bindings it makes are not copied back. If program uses a
+// construct the compiler doesn't support, nothing is run and the returned
+// *Error wraps compiler.ErrUnsupported. The limits and context of interp
+// are not enforced.
+func Run(interp *monkey.Interpreter, program *monkey.Program) (monkey.Object, error) {
+	c := compiler.New(interp.BuiltinNames())
+	globals := make([]monkey.Object, GlobalsSize)
+	env := interp.Env()
+	for _, name := range env.Names() {
+		define := c.SymbolTable().Define
+		if env.IsConst(name) {
+			define = c.SymbolTable().DefineConst
+		}
+		globals[define(name).Index], _ = env.Get(name)
+	}
+	if err := c.Compile(program); err != nil {
+		return nil, err
+	}
+	vm := New(c.Bytecode(), interp)
+	vm.globals = globals
+	if err := vm.Run(); err != nil {
+		return nil, err
+	}
+	if result := vm.LastPoppedStackElem(); result != nil {
+		return result, nil
+	}
+	return monkey.NULL_OBJ, nil
+}
+
+// frame is the activation record of a closure call
+type frame struct {
+	cl          *Closure
+	ip          int
+	basePointer int // stack pointer before the call, locals live above it
+}
+
+func newFrame(cl *Closure, basePointer int) *frame {
+	return &frame{cl: cl, ip: -1, basePointer: basePointer}
+}
+
+func (f *frame) Instructions() compiler.Instructions {
+	return f.cl.Fn.Instructions
+}
+
+// VM executes Bytecode on a value stack
+type VM struct {
+	constants []monkey.Object
+	globals   []monkey.Object
+	builtins  []monkey.Object
+
+	stack []monkey.Object
+	sp    int // always points to the next free slot, top of stack is stack[sp-1]
+
+	frames      []*frame
+	framesIndex int
+
+	// result is set when a top level return statement halts the program
+	result monkey.Object
+}
+
+// New returns a VM for bytecode that looks its builtins up in interp
+func New(bytecode *compiler.Bytecode, interp *monkey.Interpreter) *VM {
+	mainFn := &compiler.CompiledFunction{Instructions: bytecode.Instructions}
+	frames := make([]*frame, MaxFrames)
+	frames[0] = newFrame(&Closure{Fn: mainFn}, 0)
+	builtins := make([]monkey.Object, len(bytecode.Builtins))
+	for i, name := range bytecode.Builtins {
+		if b, ok := interp.Builtin(name); ok {
+			builtins[i] = b
+		}
+	}
+	return &VM{
+		constants:   bytecode.Constants,
+		globals:     make([]monkey.Object, GlobalsSize),
+		builtins:    builtins,
+		stack:       make([]monkey.Object, StackSize),
+		frames:      frames,
+		framesIndex: 1,
+	}
+}
+
+// LastPoppedStackElem returns the value of the last expression statement,
+// which is the result of the program
+func (vm *VM) LastPoppedStackElem() monkey.Object {
+	if vm.result != nil {
+		return vm.result
+	}
+	return vm.stack[vm.sp]
+}
+
+func (vm *VM) currentFrame() *frame { return vm.frames[vm.framesIndex-1] }
+
+func (vm *VM) pushFrame(f *frame) error {
+	if vm.framesIndex >= MaxFrames {
+		return newError("stack overflow: more than %d nested calls", MaxFrames)
+	}
+	vm.frames[vm.framesIndex] = f
+	vm.framesIndex++
+	return nil
+}
+
+func (vm *VM) popFrame() *frame {
+	vm.framesIndex--
+	return vm.frames[vm.framesIndex]
+}
+
+// Run executes the bytecode. Runtime errors are returned as *Error.
+func (vm *VM) Run() error {
+	return vm.run(0)
+}
+
+// run executes instructions until the number of frames drops to stop, or
+// the main frame runs out of instructions
+func (vm *VM) run(stop int) error {
+	var (
+		ip  int
+		ins compiler.Instructions
+		op  compiler.Opcode
+	)
+	for vm.framesIndex > stop && vm.currentFrame().ip < len(vm.currentFrame().Instructions())-1 {
+		vm.currentFrame().ip++
+		ip = vm.currentFrame().ip
+		ins = vm.currentFrame().Instructions()
+		op = compiler.Opcode(ins[ip])
+
+		var err error
+		switch op {
+		case compiler.OpConstant:
+			constIndex := compiler.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+			err = vm.push(vm.constants[constIndex])
+		case compiler.OpPop:
+			vm.pop()
+		case compiler.OpTrue:
+			err = vm.push(monkey.TRUE_OBJ)
+		case compiler.OpFalse:
+			err = vm.push(monkey.FALSE_OBJ)
+		case compiler.OpNull:
+			err = vm.push(monkey.NULL_OBJ)
+
+		case compiler.OpAdd, compiler.OpSub, compiler.OpMul, compiler.OpDiv, compiler.OpEqual, compiler.OpNotEqual, compiler.OpLessThan, compiler.OpGreaterThan,
+			compiler.OpMod, compiler.OpLessEqual, compiler.OpGreaterEqual, compiler.OpBitAnd, compiler.OpBitOr, compiler.OpBitXor, compiler.OpShiftLeft, compiler.OpShiftRight:
+			err = vm.executeBinaryOperation(op)
+		case compiler.OpMinus:
+			err = vm.pushResult(monkey.PrefixOperation("-", vm.pop()))
+		case compiler.OpBang:
+			err = vm.push(monkey.PrefixOperation("!", vm.pop()))
+
+		case compiler.OpJump:
+			pos := int(compiler.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip = pos - 1
+		case compiler.OpJumpNotTruthy:
+			pos := int(compiler.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+			if !monkey.IsTruthy(vm.pop()) {
+				vm.currentFrame().ip = pos - 1
+			}
+		case compiler.OpJumpIfFalsyOrPop, compiler.OpJumpIfTruthyOrPop:
+			pos := int(compiler.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+			if monkey.IsTruthy(vm.stack[vm.sp-1]) == (op == compiler.OpJumpIfTruthyOrPop) {
+				vm.currentFrame().ip = pos - 1
+			} else {
+				vm.pop()
+			}
+
+		case compiler.OpSetGlobal:
+			globalIndex := compiler.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+			vm.globals[globalIndex] = vm.pop()
+		case compiler.OpGetGlobal:
+			globalIndex := compiler.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+			err = vm.push(vm.globals[globalIndex])
+		case compiler.OpSetLocal:
+			localIndex := compiler.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+			frame := vm.currentFrame()
+			vm.stack[frame.basePointer+int(localIndex)] = vm.pop()
+		case compiler.OpGetLocal:
+			localIndex := compiler.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+			frame := vm.currentFrame()
+			err = vm.push(vm.stack[frame.basePointer+int(localIndex)])
+		case compiler.OpGetBuiltin:
+			builtinIndex := compiler.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+			err = vm.push(vm.builtins[builtinIndex])
+		case compiler.OpGetFree:
+			freeIndex := compiler.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+			err = vm.push(vm.currentFrame().cl.Free[freeIndex])
+		case compiler.OpCurrentClosure:
+			err = vm.push(vm.currentFrame().cl)
+
+		case compiler.OpArray:
+			numElements := int(compiler.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+			elements := make([]monkey.Object, numElements)
+			copy(elements, vm.stack[vm.sp-numElements:vm.sp])
+			vm.sp -= numElements
+			err = vm.push(monkey.NewArray(elements))
+		case compiler.OpHash:
+			numElements := int(compiler.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+			hash := vm.buildHash(vm.sp-numElements, vm.sp)
+			vm.sp -= numElements
+			err = vm.pushResult(hash)
+		case compiler.OpIndex:
+			index := vm.pop()
+			left := vm.pop()
+			err = vm.pushResult(monkey.IndexOperation(left, index))
+		case compiler.OpSlice:
+			high := vm.pop()
+			low := vm.pop()
+			left := vm.pop()
+			err = vm.pushResult(monkey.SliceOperation(left, low, high))
+		case compiler.OpInterpolate:
+			n := int(compiler.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+			var out strings.Builder
+			for _, val := range vm.stack[vm.sp-n : vm.sp] {
+				out.WriteString(val.Inspect())
+			}
+			vm.sp -= n
+			err = vm.push(&monkey.String{Value: out.String()})
+
+		case compiler.OpCall:
+			numArgs := compiler.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+			err = vm.executeCall(int(numArgs))
+		case compiler.OpReturnValue:
+			returnValue := vm.pop()
+			if vm.framesIndex == 1 {
+				// return at the top level ends the program
+				vm.result = returnValue
+				return nil
+			}
+			frame := vm.popFrame()
+			vm.sp = frame.basePointer - 1
+			err = vm.push(returnValue)
+		case compiler.OpReturn:
+			frame := vm.popFrame()
+			vm.sp = frame.basePointer - 1
+			err = vm.push(monkey.NULL_OBJ)
+		case compiler.OpClosure:
+			constIndex := compiler.ReadUint16(ins[ip+1:])
+			numFree := compiler.ReadUint8(ins[ip+3:])
+			vm.currentFrame().ip += 3
+			err = vm.pushClosure(int(constIndex), int(numFree))
+		default:
+			err = newError("unknown opcode %d", op)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (vm *VM) push(o monkey.Object) error {
+	if vm.sp >= StackSize {
+		return newError("stack overflow: more than %d values on the stack", StackSize)
+	}
+	vm.stack[vm.sp] = o
+	vm.sp++
+	return nil
+}
+
+// pushResult pushes the result of an operation, or halts if it is an error
+func (vm *VM) pushResult(o monkey.Object) error {
+	if err, ok := o.(*monkey.Error); ok {
+		return err
+	}
+	return vm.push(o)
+}
+
+func (vm *VM) pop() monkey.Object {
+	o := vm.stack[vm.sp-1]
+	vm.sp--
+	return o
+}
+
+func (vm *VM) executeBinaryOperation(op compiler.Opcode) error {
+	right := vm.pop()
+	left := vm.pop()
+	// fast path for integer addition, subtraction and comparison, everything
+	// else, including results that overflow, shares Eval's semantics
+	if l, ok := left.(*monkey.Integer); ok {
+		if r, ok := right.(*monkey.Integer); ok {
+			switch op {
+			case compiler.OpAdd:
+				if v := l.Value + r.Value; (v > l.Value) == (r.Value > 0) {
+					return vm.push(&monkey.Integer{Value: v})
+				}
+			case compiler.OpSub:
+				if v := l.Value - r.Value; (v < l.Value) == (r.Value > 0) {
+					return vm.push(&monkey.Integer{Value: v})
+				}
+			case compiler.OpLessThan:
+				return vm.push(boolObject(l.Value < r.Value))
+			case compiler.OpGreaterThan:
+				return vm.push(boolObject(l.Value > r.Value))
+			case compiler.OpLessEqual:
+				return vm.push(boolObject(l.Value <= r.Value))
+			case compiler.OpGreaterEqual:
+				return vm.push(boolObject(l.Value >= r.Value))
+			}
+		}
+	}
+	return vm.pushResult(monkey.InfixOperation(compiler.InfixOperators[op], left, right))
+}
+
+func (vm *VM) buildHash(startIndex, endIndex int) monkey.Object {
+	hash := monkey.NewHash((endIndex - startIndex) / 2)
+	for i := startIndex; i < endIndex; i += 2 {
+		key := vm.stack[i]
+		value := vm.stack[i+1]
+		hashKey, err := monkey.HashableKey(key)
+		if err != nil {
+			return err
+		}
+		hash.Set(hashKey, value)
+	}
+	return hash
+}
+
+func (vm *VM) executeCall(numArgs int) error {
+	callee := vm.stack[vm.sp-1-numArgs]
+	switch callee := callee.(type) {
+	case *Closure:
+		return vm.callClosure(callee, numArgs)
+	default:
+		// builtins, and functions of the interpreter found in its globals
+		args := vm.stack[vm.sp-numArgs : vm.sp]
+		result, err := monkey.Call(callee, args...)
+		vm.sp = vm.sp - numArgs - 1
+		if err != nil {
+			return err
+		}
+		return vm.push(result)
+	}
+}
+
+// call runs cl with args to completion and returns its result, for calls
+// made from outside the instruction loop, e.g. a builtin calling back into
+// a closure it was passed
+func (vm *VM) call(cl *Closure, args []monkey.Object) monkey.Object {
+	sp, framesIndex := vm.sp, vm.framesIndex
+	defer func() { vm.sp, vm.framesIndex = sp, framesIndex }()
+	err := vm.push(cl)
+	for _, arg := range args {
+		if err == nil {
+			err = vm.push(arg)
+		}
+	}
+	if err == nil {
+		err = vm.callClosure(cl, len(args))
+	}
+	if err == nil {
+		err = vm.run(framesIndex)
+	}
+	if err != nil {
+		if errObj, ok := err.(*monkey.Error); ok {
+			return errObj
+		}
+		return newError("%s", err)
+	}
+	return vm.stack[vm.sp-1]
+}
+
+func (vm *VM) callClosure(cl *Closure, numArgs int) error {
+	if numArgs != cl.Fn.NumParameters {
+		return newError("wrong number of arguments: want=%d, got=%d",
+			cl.Fn.NumParameters, numArgs)
+	}
+	frame := newFrame(cl, vm.sp-numArgs)
+	if err := vm.pushFrame(frame); err != nil {
+		return err
+	}
+	if frame.basePointer+cl.Fn.NumLocals >= StackSize {
+		return newError("stack overflow: more than %d values on the stack", StackSize)
+	}
+	// reserve the slots for the locals
+	vm.sp = frame.basePointer + cl.Fn.NumLocals
+	return nil
+}
+
+func (vm *VM) pushClosure(constIndex, numFree int) error {
+	constant := vm.constants[constIndex]
+	function, ok := constant.(*compiler.CompiledFunction)
+	if !ok {
+		return newError("not a function: %+v", constant)
+	}
+	free := make([]monkey.Object, numFree)
+	copy(free, vm.stack[vm.sp-numFree:vm.sp])
+	vm.sp = vm.sp - numFree
+	return vm.push(&Closure{Fn: function, Free: free, vm: vm})
+}
+
+// Closure is a CompiledFunction together with the free variables it captured
+type Closure struct {
+	Fn   *compiler.CompiledFunction
+	Free []monkey.Object
+	vm   *VM // runs calls made from outside the VM, by builtins or the host
+}
+
+// Type reports FUNCTION, scripts can't tell closures and functions apart
+func (c *Closure) Type() monkey.ObjectType { return monkey.FUNCTION_OBJ_TYPE }
+func (c *Closure) Inspect() string {
+	return fmt.Sprintf("Closure[%p]", c)
+}
+
+// Call runs the closure on the VM that created it, it makes closures
+// monkey.Callable
+func (c *Closure) Call(args ...monkey.Object) monkey.Object {
+	return c.vm.call(c, args)
+}
+
+func newError(format string, a ...interface{}) *monkey.Error {
+	return &monkey.Error{Message: fmt.Sprintf(format, a...)}
+}
+
+func boolObject(b bool) *monkey.BooleanObject {
+	if b {
+		return monkey.TRUE_OBJ
+	}
+	return monkey.FALSE_OBJ
+}
@@ -0,0 +1,162 @@
+package vm
+
+import (
+	"errors"
+	"testing"
+
+	monkey "monkey-interpreter"
+	"monkey-interpreter/compiler"
+
+	"github.com/stretchr/testify/require"
+)
+
+// testRun parses and runs input on the VM for a fresh interpreter
+func testRun(t *testing.T, input string) (monkey.Object, error) {
+	p := monkey.NewParser(monkey.NewLexer(input))
+	program := p.ParseProgram()
+	require.Empty(t, p.Errors())
+	return Run(monkey.NewInterpreter(), program)
+}
+
+func TestVMRecursiveFunctions(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected int64
+	}{
+		{
+			"global recursion",
+			"let fib = fn(n) { if (n < 2) { return n; } fib(n - 1) + fib(n - 2) }; fib(15);",
+			610,
+		},
+		{
+			"local recursion",
+			`let wrapper = fn() {
+			   let countDown = fn(x) { if (x == 0) { return 0; } countDown(x - 1) };
+			   countDown(5) + 1;
+			 };
+			 wrapper();`,
+			1,
+		},
+		{
+			"closure over closure",
+			`let newAdder = fn(a) { fn(b) { fn(c) { a + b + c } } };
+			 newAdder(1)(2)(3);`,
+			6,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := testRun(t, tt.input)
+			require.NoError(t, err)
+			require.Equal(t, &monkey.Integer{Value: tt.expected}, result)
+		})
+	}
+}
+
+func TestVMErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"fn(a) { a }();", "wrong number of arguments: want=1, got=0"},
+		{"let f = fn(x) { f(x) }; f(1);", "stack overflow: more than 1024 nested calls"},
+		{"1(2)", "not a function: INTEGER"},
+		{"const c = 1; let c = 2", "cannot redeclare constant: c"},
+		{"const c = 1; const c = 2", "cannot redeclare constant: c"},
+		{"map([1], fn(x) { x + true })", "type mismatch: INTEGER + BOOLEAN"},
+	}
+	for _, tt := range tests {
+		_, err := testRun(t, tt.input)
+		var errObj *monkey.Error
+		require.True(t, errors.As(err, &errObj), "no error returned for %q", tt.input)
+		require.Equal(t, tt.expected, errObj.Message)
+		require.False(t, errors.Is(err, compiler.ErrUnsupported))
+	}
+}
+
+func TestVMUnsupportedConstructs(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"while (false) { 1 }", "1:1: the bytecode VM does not support while loops"},
+		{"for (x in [1]) { x }", "1:1: the bytecode VM does not support for loops"},
+		{"fn() { while (true) { break; } }", "1:8: the bytecode VM does not support while loops"},
+		{"let x = 1;\nx = 2", "2:1: the bytecode VM does not support assignment"},
+		{"let x = 1; x += 2", "1:12: the bytecode VM does not support assignment"},
+		{`throw "up"`, "1:1: the bytecode VM does not support throw"},
+		{"try { 1 } catch (e) { 2 }", "1:1: the bytecode VM does not support try expressions"},
+	}
+	for _, tt := range tests {
+		_, err := testRun(t, tt.input)
+		require.EqualError(t, err, tt.expected)
+		require.True(t, errors.Is(err, compiler.ErrUnsupported))
+	}
+}
+
+func TestVMUsesInterpreter(t *testing.T) {
+	interp := monkey.NewInterpreter()
+	interp.RegisterBuiltin("double", func(args ...monkey.Object) monkey.Object {
+		return &monkey.Integer{Value: 2 * args[0].(*monkey.Integer).Value}
+	})
+	interp.SetGlobal("base", &monkey.Integer{Value: 10})
+	_, err := interp.Eval("const limit = 3; let inc = fn(x) { x + 1 };")
+	require.NoError(t, err)
+
+	run := func(input string) (monkey.Object, error) {
+		return Run(interp, monkey.NewParser(monkey.NewLexer(input)).ParseProgram())
+	}
+	result, err := run("inc(double(base)) + limit")
+	require.NoError(t, err)
+	require.Equal(t, "24", result.Inspect())
+
+	_, err = run("let limit = 4")
+	require.EqualError(t, err, "1:1: cannot redeclare constant: limit")
+
+	// the globals of interp are untouched
+	result, err = run("let base = 1; base")
+	require.NoError(t, err)
+	require.Equal(t, "1", result.Inspect())
+	base, _ := interp.GetGlobal("base")
+	require.Equal(t, "10", base.Inspect())
+}
+
+func TestVMClosuresAsCallbacks(t *testing.T) {
+	result, err := testRun(t, "let k = 10; map([1, 2], fn(x) { x + k })")
+	require.NoError(t, err)
+	require.Equal(t, "[11, 12]", result.Inspect())
+
+	fn, err := testRun(t, "let k = 10; fn(x) { x * k }")
+	require.NoError(t, err)
+	require.Implements(t, (*monkey.Callable)(nil), fn)
+	result, err = monkey.Call(fn, &monkey.Integer{Value: 4})
+	require.NoError(t, err)
+	require.Equal(t, "40", result.Inspect())
+}
+
+const fibonacciInput = `
+let fibonacci = fn(x) {
+  if (x < 2) { return x; }
+  fibonacci(x - 1) + fibonacci(x - 2);
+};
+fibonacci(20);
+`
+
+func BenchmarkFibonacciEval(b *testing.B) {
+	program := monkey.NewParser(monkey.NewLexer(fibonacciInput)).ParseProgram()
+	for i := 0; i < b.N; i++ {
+		if _, err := monkey.NewInterpreter().EvalProgram(program); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkFibonacciVM(b *testing.B) {
+	program := monkey.NewParser(monkey.NewLexer(fibonacciInput)).ParseProgram()
+	for i := 0; i < b.N; i++ {
+		if _, err := Run(monkey.NewInterpreter(), program); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
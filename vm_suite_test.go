@@ -0,0 +1,28 @@
+package monkey_interpreter_test
+
+import (
+	"testing"
+
+	monkey "monkey-interpreter"
+	"monkey-interpreter/vm"
+)
+
+// testRunVM compiles and runs input on the VM. Compile and runtime errors
+// are returned as *Error objects, like Eval does.
+func testRunVM(input string) monkey.Object {
+	program := monkey.NewParser(monkey.NewLexer(input)).ParseProgram()
+	result, err := vm.Run(monkey.NewInterpreter(), program)
+	if err != nil {
+		return err.(*monkey.Error)
+	}
+	return result
+}
+
+// TestEvaluatorSuiteOnVM runs the evaluator test cases against the VM, to
+// prove both backends give identical results
+func TestEvaluatorSuiteOnVM(t *testing.T) {
+	defer monkey.SetTestEval(testRunVM)()
+	for _, tt := range monkey.EvaluatorSuite {
+		t.Run(tt.Name, tt.Test)
+	}
+}